@@ -0,0 +1,276 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/joltifybridge"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// the actions the operator can take on a queued item
+const (
+	adminDrop    = "drop"
+	adminRequeue = "requeue"
+	adminRetry   = "retry"
+)
+
+// adminTokenBytes is the size of the random admin token
+const adminTokenBytes = 32
+
+// queueManager is the chain whose queues are managed by the operator
+type queueManager interface {
+	QueueItems() []bcommon.QueueItem
+	DropItem(id string) (string, error)
+	RequeueItem(id string) (string, error)
+	RetryItem(id string) (string, error)
+}
+
+// auditEntry records an action taken by the operator
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Remote string    `json:"remote"`
+	Chain  string    `json:"chain"`
+	ID     string    `json:"id"`
+	Action string    `json:"action"`
+	Queue  string    `json:"queue,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// adminAPI lets the operator inspect the queues of the chains and drop, requeue or retry the stuck items
+type adminAPI struct {
+	joltChain  *joltifybridge.JoltifyChainInstance
+	chains     pubChains
+	stateStore *storage.StateStore
+	// token is the bearer token every request should carry
+	token  string
+	logger zerolog.Logger
+}
+
+func newAdminAPI(joltChain *joltifybridge.JoltifyChainInstance, chains pubChains, stateStore *storage.StateStore, token string) *adminAPI {
+	return &adminAPI{
+		joltChain:  joltChain,
+		chains:     chains,
+		stateStore: stateStore,
+		token:      token,
+		logger:     log.With().Str("module", "admin").Logger(),
+	}
+}
+
+// loadAdminToken reads the admin token from the file, a random token is written to the file if it does not exist
+func loadAdminToken(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("the admin token file %v is empty", file)
+		}
+		return token, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	raw := make([]byte, adminTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	if err := ioutil.WriteFile(file, []byte(token+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// newAdminServer serves the admin api on the loopback, apart from the metrics and health endpoints that may be
+// exposed
+func newAdminServer(ctx context.Context, port int, admin *adminAPI) *JoltifyHTTPServer {
+	return &JoltifyHTTPServer{
+		logger: log.With().Str("module", "admin_http").Logger(),
+		s: &http.Server{
+			Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+			Handler: admin.handler(),
+		},
+		ctx: ctx,
+	}
+}
+
+// loopbackOnly rejects the requests that do not come from the loopback
+func loopbackOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		ip := net.ParseIP(host)
+		if err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "the admin api is only served on the loopback", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requireToken rejects the requests that do not carry the admin token, the browsers never send the authorization
+// header on their own, so a web page cannot forge the requests either
+func (a *adminAPI) requireToken(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if a.token == "" || token == auth || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// handler registers the admin routes and returns the handler of the admin server
+func (a *adminAPI) handler() http.Handler {
+	router := mux.NewRouter()
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Handle("/queues", http.HandlerFunc(a.getQueuesHandler)).Methods(http.MethodGet)
+	admin.Handle("/audit", http.HandlerFunc(a.getAuditHandler)).Methods(http.MethodGet)
+	admin.Handle("/{chain}/items/{id}/{action}", http.HandlerFunc(a.postActionHandler)).Methods(http.MethodPost)
+	admin.Use(loopbackOnly, a.requireToken)
+	router.Use(logMiddleware())
+	return router
+}
+
+// chain returns the queues of the given chain, the chain is either joltify or the ID of a public chain
+func (a *adminAPI) chain(label string) (queueManager, bool) {
+	if label == monitor.JoltifyChain {
+		return a.joltChain, a.joltChain != nil
+	}
+	chainID, err := strconv.ParseUint(label, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	pi, ok := a.chains[chainID]
+	return pi, ok
+}
+
+// queues lists the items of all the chains, keyed by the chain label
+func (a *adminAPI) queues() map[string][]bcommon.QueueItem {
+	items := make(map[string][]bcommon.QueueItem)
+	if a.joltChain != nil {
+		items[monitor.JoltifyChain] = a.joltChain.QueueItems()
+	}
+	for _, id := range a.chains.chainIDs() {
+		items[chainLabel(id)] = a.chains[id].QueueItems()
+	}
+	return items
+}
+
+// apply takes the action on the item of the chain and records it in the audit log
+func (a *adminAPI) apply(chain queueManager, remote, label, id, action string) (auditEntry, error) {
+	entry := auditEntry{Time: time.Now().UTC(), Remote: remote, Chain: label, ID: id, Action: action}
+	var err error
+	switch action {
+	case adminDrop:
+		entry.Queue, err = chain.DropItem(id)
+	case adminRequeue:
+		entry.Queue, err = chain.RequeueItem(id)
+	case adminRetry:
+		entry.Queue, err = chain.RetryItem(id)
+	default:
+		err = fmt.Errorf("unknown action %v", action)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	a.audit(entry)
+	return entry, err
+}
+
+// audit writes the entry to the log and the state store
+func (a *adminAPI) audit(entry auditEntry) {
+	a.logger.Warn().Str("remote", entry.Remote).Str("chain", entry.Chain).Str("id", entry.ID).Str("action", entry.Action).
+		Str("queue", entry.Queue).Str("error", entry.Error).Msg("admin action")
+	if a.stateStore == nil {
+		return
+	}
+	// the zero padded time keeps the entries in order in the store
+	key := fmt.Sprintf("%020d", entry.Time.UnixNano())
+	if err := a.stateStore.Put(storage.AdminAuditBucket, key, entry); err != nil {
+		a.logger.Error().Err(err).Msg("fail to persist the audit entry")
+	}
+}
+
+// auditLog returns the actions taken by the operator in order
+func (a *adminAPI) auditLog() ([]auditEntry, error) {
+	entries := []auditEntry{}
+	if a.stateStore == nil {
+		return entries, nil
+	}
+	err := a.stateStore.Iterate(storage.AdminAuditBucket, func(_ string, value []byte) error {
+		var entry auditEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// writeJSON writes the value with the given status
+func (a *adminAPI) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		a.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+// getQueuesHandler lists the queued items of all the chains
+func (a *adminAPI) getQueuesHandler(w http.ResponseWriter, _ *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.queues())
+}
+
+// getAuditHandler lists the actions taken by the operator
+func (a *adminAPI) getAuditHandler(w http.ResponseWriter, _ *http.Request) {
+	entries, err := a.auditLog()
+	if err != nil {
+		a.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.writeJSON(w, http.StatusOK, entries)
+}
+
+// postActionHandler drops, requeues or retries the item with the given ID
+func (a *adminAPI) postActionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chain, ok := a.chain(vars["chain"])
+	if !ok {
+		a.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown chain " + vars["chain"]})
+		return
+	}
+	switch vars["action"] {
+	case adminDrop, adminRequeue, adminRetry:
+	default:
+		a.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown action " + vars["action"]})
+		return
+	}
+	entry, err := a.apply(chain, r.RemoteAddr, vars["chain"], vars["id"], vars["action"])
+	status := http.StatusOK
+	if errors.Is(err, bcommon.ErrItemNotFound) {
+		status = http.StatusNotFound
+	} else if err != nil {
+		status = http.StatusConflict
+	}
+	a.writeJSON(w, status, entry)
+}
@@ -0,0 +1,155 @@
+package bridge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joltify-finance/tss/conversion"
+	"gitlab.com/joltify/joltifychain-bridge/joltifybridge"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+)
+
+// tssPeers tells how many of the pool members are connected to our tss host
+type tssPeers interface {
+	ConnectedPeers(pubKeys []string) (int, error)
+}
+
+// chainHealth is the state of one of the chains
+type chainHealth struct {
+	Chain         string  `json:"chain"`
+	Subscribed    bool    `json:"subscribed"`
+	SinceLastHead float64 `json:"since_last_head_seconds"`
+	Reconnects    int64   `json:"reconnects"`
+	RetryQueue    int     `json:"retry_queue"`
+	BroadcastTxs  int     `json:"broadcast_txs"`
+}
+
+// healthReport is the state of the bridge served by the health and readiness endpoints
+type healthReport struct {
+	Healthy           bool          `json:"healthy"`
+	Ready             bool          `json:"ready"`
+	Synced            bool          `json:"synced"`
+	InPool            bool          `json:"in_pool"`
+	TssPeers          int           `json:"tss_peers"`
+	TssPeersConnected int           `json:"tss_peers_connected"`
+	Chains            []chainHealth `json:"chains"`
+	Errors            []string      `json:"errors,omitempty"`
+}
+
+// healthChecker collects the state of the bridge, the bridge is healthy if all the chains deliver new heads, and
+// ready if it is also able to sign, that is the joltify node is synced, enough pool members are connected to our tss
+// host to sign and we are in the current pool
+type healthChecker struct {
+	joltChain  *joltifybridge.JoltifyChainInstance
+	chains     pubChains
+	subManager *joltifybridge.SubscriptionManager
+	tssServer  tssPeers
+	// started is when the bridge starts, a chain has its stall timeout from then on to deliver the first head
+	started time.Time
+}
+
+// chainState reports the chain and whether its heads are fresh, the heads are stale if none arrives in the stall
+// timeout of the chain, zero stall timeout disables the check
+func chainState(chain string, subscribed bool, lastHead, started, now time.Time, stallTimeout time.Duration) (chainHealth, []string) {
+	state := chainHealth{Chain: chain, Subscribed: subscribed, SinceLastHead: -1}
+	var errs []string
+	if !subscribed {
+		errs = append(errs, "the subscription of "+chain+" is broken")
+	}
+	if lastHead.IsZero() {
+		if stallTimeout > 0 && now.Sub(started) > stallTimeout {
+			errs = append(errs, "no head from "+chain+" since the start in "+stallTimeout.String())
+		}
+		return state, errs
+	}
+	state.SinceLastHead = now.Sub(lastHead).Seconds()
+	if stallTimeout > 0 && now.Sub(lastHead) > stallTimeout {
+		errs = append(errs, "no new head from "+chain+" in "+stallTimeout.String())
+	}
+	return state, errs
+}
+
+// liveness checks whether all the chains deliver new heads
+func (hc *healthChecker) liveness(now time.Time) healthReport {
+	var report healthReport
+	state, errs := chainState(monitor.JoltifyChain, hc.subManager.Healthy(), hc.subManager.LastBlockTime(), hc.started, now,
+		hc.subManager.StallTimeout())
+	state.Reconnects = hc.subManager.ReconnectCount()
+	state.RetryQueue = hc.joltChain.Size()
+	report.Chains = append(report.Chains, state)
+	report.Errors = append(report.Errors, errs...)
+
+	for _, id := range hc.chains.chainIDs() {
+		pi := hc.chains[id]
+		state, errs := chainState(chainLabel(id), pi.Subscribed(), pi.LastHeadTime(), hc.started, now, pi.StallTimeout())
+		state.Reconnects = pi.ReconnectCount()
+		state.RetryQueue = pi.Size()
+		state.BroadcastTxs = pi.BroadcastSize()
+		report.Chains = append(report.Chains, state)
+		report.Errors = append(report.Errors, errs...)
+	}
+	report.Healthy = len(report.Errors) == 0
+	return report
+}
+
+// readiness checks whether the bridge is healthy and able to sign
+func (hc *healthChecker) readiness(now time.Time) healthReport {
+	report := hc.liveness(now)
+	for _, el := range report.Chains {
+		if el.SinceLastHead < 0 {
+			report.Errors = append(report.Errors, "no head from "+el.Chain+" yet")
+		}
+	}
+
+	synced, err := hc.joltChain.IsSynced()
+	if err != nil {
+		report.Errors = append(report.Errors, "fail to query the joltify node: "+err.Error())
+	}
+	report.Synced = synced
+	if err == nil && !synced {
+		report.Errors = append(report.Errors, "the joltify node is syncing")
+	}
+
+	report.TssPeers, report.TssPeersConnected, err = hc.poolPeers()
+	if err != nil {
+		report.Errors = append(report.Errors, "fail to check the tss peers: "+err.Error())
+	}
+
+	pools := hc.joltChain.GetPool()
+	if pools[1] != nil {
+		report.InPool, err = hc.joltChain.CheckWhetherSigner(pools[1].PoolInfo)
+		if err != nil {
+			report.Errors = append(report.Errors, "fail to check the pool membership: "+err.Error())
+		}
+	}
+	if !report.InPool {
+		report.Errors = append(report.Errors, "we are not in the current pool")
+	}
+	report.Ready = len(report.Errors) == 0
+	return report
+}
+
+// poolPeers returns how many other members the current pool has and how many of them are connected to our tss host,
+// it fails if too few of them are connected to sign with us
+func (hc *healthChecker) poolPeers() (int, int, error) {
+	pubKeys, err := hc.joltChain.PoolMemberPubKeys()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(pubKeys) == 0 {
+		return 0, 0, nil
+	}
+	connected, err := hc.tssServer.ConnectedPeers(pubKeys)
+	if err != nil {
+		return len(pubKeys) - 1, 0, err
+	}
+	threshold, err := conversion.GetThreshold(len(pubKeys))
+	if err != nil {
+		return len(pubKeys) - 1, connected, err
+	}
+	// the keysign needs threshold+1 parties including us
+	if connected < threshold {
+		return len(pubKeys) - 1, connected, fmt.Errorf("only %v of the %v pool members are connected", connected, len(pubKeys)-1)
+	}
+	return len(pubKeys) - 1, connected, nil
+}
@@ -2,15 +2,19 @@ package bridge
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 )
 
 // JoltifyHTTPServer provide http endpoint for tss server
@@ -18,15 +22,22 @@ type JoltifyHTTPServer struct {
 	logger zerolog.Logger
 	s      *http.Server
 	peerID string
-	ctx    context.Context
+	blames *tssclient.BlameTracker
+	health *healthChecker
+	// transfers is the lifecycle of the transfers served by their source tx hash
+	transfers *monitor.TransferTracker
+	ctx       context.Context
 }
 
 // NewJoltifyHttpServer should only listen to the loopback
-func NewJoltifyHttpServer(ctx context.Context, tssAddr string, peerID string) *JoltifyHTTPServer {
+func NewJoltifyHttpServer(ctx context.Context, tssAddr string, peerID string, blames *tssclient.BlameTracker, health *healthChecker, transfers *monitor.TransferTracker) *JoltifyHTTPServer {
 	hs := &JoltifyHTTPServer{
-		logger: log.With().Str("module", "http").Logger(),
-		peerID: peerID,
-		ctx:    ctx,
+		logger:    log.With().Str("module", "http").Logger(),
+		peerID:    peerID,
+		blames:    blames,
+		health:    health,
+		transfers: transfers,
+		ctx:       ctx,
 	}
 	s := &http.Server{
 		Addr:    tssAddr,
@@ -57,10 +68,74 @@ func (t *JoltifyHTTPServer) getP2pIDHandler(w http.ResponseWriter, _ *http.Reque
 	}
 }
 
+// getBlameHandler returns the blame history of each peer, keyed by the peer pubkey
+func (t *JoltifyHTTPServer) getBlameHandler(w http.ResponseWriter, _ *http.Request) {
+	history := make(map[string][]tssclient.BlameRecord)
+	if t.blames != nil {
+		history = t.blames.History()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(history)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+// writeHealth writes the health report, the status is 503 if the check fails
+func (t *JoltifyHTTPServer) writeHealth(w http.ResponseWriter, report healthReport, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
+// getHealthHandler reports whether all the chains deliver new heads, the bridge should be restarted if it fails
+func (t *JoltifyHTTPServer) getHealthHandler(w http.ResponseWriter, _ *http.Request) {
+	report := t.health.liveness(time.Now())
+	t.writeHealth(w, report, report.Healthy)
+}
+
+// getReadyHandler reports whether the bridge is able to sign, the requests should be routed around it if it fails
+func (t *JoltifyHTTPServer) getReadyHandler(w http.ResponseWriter, _ *http.Request) {
+	report := t.health.readiness(time.Now())
+	t.writeHealth(w, report, report.Ready)
+}
+
+// getTransferHandler returns the current state and the destination tx of the transfers with the source tx hash, a tx
+// carrying several deposits has one transfer for each deposit
+func (t *JoltifyHTTPServer) getTransferHandler(w http.ResponseWriter, r *http.Request) {
+	hash := mux.Vars(r)["hash"]
+	records, err := t.transfers.Find(hash)
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		err = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	case len(records) == 0:
+		w.WriteHeader(http.StatusNotFound)
+		err = json.NewEncoder(w).Encode(map[string]string{"error": "the transfer " + hash + " is not found"})
+	default:
+		err = json.NewEncoder(w).Encode(records)
+	}
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to write to response")
+	}
+}
+
 // NewHandler registers the API routes and returns a new HTTP handler
 func (t *JoltifyHTTPServer) joltifyNewHandler() http.Handler {
 	router := mux.NewRouter()
 	router.Handle("/p2pid", http.HandlerFunc(t.getP2pIDHandler)).Methods(http.MethodGet)
+	router.Handle("/blame", http.HandlerFunc(t.getBlameHandler)).Methods(http.MethodGet)
+	router.Handle("/transfer/{hash}", http.HandlerFunc(t.getTransferHandler)).Methods(http.MethodGet)
+	if t.health != nil {
+		router.Handle("/healthz", http.HandlerFunc(t.getHealthHandler)).Methods(http.MethodGet)
+		router.Handle("/readyz", http.HandlerFunc(t.getReadyHandler)).Methods(http.MethodGet)
+	}
 	router.Handle("/metrics", promhttp.Handler())
 	router.Use(logMiddleware())
 	return router
@@ -2,17 +2,18 @@ package bridge
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html"
-	"io/ioutil"
-	"log"
 	"os"
 	"os/signal"
 	"path"
 	"sync"
 	"time"
 
+	"gitlab.com/joltify/joltifychain-bridge/common"
 	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 
 	"gitlab.com/joltify/joltifychain-bridge/config"
@@ -33,13 +34,9 @@ func NewBridgeService(config config.Config) {
 	signal.Notify(c, os.Interrupt)
 
 	passcodeLength := 32
-	passcode := make([]byte, passcodeLength)
-	n, err := os.Stdin.Read(passcode)
+	passcode, err := joltifybridge.ReadPasscode(os.Stdin, passcodeLength)
 	if err != nil {
-		return
-	}
-	if n > passcodeLength {
-		log.Fatalln("the passcode is too long")
+		zlog.Logger.Error().Err(err).Msg("fail to read the passcode")
 		return
 	}
 
@@ -48,39 +45,109 @@ func NewBridgeService(config config.Config) {
 		metrics.Enable()
 	}
 
-	// fixme, in docker it needs to be changed to basehome
-	tssServer, _, err := tssclient.StartTssServer(config.HomeDir, config.TssConfig)
+	stateStore, err := storage.NewStateStore(config.HomeDir)
 	if err != nil {
-		log.Fatalln("fail to start the tss")
+		zlog.Logger.Error().Err(err).Msg("fail to open the state store")
 		return
 	}
+	defer func() {
+		err := stateStore.Close()
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("fail to close the state store")
+		}
+	}()
 
-	joltifyBridge, err := joltifybridge.NewJoltifyBridge(config.JoltifyChain.GrpcAddress, config.JoltifyChain.WsAddress, tssServer)
+	// fixme, in docker it needs to be changed to basehome
+	tssLib, _, err := tssclient.StartTssServer(config.HomeDir, config.TssConfig)
 	if err != nil {
-		log.Fatalln("fail to create the invoice joltify_bridge", err)
+		zlog.Logger.Error().Err(err).Msg("fail to start the tss")
 		return
 	}
 
-	keyringPath := path.Join(config.HomeDir, config.KeyringAddress)
+	// the clients created so far are closed when we quit, also if we fail halfway through the start
+	var joltifyBridge *joltifybridge.JoltifyChainInstance
+	chains := make(pubChains)
+	defer func() {
+		for _, ci := range chains {
+			ci.Close()
+		}
+		// the joltify bridge stops the tss server when it terminates
+		if joltifyBridge == nil {
+			tssLib.Stop()
+			return
+		}
+		err := joltifyBridge.TerminateBridge()
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("fail to terminate the joltify bridge")
+		}
+	}()
 
-	dat, err := ioutil.ReadFile(keyringPath)
+	// we keep the blame history of the failed tss operations and leave the peers whose repeated blames are confirmed
+	// by the pool members out of the keysign
+	blameTracker, err := tssclient.NewBlameTracker(stateStore)
 	if err != nil {
-		log.Fatalln("error in read keyring file")
+		zlog.Logger.Error().Err(err).Msg("fail to load the blame history")
 		return
 	}
-
-	// fixme need to update the passcode
-	err = joltifyBridge.Keyring.ImportPrivKey("operator", string(dat), "12345678")
-	if err != nil {
+	if err := tssLib.ServeBlames(blameTracker); err != nil {
+		zlog.Logger.Error().Err(err).Msg("fail to share the blames with the pool members")
 		return
 	}
-
-	defer func() {
-		err := joltifyBridge.TerminateBridge()
+	// we only join the keysign of the txs we rebuilt from the requests we observed ourselves
+	signPolicy := tssclient.NewPolicy()
+	tssServer := tssclient.NewPolicyTssServer(tssclient.NewMetricTssServer(tssclient.NewBlameTssServer(tssLib, blameTracker), metrics), signPolicy)
+	// we record the lifecycle of each transfer, so we can tell where a transfer is by its source tx hash
+	transfers := monitor.NewTransferTracker(stateStore, config.TransferRetention)
+
+	// now we connect the public chains and monitor the transfer events on each of them
+	var registries []*common.TokenRegistry
+	for i, el := range config.PubChainList() {
+		ci, err := pubchain.NewChainInstance(el, el.TokenList(config.Params.InBoundDenom), tssServer, stateStore)
 		if err != nil {
+			fmt.Printf("fail to connect the public pub_chain with address %v: %v\n", el.WsAddress, err)
 			return
 		}
-	}()
+		chains[ci.ChainID()] = ci
+		ci.SignPolicy = signPolicy
+		ci.Metric = metrics
+		ci.Transfers = transfers
+		// the state saved when the bridge supported only one public chain belongs to the first chain
+		if i == 0 {
+			err = ci.MigrateLegacyState()
+			if err != nil {
+				fmt.Printf("fail to migrate the public chain state %v\n", err)
+				return
+			}
+		}
+		registries = append(registries, ci.Tokens())
+	}
+	tokens, err := common.NewChainRegistry(registries...)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("fail to load the token list")
+		return
+	}
+
+	joltifyBridge, err = joltifybridge.NewJoltifyBridge(config.JoltifyChain.GrpcAddress, config.JoltifyChain.WsAddress, tssServer, stateStore, tokens)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("fail to create the invoice joltify_bridge")
+		return
+	}
+
+	kr, err := joltifybridge.NewKeyring(config.KeyringBackend, config.HomeDir, passcode)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("fail to open the keyring")
+		return
+	}
+	keyringPath := path.Join(config.HomeDir, config.KeyringAddress)
+	_, err = joltifybridge.ImportOperatorKey(kr, keyringPath, config.MnemonicFile, passcode)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("fail to load the operator key")
+		return
+	}
+	joltifyBridge.Keyring = kr
+	joltifyBridge.SignPolicy = signPolicy
+	joltifyBridge.Metric = metrics
+	joltifyBridge.Transfers = transfers
 
 	err = joltifyBridge.InitValidators(config.JoltifyChain.HTTPAddress)
 	if err != nil {
@@ -88,7 +155,17 @@ func NewBridgeService(config config.Config) {
 		cancel()
 		return
 	}
-	tssHTTPServer := NewJoltifyHttpServer(ctx, config.TssConfig.HTTPAddr, joltifyBridge.GetTssNodeID())
+	// the subscription manager reconnects the joltify chain if the subscriptions are broken
+	subManager := joltifyBridge.NewSubscriptionManager(config.JoltifyChain.StallTimeout)
+	subManager.Metric = metrics
+	health := &healthChecker{
+		joltChain:  joltifyBridge,
+		chains:     chains,
+		subManager: subManager,
+		tssServer:  tssLib,
+		started:    time.Now(),
+	}
+	tssHTTPServer := NewJoltifyHttpServer(ctx, config.TssConfig.HTTPAddr, joltifyBridge.GetTssNodeID(), blameTracker, health, transfers)
 
 	wg.Add(1)
 	ret := tssHTTPServer.Start(&wg)
@@ -97,16 +174,44 @@ func NewBridgeService(config config.Config) {
 		return
 	}
 
-	// now we monitor the bsc transfer event
-	ci, err := pubchain.NewChainInstance(config.PubChainConfig.WsAddress, config.PubChainConfig.TokenAddress, tssServer)
+	// the admin api is only served on the loopback and requires the token
+	if config.AdminPort != 0 {
+		tokenFile := config.AdminTokenFile
+		if tokenFile == "" {
+			tokenFile = path.Join(config.HomeDir, "admin_token")
+		}
+		token, err := loadAdminToken(tokenFile)
+		if err != nil {
+			fmt.Printf("fail to load the admin token %v\n", err)
+			cancel()
+			return
+		}
+		adminServer := newAdminServer(ctx, config.AdminPort, newAdminAPI(joltifyBridge, chains, stateStore, token))
+		wg.Add(1)
+		if err := adminServer.Start(&wg); err != nil {
+			cancel()
+			return
+		}
+	}
+
+	// we reload the queues that were pending when the bridge stopped
+	err = joltifyBridge.RestoreState()
 	if err != nil {
-		fmt.Printf("fail to connect the public pub_chain with address %v\n", config.PubChainConfig.WsAddress)
+		fmt.Printf("fail to restore the joltify chain queues %v\n", err)
 		cancel()
 		return
 	}
+	for id, ci := range chains {
+		err = ci.RestoreState()
+		if err != nil {
+			fmt.Printf("fail to restore the queues of public chain %v %v\n", id, err)
+			cancel()
+			return
+		}
+	}
 
 	wg.Add(1)
-	addEventLoop(ctx, &wg, joltifyBridge, ci, metrics)
+	addEventLoop(ctx, &wg, joltifyBridge, subManager, chains, tokens, metrics, config.JoltifyChain, config.SubmitterTimeout)
 
 	<-c
 	ctx.Done()
@@ -115,40 +220,44 @@ func NewBridgeService(config config.Config) {
 	fmt.Printf("we quit gracefully\n")
 }
 
-func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybridge.JoltifyChainInstance, pi *pubchain.PubChainInstance, metric *monitor.Metric) {
+func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybridge.JoltifyChainInstance, subManager *joltifybridge.SubscriptionManager, chains pubChains, tokens *common.ChainRegistry, metric *monitor.Metric, joltCfg config.InvoiceChainConfig, submitterTimeout time.Duration) {
 	defer wg.Done()
-	query := "tm.event = 'ValidatorSetUpdates'"
-	ctxLocal, cancelLocal := context.WithTimeout(ctx, time.Second*5)
-	defer cancelLocal()
-
-	validatorUpdateChan, err := joltChain.AddSubscribe(ctxLocal, query)
+	err := subManager.Start(ctx, wg)
 	if err != nil {
 		fmt.Printf("fail to start the subscription")
 		return
 	}
 
-	query = "tm.event = 'NewBlock'"
-	newBlockChan, err := joltChain.AddSubscribe(ctxLocal, query)
+	// pubNewBlockChan is the channel for the new blocks of all the public chains
+	pubNewBlockChan, err := chains.subscribeHeads(ctx, wg)
 	if err != nil {
-		fmt.Printf("fail to start the subscription")
+		fmt.Printf("fail to subscribe the token transfer with err %v\n", err)
 		return
 	}
+	inboundReqChan := chains.mergeInbounds(ctx)
 
-	query = "tm.event = 'Tx'"
-
-	newJoltifyTxChan, err := joltChain.AddSubscribe(ctxLocal, query)
-	if err != nil {
-		fmt.Printf("fail to start the subscription")
-		return
+	// the inbounds of each chain are minted in batches
+	batchers := make(map[uint64]*joltifybridge.MintBatcher)
+	for id := range chains {
+		batchers[id] = joltifybridge.NewMintBatcher(joltCfg.MintBatchBlocks, joltCfg.MintBatchSize)
 	}
+	mintBatchChan := make(chan pubChainMintBatch, mintBatchQueueSize)
+	// the inbounds of the reorged blocks are cancelled in the batches waiting to be minted as well, the hook runs in
+	// the event loop as the headers are added there
+	for id, pi := range chains {
+		id, pi := id, pi
+		pi.OnReorg = func(heights map[int64]bool) {
+			for _, el := range batchers[id].RemoveFromHeights(heights) {
+				pi.ForgetInbound(el)
+			}
+			chains.cancelMintBatches(mintBatchChan, id, heights)
+		}
+	}
+	outboundBatchChan := make(chan pubChainOutboundBatch, outboundBatchQueueSize)
 
-	wg.Add(1)
-
-	// pubNewBlockChan is the channel for the new blocks for the public chain
-	pubNewBlockChan, err := pi.StartSubscription(ctx, wg)
-	if err != nil {
-		fmt.Printf("fail to subscribe the token transfer with err %v\n", err)
-		return
+	// the outbounds signed before the bridge stopped are paid again only if their txs do not pay them
+	for _, el := range joltChain.PopSentOutbounds() {
+		go resumeOutbound(ctx, joltChain, chains, tokens, metric, el)
 	}
 
 	go func() {
@@ -157,7 +266,7 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 			case <-ctx.Done():
 				return
 				// process the update of the validators
-			case vals := <-validatorUpdateChan:
+			case vals := <-subManager.ValidatorUpdateChan:
 				height, err := joltChain.GetLastBlockHeight()
 				if err != nil {
 					continue
@@ -170,7 +279,7 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 				}
 
 				// process the new joltify block, validator may need to submit the pool address
-			case block := <-newBlockChan:
+			case block := <-subManager.NewBlockChan:
 				currentBlockHeight := block.Data.(tmtypes.EventDataNewBlock).Block.Height
 				joltChain.CheckAndUpdatePool(currentBlockHeight)
 				joltChain.CurrentHeight = currentBlockHeight
@@ -186,38 +295,60 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 					continue
 				}
 
-				// now we need to put the failed inbound request to the process channel, for each new joltify block
-				// we process one failure
-				itemInbound := pi.PopItem()
-				metric.UpdateInboundTxNum(float64(pi.Size()))
-				if itemInbound != nil {
-					itemInbound.SetItemHeight(currentBlockHeight)
-					pi.InboundReqChan <- itemInbound
+				// now we need to put the failed inbound requests to the process channel, for each new joltify block
+				// we retry one batch of each public chain
+				for _, id := range chains.chainIDs() {
+					items := chains[id].PopItems(joltCfg.MintBatchSize)
+					batches := joltifybridge.NewMintBatches(currentBlockHeight, items, joltCfg.MintBatchSize)
+					for i := range batches {
+						batches[i].SeqHeight = currentBlockHeight
+					}
+					chains.queueMintBatches(mintBatchChan, id, batches)
 				}
+				metric.UpdateInboundTxNum(float64(chains.retrySize()))
 
-				currentPool := pi.GetPool()
+				// the deposits that cannot be credited are paid back as outbounds
+				for _, id := range chains.chainIDs() {
+					for _, el := range chains[id].PopRefunds() {
+						joltChain.AddRefund(el.TxID, el.To, el.Pool, el.Coin, el.Height)
+						chains[id].FinishRefund(el)
+					}
+				}
+				joltChain.Transfers.Prune(time.Now())
+
+				// all the chains share the same pools, so we take the pools of joltify as the current ones
+				currentPool := joltChain.GetPool()
 				// this means the pools has not been filled with two address
 				if currentPool[0] == nil {
 					for _, el := range poolInfo {
-						err := pi.UpdatePool(el)
-						if err != nil {
-							zlog.Log().Err(err).Msgf("fail to update the pool")
+						for _, pi := range chains {
+							err := pi.UpdatePool(el)
+							if err != nil {
+								zlog.Log().Err(err).Msgf("fail to update the pool")
+							}
 						}
 						joltChain.UpdatePool(el)
 					}
 					continue
 				}
 
+				// we scan the blocks whose outbound txs may have been missed
+				joltChain.CatchUpOutbound(currentBlockHeight, subManager.ReconnectCount())
+
 				if NeedUpdate(poolInfo, currentPool) {
-					err := pi.UpdatePool(poolInfo[0])
-					if err != nil {
-						zlog.Log().Err(err).Msgf("fail to update the pool")
+					for _, pi := range chains {
+						err := pi.UpdatePool(poolInfo[0])
+						if err != nil {
+							zlog.Log().Err(err).Msgf("fail to update the pool")
+						}
 					}
 					previousPool := joltChain.UpdatePool(poolInfo[0])
 					if previousPool.Pk != poolInfo[0].CreatePool.PoolPubKey {
 						// we force the first try of the tx to be run without blocking by the block wait
 						joltChain.AddMoveFundItem(previousPool, currentBlockHeight-config.MINCHECKBLOCKGAP+5)
-						pi.AddMoveFundItem(previousPool, pi.CurrentHeight-config.MINCHECKBLOCKGAP+5)
+						for _, pi := range chains {
+							pi.AddMoveFundItem(previousPool, pi.CurrentHeight-config.MINCHECKBLOCKGAP+5)
+						}
 					}
 				}
 
@@ -233,20 +364,24 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 					continue
 				}
 				if !isSigner {
+					joltChain.ForgetRetiredPool(previousPool)
 					continue
 				}
 				emptyAcc, err := joltChain.MoveFunds(previousPool, poolInfo[0].CreatePool.PoolAddr, currentBlockHeight)
 				if emptyAcc {
+					joltChain.ForgetRetiredPool(previousPool)
 					tick := html.UnescapeString("&#" + "127974" + ";")
 					zlog.Logger.Info().Msgf("%v successfully moved funds from %v to %v", tick, previousPool.JoltifyAddress.String(), poolInfo[0].CreatePool.PoolAddr.String())
 					continue
 				}
 				if err != nil {
 					zlog.Log().Err(err).Msgf("fail to move the fund from %v to %v", previousPool.JoltifyAddress.String(), poolInfo[1].CreatePool.PoolAddr.String())
+				} else {
+					metric.AddFundMove(monitor.JoltifyChain)
 				}
 				joltChain.AddMoveFundItem(previousPool, currentBlockHeight)
 
-			case r := <-newJoltifyTxChan:
+			case r := <-subManager.TxChan:
 				result := r.Data.(tmtypes.EventDataTx).Result
 				if result.Code != 0 {
 					// this means this tx is not a successful tx
@@ -258,23 +393,62 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 				joltChain.CheckOutBoundTx(blockHeight, tx)
 
 				// process the public chain new block event
-			case head := <-pubNewBlockChan:
-				err := pi.ProcessNewBlock(head.Number)
-				pi.CurrentHeight = head.Number.Int64()
-				if err != nil {
-					zlog.Logger.Error().Err(err).Msg("fail to process the inbound block")
+			case chainHead := <-pubNewBlockChan:
+				pi, head := chains[chainHead.chainID], chainHead.head
+				// we only process the blocks with enough confirmations
+				confirmed := pi.AddNewHeader(head)
+				for _, el := range confirmed {
+					err := pi.ProcessNewBlock(el.Hash(), joltChain.CurrentHeight)
+					if err != nil {
+						zlog.Logger.Error().Err(err).Msgf("fail to process the inbound block %v", el.Number)
+					}
+				}
+				if len(confirmed) != 0 {
+					processed := confirmed[len(confirmed)-1].Number.Int64()
+					metric.UpdateHeadLag(chainLabel(chainHead.chainID), head.Number.Int64()-processed)
+					chains.queueMintBatches(mintBatchChan, chainHead.chainID, batchers[chainHead.chainID].PopReady(processed))
 				}
+				pi.CurrentHeight = head.Number.Int64()
+				// the stuck txs are replaced in the background as the keysign takes a while
+				go pi.CheckBroadcastTxs(head.Number.Int64())
 				// we delete the expired tx
 				pi.DeleteExpired(head.Number.Uint64())
 
-				// now we need to put the failed outbound request to the process channel
+				// now we need to put the failed outbound request to the process channel, only the outbounds to this
+				// chain are signed at its height
 				// todo need to check after a given block gap
-				itemOutBound := joltChain.PopItem()
-				metric.UpdateOutboundTxNum(float64(joltChain.Size()))
-				if itemOutBound != nil {
-					itemOutBound.SetItemHeight(head.Number.Int64())
-					joltChain.OutboundReqChan <- itemOutBound
+				toThisChain := func(req *joltifybridge.OutBoundReq) bool {
+					_, _, coin, _ := req.GetOutBoundInfo()
+					token, ok := tokens.ByDenom(coin.Denom)
+					return ok && token.ChainID == chainHead.chainID
+				}
+				if batchSize := pi.OutboundBatchSize(); batchSize > 0 {
+					// the chain pays its outbounds in batches with the multisend contract
+					items := joltChain.PopItemsMatching(batchSize, toThisChain)
+					for _, el := range items {
+						el.SetItemHeight(head.Number.Int64())
+					}
+					for _, el := range groupOutbounds(chainHead.chainID, items) {
+						if len(el.items) == 1 {
+							joltChain.SendOutbound(el.items[0])
+							continue
+						}
+						select {
+						case outboundBatchChan <- el:
+						default:
+							// the event loop falls behind, the outbounds wait in the retry queue
+							for _, item := range el.items {
+								joltChain.AddItem(item)
+							}
+						}
+					}
+				} else {
+					for _, el := range joltChain.PopItemsMatching(1, toThisChain) {
+						el.SetItemHeight(head.Number.Int64())
+						joltChain.SendOutbound(el)
+					}
 				}
+				metric.UpdateOutboundTxNum(float64(joltChain.Size()))
 
 				// we move fund in the public chain
 				previousPool, _ := pi.PopMoveFundItemAfterBlock(int64(head.Number.Uint64()))
@@ -290,6 +464,7 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 					continue
 				}
 				if !isSigner {
+					pi.ForgetRetiredPool(previousPool)
 					continue
 				}
 				emptyAccount, err := pi.MoveFunds(previousPool, currentPool[1].EthAddress, head.Number.Int64())
@@ -299,74 +474,226 @@ func addEventLoop(ctx context.Context, wg *sync.WaitGroup, joltChain *joltifybri
 					continue
 				}
 				if emptyAccount {
+					pi.ForgetRetiredPool(previousPool)
 					tick := html.UnescapeString("&#" + "9989" + ";")
 					zlog.Logger.Info().Msgf("%v account %v is clear no need to move", tick, previousPool.EthAddress.String())
 					continue
 				}
 
+				metric.AddFundMove(chainLabel(chainHead.chainID))
 				// we add this account to "retry" to ensure it is the empty account in the next balance check
 				pi.AddMoveFundItem(previousPool, pi.CurrentHeight)
 
-			// process the in-bound top up event which will mint coin for users
-			case item := <-pi.InboundReqChan:
-				// first we check whether this tx has already been submitted by others
-				pools := joltChain.GetPool()
-				found, err := joltChain.CheckWhetherSigner(pools[1].PoolInfo)
+			// process the in-bound top up event which will mint coin for users, the requests wait for their batch
+			case inbound := <-inboundReqChan:
+				metric.AddInbound(chainLabel(inbound.chainID))
+				batchers[inbound.chainID].Add(inbound.item)
+
+			case mintBatch := <-mintBatchChan:
+				pi := chains[mintBatch.chainID]
+				// all the pool members sign the tx while only the elected one broadcasts it
+				submitter, err := electSubmitter(joltChain, mintBatch.batch.RequestID(), submitterTimeout)
 				if err != nil {
 					zlog.Logger.Error().Err(err).Msg("fail to check whether we are the node submit the mint request")
 					continue
 				}
-				if found {
-					txHash, index, err := joltChain.ProcessInBound(item)
+				if !submitter.IsMember() {
+					for _, el := range mintBatch.batch.Items {
+						pi.ForgetInbound(el)
+					}
+					continue
+				}
+				seqHeight := mintBatch.batch.SeqHeight
+				if seqHeight == 0 {
+					seqHeight, err = mintSeqHeight(joltChain, pi, mintBatch.batch.Height)
 					if err != nil {
-						pi.AddItem(item)
-						zlog.Logger.Error().Err(err).Msg("fail to mint the coin for the user")
+						zlog.Logger.Error().Err(err).Msgf("fail to anchor the mint batch of %v to joltify", mintBatch.batch.Height)
+						for _, el := range mintBatch.batch.Items {
+							pi.AddItem(el)
+						}
 						continue
 					}
-
-					go func() {
-						err := joltChain.CheckTxStatus(index)
+				}
+				txHash, results, err := joltChain.ProcessInBoundBatch(mintBatch.batch.Items, mintBatch.batch.Height, seqHeight, submitter)
+				if err != nil {
+					zlog.Logger.Error().Err(err).Msg("fail to mint the coin for the users")
+					metric.AddMints(monitor.MintFailed, len(mintBatch.batch.Items))
+					for _, el := range mintBatch.batch.Items {
+						joltChain.Transfers.Fail(el.SourceTx(), err)
+						pi.AddItem(el)
+					}
+					continue
+				}
+				submitted := 0
+				for _, el := range results {
+					if el.Err == nil && el.TxHash != "" {
+						submitted++
+						joltChain.Transfers.UpdateTx(el.Item.SourceTx(), sentState(submitter), el.TxHash)
+					}
+				}
+				metric.AddMints(monitor.MintSubmitted, submitted)
+				go func() {
+					for _, el := range results {
+						if el.Err != nil {
+							zlog.Logger.Error().Err(el.Err).Msgf("fail to mint the coin for the inbound %v", el.Index)
+							metric.AddMints(monitor.MintFailed, 1)
+							joltChain.Transfers.Fail(el.Item.SourceTx(), el.Err)
+							pi.AddItem(el.Item)
+							continue
+						}
+						err := joltChain.CheckTxStatus(el.Index)
 						if err != nil {
 							zlog.Logger.Error().Err(err).Msgf("the tx has not been sussfully submitted retry")
-							pi.AddItem(item)
+							metric.AddMints(monitor.MintFailed, 1)
+							joltChain.Transfers.Fail(el.Item.SourceTx(), err)
+							pi.AddItem(el.Item)
+							continue
 						}
+						metric.AddMints(monitor.MintConfirmed, 1)
+						mintHash := el.TxHash
+						if mintHash == "" {
+							// the inbound is minted by another node, we look up its tx by the issue token
+							mintHash, err = joltChain.MintTxHash(el.Index)
+							if err != nil {
+								zlog.Logger.Error().Err(err).Msgf("fail to find the tx that mints the inbound %v", el.Index)
+							}
+						}
+						joltChain.Transfers.UpdateTx(el.Item.SourceTx(), monitor.TransferConfirmed, mintHash)
+						pi.ForgetInbound(el.Item)
 						tick := html.UnescapeString("&#" + "128229" + ";")
-						zlog.Logger.Info().Msgf("%v txid(%v) have successfully top up", tick, txHash)
-					}()
+						zlog.Logger.Info().Msgf("%v txid(%v) have successfully top up the inbound %v", tick, txHash, el.Index)
+					}
+				}()
 
+			case outBatch := <-outboundBatchChan:
+				submitter, err := electSubmitter(joltChain, outBatch.requestID(), submitterTimeout)
+				if err != nil {
+					zlog.Logger.Error().Err(err).Msg("fail to check whether we are the node submit the outbound batch")
+					continue
 				}
-
-			case item := <-joltChain.OutboundReqChan:
-				pools := joltChain.GetPool()
-				found, err := joltChain.CheckWhetherSigner(pools[1].PoolInfo)
+				if !submitter.IsMember() {
+					for _, el := range outBatch.items {
+						joltChain.ForgetOutbound(el)
+					}
+					continue
+				}
+				pi := chains[outBatch.chainID]
+				fromAddr, payouts, height := outBatch.payouts()
+				// the tx hash is stored before the batch is broadcast, so we check the tx instead of paying the
+				// outbounds again if we crash in between
+				txHash, err := pi.ProcessOutBoundBatch(fromAddr, payouts, height, submitter, func(txHash string) {
+					for _, el := range outBatch.items {
+						joltChain.MarkOutboundSent(el, txHash)
+					}
+				})
 				if err != nil {
-					zlog.Logger.Error().Err(err).Msg("fail to check whether we are the node submit the mint request")
+					if !errors.Is(err, pubchain.ErrBatchNotSent) {
+						// the batch may be on the chain, the outbounds are checked again once we restart
+						zlog.Logger.Error().Err(err).Msgf("fail to pay the %v outbounds in one tx", len(payouts))
+						continue
+					}
+					// nothing of the batch is broadcast, so we fall back to the single transfers
+					zlog.Logger.Warn().Err(err).Msgf("fail to pay the %v outbounds in one tx, we pay them one by one", len(payouts))
+					go func(items []*joltifybridge.OutBoundReq) {
+						for _, el := range items {
+							joltChain.SendOutbound(el)
+						}
+					}(outBatch.items)
 					continue
 				}
-				if found {
-					toAddr, fromAddr, amount, blockHeight := item.GetOutBoundInfo()
-					txHash, err := pi.ProcessOutBound(toAddr, fromAddr, amount, blockHeight)
-					if err != nil {
-						zlog.Logger.Error().Err(err).Msg("fail to broadcast the tx")
-						joltChain.AddItem(item)
-					} else {
-						// though we submit the tx successful, we may still fail as tx may run out of gas,so we need to check
-						go func() {
-							err := pi.CheckTxStatus(txHash)
-							if err == nil {
-								tick := html.UnescapeString("&#" + "128229" + ";")
-								zlog.Logger.Info().Msgf("%v we have send outbound tx(%v) from %v to %v (%v)", tick, txHash, fromAddr, toAddr, amount.String())
+				for _, el := range outBatch.items {
+					joltChain.Transfers.UpdateTx(el.SourceTx(), sentState(submitter), txHash)
+				}
+				go func(items []*joltifybridge.OutBoundReq) {
+					// the tx may be replaced with a higher fee, so the mined tx may have another hash
+					minedHash, err := pi.CheckTxStatus(ctx, txHash)
+					if err != nil && err.Error() != "tx failed" {
+						zlog.Logger.Error().Err(err).Msgf("fail to check the status of tx %v, we do not resend it", txHash)
+						return
+					}
+					done := make([]bool, len(items))
+					if err == nil {
+						done, err = pi.PayoutResults(ctx, minedHash, fromAddr, payouts)
+						if err != nil {
+							// the outbounds are checked again once we restart if we are stopped
+							if ctx.Err() != nil {
 								return
 							}
-							if err.Error() != "tx failed" {
-								zlog.Logger.Info().Msgf("fail to check the tx status")
-								return
+							zlog.Logger.Error().Err(err).Msgf("fail to check the payouts of tx %v, we put them back to the retry queue", minedHash)
+							for _, el := range items {
+								joltChain.Transfers.Fail(el.SourceTx(), err)
+								joltChain.AddItem(el)
 							}
-
-							zlog.Logger.Warn().Msgf("the tx is fail in submission, we need to resend")
-							joltChain.AddItem(item)
-						}()
+							return
+						}
 					}
+					// the outbounds not paid by the batch are paid one by one
+					for i, el := range items {
+						if done[i] {
+							metric.AddOutbounds(monitor.OutboundConfirmed, 1)
+							joltChain.Transfers.UpdateTx(el.SourceTx(), monitor.TransferConfirmed, minedHash)
+							joltChain.ForgetOutbound(el)
+							tick := html.UnescapeString("&#" + "128229" + ";")
+							zlog.Logger.Info().Msgf("%v we have send outbound to %v (%v) in tx(%v)", tick, payouts[i].To, payouts[i].Coin.String(), minedHash)
+							continue
+						}
+						zlog.Logger.Warn().Msgf("the outbound to %v is not paid by tx %v, we resend it", payouts[i].To, txHash)
+						metric.AddOutbounds(monitor.OutboundResent, 1)
+						joltChain.Transfers.Fail(el.SourceTx(), fmt.Errorf("the outbound is not paid by tx %v", txHash))
+						joltChain.SendOutbound(el)
+					}
+				}(outBatch.items)
+
+			case item := <-joltChain.OutboundReqChan:
+				submitter, err := electSubmitter(joltChain, common.RequestID(item.Hash().Bytes()), submitterTimeout)
+				if err != nil {
+					zlog.Logger.Error().Err(err).Msg("fail to check whether we are the node submit the mint request")
+					continue
+				}
+				if !submitter.IsMember() {
+					joltChain.ForgetOutbound(item)
+					continue
+				}
+				toAddr, fromAddr, coin, blockHeight := item.GetOutBoundInfo()
+				// the denom tells which public chain the outbound goes to
+				token, ok := tokens.ByDenom(coin.Denom)
+				if !ok {
+					zlog.Logger.Error().Msgf("no public chain bridges the denom %v, drop the outbound", coin.Denom)
+					joltChain.ForgetOutbound(item)
+					continue
+				}
+				pi := chains[token.ChainID]
+				txHash, err := pi.ProcessOutBound(toAddr, fromAddr, coin, blockHeight, submitter, func(txHash string) {
+					joltChain.MarkOutboundSent(item, txHash)
+				})
+				if err != nil {
+					zlog.Logger.Error().Err(err).Msg("fail to broadcast the tx")
+					joltChain.Transfers.Fail(item.SourceTx(), err)
+					joltChain.AddItem(item)
+				} else {
+					joltChain.Transfers.UpdateTx(item.SourceTx(), sentState(submitter), txHash)
+					// though we submit the tx successful, we may still fail as tx may run out of gas,so we need to check,
+					// the tx is only resent if it is mined as failed, otherwise we may pay twice
+					go func() {
+						minedHash, err := pi.CheckTxStatus(ctx, txHash)
+						if err == nil {
+							metric.AddOutbounds(monitor.OutboundConfirmed, 1)
+							joltChain.Transfers.UpdateTx(item.SourceTx(), monitor.TransferConfirmed, minedHash)
+							joltChain.ForgetOutbound(item)
+							tick := html.UnescapeString("&#" + "128229" + ";")
+							zlog.Logger.Info().Msgf("%v we have send outbound tx(%v) from %v to %v (%v)", tick, minedHash, fromAddr, toAddr, coin.String())
+							return
+						}
+						if err.Error() != "tx failed" {
+							zlog.Logger.Error().Err(err).Msgf("fail to check the status of tx %v, we do not resend it", txHash)
+							return
+						}
+
+						zlog.Logger.Warn().Msgf("the tx is fail in submission, we need to resend")
+						metric.AddOutbounds(monitor.OutboundResent, 1)
+						joltChain.Transfers.Fail(item.SourceTx(), err)
+						joltChain.AddItem(item)
+					}()
 				}
 
 			}
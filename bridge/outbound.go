@@ -0,0 +1,146 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	zlog "github.com/rs/zerolog/log"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/joltifybridge"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/pubchain"
+)
+
+// outboundBatchQueueSize is the number of outbound batches waiting for the event loop
+const outboundBatchQueueSize = 128
+
+// pubChainOutboundBatch is a group of outbounds paid in one multisend tx on one of the public chains
+type pubChainOutboundBatch struct {
+	chainID uint64
+	items   []*joltifybridge.OutBoundReq
+}
+
+// payouts returns the pool, the payouts and the keysign height of the batch
+func (b pubChainOutboundBatch) payouts() (common.Address, []pubchain.OutboundPayout, int64) {
+	var fromAddr common.Address
+	var height int64
+	payouts := make([]pubchain.OutboundPayout, len(b.items))
+	for i, el := range b.items {
+		toAddr, from, coin, blockHeight := el.GetOutBoundInfo()
+		payouts[i] = pubchain.OutboundPayout{To: toAddr, Coin: coin}
+		fromAddr, height = from, blockHeight
+	}
+	return fromAddr, payouts, height
+}
+
+// requestID returns the ID of the batch used to elect the node that broadcasts its tx
+func (b pubChainOutboundBatch) requestID() []byte {
+	hashes := make([][]byte, len(b.items))
+	for i, el := range b.items {
+		hashes[i] = el.Hash().Bytes()
+	}
+	return bcommon.RequestID(hashes...)
+}
+
+// electSubmitter returns our role in broadcasting the tx of the request, the rank is -1 if we are not a member of
+// the current pool
+func electSubmitter(joltChain *joltifybridge.JoltifyChainInstance, requestID []byte, timeout time.Duration) (bcommon.Submitter, error) {
+	pools := joltChain.GetPool()
+	// the pools are filled in on the first joltify block
+	if pools[1] == nil || pools[1].PoolInfo == nil {
+		return bcommon.Submitter{}, errors.New("the pool is not known yet")
+	}
+	rank, err := joltChain.SubmitterRank(pools[1].PoolInfo, requestID)
+	if err != nil {
+		return bcommon.Submitter{}, err
+	}
+	return bcommon.Submitter{Rank: rank, Timeout: timeout}, nil
+}
+
+// sentState is the state of the transfer once we have signed its tx, only the leader broadcasts the tx at once
+func sentState(submitter bcommon.Submitter) string {
+	if submitter.IsLeader() {
+		return monitor.TransferBroadcast
+	}
+	return monitor.TransferSigned
+}
+
+// groupOutbounds groups the outbounds by the pool and the denom as one multisend tx pays one token from one pool,
+// the order of the items is kept so all the nodes build the same txs
+func groupOutbounds(chainID uint64, items []*joltifybridge.OutBoundReq) []pubChainOutboundBatch {
+	groups := make(map[string]*pubChainOutboundBatch)
+	var keys []string
+	for _, el := range items {
+		_, from, coin, _ := el.GetOutBoundInfo()
+		key := from.Hex() + "/" + coin.Denom
+		group, ok := groups[key]
+		if !ok {
+			group = &pubChainOutboundBatch{chainID: chainID}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.items = append(group.items, el)
+	}
+	sort.Strings(keys)
+	batches := make([]pubChainOutboundBatch, len(keys))
+	for i, key := range keys {
+		batches[i] = *groups[key]
+	}
+	return batches
+}
+
+// resumeOutbound checks the tx signed for the outbound before the bridge stopped, the outbound goes back to the retry
+// queue only if the tx never reached the chain or the mined tx does not pay it
+func resumeOutbound(ctx context.Context, joltChain *joltifybridge.JoltifyChainInstance, chains pubChains, tokens *bcommon.ChainRegistry, metric *monitor.Metric, sent joltifybridge.SentOutbound) {
+	toAddr, fromAddr, coin, _ := sent.Req.GetOutBoundInfo()
+	token, ok := tokens.ByDenom(coin.Denom)
+	if !ok {
+		zlog.Logger.Error().Msgf("no public chain bridges the denom %v, drop the outbound", coin.Denom)
+		joltChain.ForgetOutbound(sent.Req)
+		return
+	}
+	pi := chains[token.ChainID]
+	// the tx hash is stored before the tx is broadcast, the outbound is paid again if the tx never reached the chain
+	known, err := pi.KnownTx(ctx, sent.TxHash)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msgf("fail to query the tx %v, we do not resend it", sent.TxHash)
+		return
+	}
+	if !known {
+		zlog.Logger.Warn().Msgf("the tx %v of the outbound to %v is not broadcast, we resend it", sent.TxHash, toAddr)
+		joltChain.AddItem(sent.Req)
+		return
+	}
+	minedHash, err := pi.CheckTxStatus(ctx, sent.TxHash)
+	if err != nil && err.Error() != "tx failed" {
+		zlog.Logger.Error().Err(err).Msgf("fail to check the status of tx %v, we do not resend it", sent.TxHash)
+		return
+	}
+	if err == nil {
+		done, err := pi.PayoutResults(ctx, minedHash, fromAddr, []pubchain.OutboundPayout{{To: toAddr, Coin: coin}})
+		if err != nil {
+			// the outbound is checked again once we restart if we are stopped
+			if ctx.Err() != nil {
+				return
+			}
+			zlog.Logger.Error().Err(err).Msgf("fail to check the payouts of tx %v, we put it back to the retry queue", minedHash)
+			joltChain.Transfers.Fail(sent.Req.SourceTx(), err)
+			joltChain.AddItem(sent.Req)
+			return
+		}
+		if done[0] {
+			metric.AddOutbounds(monitor.OutboundConfirmed, 1)
+			joltChain.Transfers.UpdateTx(sent.Req.SourceTx(), monitor.TransferConfirmed, minedHash)
+			joltChain.ForgetOutbound(sent.Req)
+			return
+		}
+	}
+	zlog.Logger.Warn().Msgf("the outbound to %v is not paid by tx %v, we resend it", toAddr, sent.TxHash)
+	metric.AddOutbounds(monitor.OutboundResent, 1)
+	joltChain.Transfers.Fail(sent.Req.SourceTx(), fmt.Errorf("the outbound is not paid by tx %v", sent.TxHash))
+	joltChain.AddItem(sent.Req)
+}
@@ -0,0 +1,168 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"gitlab.com/joltify/joltifychain-bridge/joltifybridge"
+	"gitlab.com/joltify/joltifychain-bridge/pubchain"
+)
+
+// mintBatchQueueSize is the number of mint batches waiting for the event loop
+const mintBatchQueueSize = 128
+
+// pubChains are the public chain instances keyed by the chain ID
+type pubChains map[uint64]*pubchain.PubChainInstance
+
+// pubChainHead is a new head of one of the public chains
+type pubChainHead struct {
+	chainID uint64
+	head    *ethTypes.Header
+}
+
+// pubChainInbound is an inbound request from one of the public chains
+type pubChainInbound struct {
+	chainID uint64
+	item    *pubchain.InboundReq
+}
+
+// pubChainMintBatch is a batch of inbound requests from one of the public chains
+type pubChainMintBatch struct {
+	chainID uint64
+	batch   joltifybridge.MintBatch
+}
+
+// chainLabel is the metric label of the public chain
+func chainLabel(chainID uint64) string {
+	return strconv.FormatUint(chainID, 10)
+}
+
+// queueMintBatches hands the batches to the event loop, the requests go back to the retry queue of the chain if the
+// event loop falls behind
+func (p pubChains) queueMintBatches(queue chan pubChainMintBatch, chainID uint64, batches []joltifybridge.MintBatch) {
+	for _, el := range batches {
+		select {
+		case queue <- pubChainMintBatch{chainID: chainID, batch: el}:
+		default:
+			for _, item := range el.Items {
+				p[chainID].AddItem(item)
+			}
+		}
+	}
+}
+
+// mintSeqHeight returns the joltify height the sequence of the mint tx of the window ending at the public chain height
+// is derived from, it is the last joltify block before the block ending the window, so all the nodes pick the same one
+func mintSeqHeight(joltChain *joltifybridge.JoltifyChainInstance, pi *pubchain.PubChainInstance, height int64) (int64, error) {
+	blockTime, err := pi.BlockTime(height)
+	if err != nil {
+		return 0, err
+	}
+	return joltChain.HeightAt(blockTime)
+}
+
+// cancelMintBatches removes the requests derived from the reorged blocks of the chain from the queued batches
+func (p pubChains) cancelMintBatches(queue chan pubChainMintBatch, chainID uint64, heights map[int64]bool) {
+	var remains []pubChainMintBatch
+drain:
+	for {
+		select {
+		case el := <-queue:
+			remains = append(remains, el)
+		default:
+			break drain
+		}
+	}
+	for _, el := range remains {
+		if el.chainID == chainID {
+			var kept []*pubchain.InboundReq
+			for _, item := range el.batch.Items {
+				if item.FromHeights(heights) {
+					p[chainID].ForgetInbound(item)
+					continue
+				}
+				kept = append(kept, item)
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			el.batch.Items = kept
+		}
+		queue <- el
+	}
+}
+
+// chainIDs returns the IDs of the chains in order, so we always walk through the chains in the same way
+func (p pubChains) chainIDs() []uint64 {
+	ids := make([]uint64, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// retrySize returns the number of inbound requests waiting for retry on all the chains
+func (p pubChains) retrySize() int {
+	size := 0
+	for _, pi := range p {
+		size += pi.Size()
+	}
+	return size
+}
+
+// subscribeHeads subscribes the new heads of all the chains and fans them in to one channel
+func (p pubChains) subscribeHeads(ctx context.Context, wg *sync.WaitGroup) (chan pubChainHead, error) {
+	out := make(chan pubChainHead)
+	for _, id := range p.chainIDs() {
+		wg.Add(1)
+		heads, err := p[id].StartSubscription(ctx, wg)
+		if err != nil {
+			wg.Done()
+			return nil, fmt.Errorf("fail to subscribe the chain %v with err %w", id, err)
+		}
+		go func(chainID uint64) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case head := <-heads:
+					select {
+					case out <- pubChainHead{chainID, head}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}(id)
+	}
+	return out, nil
+}
+
+// mergeInbounds fans in the inbound requests of all the chains to one channel
+func (p pubChains) mergeInbounds(ctx context.Context) chan pubChainInbound {
+	out := make(chan pubChainInbound)
+	for id, pi := range p {
+		go func(chainID uint64, in chan *pubchain.InboundReq) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item := <-in:
+					select {
+					case out <- pubChainInbound{chainID, item}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}(id, pi.InboundReqChan)
+	}
+	return out
+}
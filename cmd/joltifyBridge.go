@@ -1,6 +1,9 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	golog "github.com/ipfs/go-log"
 	"github.com/joltify-finance/tss/common"
 	"github.com/rs/zerolog"
@@ -12,11 +15,15 @@ import (
 func main() {
 	misc.SetupBech32Prefix()
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	config := config.DefaultConfig()
-	err := golog.SetLogLevel("tss-lib", "INFO")
+	cfg, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	config.ApplyParams(cfg.Params)
+	err = golog.SetLogLevel("tss-lib", "INFO")
 	if err != nil {
 		panic(err)
 	}
 	common.InitLog("info", true, "joltifyBridge_service")
-	bridge.NewBridgeService(config)
+	bridge.NewBridgeService(cfg)
 }
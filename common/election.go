@@ -0,0 +1,61 @@
+package common
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Submitter is the role of this node in broadcasting the tx of a request, all the pool members sign the tx while
+// only the leader (rank 0) broadcasts it at once, the member of rank k broadcasts it if the tx is still not seen
+// after k timeouts
+type Submitter struct {
+	Rank    int
+	Timeout time.Duration
+}
+
+// IsMember returns true if the node is one of the pool members
+func (s Submitter) IsMember() bool {
+	return s.Rank >= 0
+}
+
+// IsLeader returns true if the node broadcasts the tx at once
+func (s Submitter) IsLeader() bool {
+	return s.Rank == 0
+}
+
+// Delay returns how long we wait for the others before we broadcast the tx ourselves
+func (s Submitter) Delay() time.Duration {
+	return time.Duration(s.Rank) * s.Timeout
+}
+
+// RequestID builds the election ID of a request made of the given items, e.g. the hashes of the batched requests
+func RequestID(items ...[]byte) []byte {
+	return crypto.Keccak256(items...)
+}
+
+// SubmitterRank orders the members by the hash of the request ID and the member address, so every node agrees on
+// the order while the leader changes from request to request, it returns the rank of the node or -1 if the node is
+// not a member
+func SubmitterRank(requestID []byte, members []types.AccAddress, node types.AccAddress) int {
+	type scored struct {
+		addr  types.AccAddress
+		score []byte
+	}
+	ordered := make([]scored, len(members))
+	for i, el := range members {
+		ordered[i] = scored{addr: el, score: crypto.Keccak256(requestID, el.Bytes())}
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return bytes.Compare(ordered[i].score, ordered[j].score) == -1
+	})
+	for i, el := range ordered {
+		if el.addr.Equals(node) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitterRank(t *testing.T) {
+	members := []types.AccAddress{{1}, {2}, {3}, {4}}
+	reversed := []types.AccAddress{members[3], members[2], members[1], members[0]}
+
+	leaders := make(map[string]bool)
+	for i := byte(0); i < 20; i++ {
+		requestID := RequestID([]byte{i})
+		ranks := make(map[int]bool)
+		for _, el := range members {
+			rank := SubmitterRank(requestID, members, el)
+			// the order of the members does not change the ranks
+			require.Equal(t, rank, SubmitterRank(requestID, reversed, el))
+			ranks[rank] = true
+			if rank == 0 {
+				leaders[el.String()] = true
+			}
+		}
+		require.Len(t, ranks, len(members))
+		require.Equal(t, -1, SubmitterRank(requestID, members, types.AccAddress{5}))
+	}
+	// the leader changes from request to request
+	require.Greater(t, len(leaders), 1)
+
+	s := Submitter{Rank: 2, Timeout: time.Second}
+	require.True(t, s.IsMember())
+	require.False(t, s.IsLeader())
+	require.Equal(t, 2*time.Second, s.Delay())
+	require.False(t, Submitter{Rank: -1}.IsMember())
+	require.Equal(t, time.Duration(0), Submitter{}.Delay())
+}
@@ -0,0 +1,25 @@
+package common
+
+import "errors"
+
+// the queues of the bridge shown to the operator
+const (
+	PendingInboundQueue    = "pending_inbound"
+	PendingInboundBnBQueue = "pending_inbound_bnb"
+	RetryInboundQueue      = "retry_inbound"
+	RetryOutboundQueue     = "retry_outbound"
+	DroppedQueue           = "dropped"
+	MoveFundQueue          = "move_fund"
+)
+
+// ErrItemNotFound is returned if no queue holds the item
+var ErrItemNotFound = errors.New("the item is not found in the queues")
+
+// QueueItem is an item waiting in one of the bridge queues, the ID is the hash of the request, the tx ID of the
+// pending inbound or the address of the retired pool
+type QueueItem struct {
+	Queue  string      `json:"queue"`
+	ID     string      `json:"id"`
+	Height int64       `json:"height"`
+	Item   interface{} `json:"item"`
+}
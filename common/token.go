@@ -0,0 +1,197 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+)
+
+// JoltifyDecimals is the decimals of the bridged tokens on joltify
+const JoltifyDecimals = 18
+
+// TokenInfo maps an ERC20 token on the public chain to its denom on joltify
+type TokenInfo struct {
+	ChainID  uint64
+	Address  common.Address
+	Denom    string
+	Decimals uint8
+}
+
+// ToJoltify converts the amount of the public chain token to the amount on joltify
+func (t *TokenInfo) ToJoltify(amount *big.Int) types.Int {
+	return types.NewIntFromBigInt(scaleAmount(amount, int(t.Decimals), JoltifyDecimals))
+}
+
+// ToPubChain converts the amount on joltify to the amount of the public chain token, it fails if the token cannot
+// represent the amount exactly, so no fraction is dropped silently
+func (t *TokenInfo) ToPubChain(amount types.Int) (*big.Int, error) {
+	scaled := scaleAmount(amount.BigInt(), JoltifyDecimals, int(t.Decimals))
+	if scaleAmount(scaled, int(t.Decimals), JoltifyDecimals).Cmp(amount.BigInt()) != 0 {
+		return nil, fmt.Errorf("the amount %v%v cannot be represented with %v decimals", amount, t.Denom, t.Decimals)
+	}
+	return scaled, nil
+}
+
+func scaleAmount(amount *big.Int, from, to int) *big.Int {
+	switch {
+	case from == to:
+		return new(big.Int).Set(amount)
+	case from < to:
+		factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(to-from)), nil)
+		return new(big.Int).Mul(amount, factor)
+	default:
+		factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(from-to)), nil)
+		return new(big.Int).Quo(amount, factor)
+	}
+}
+
+// TokenRegistry is the list of the tokens supported by the bridge on one public chain
+type TokenRegistry struct {
+	chainID uint64
+	tokens  []*TokenInfo
+	byAddr  map[common.Address]*TokenInfo
+	byDenom map[string]*TokenInfo
+	// outboundFee is the minimum fee paid on joltify by the outbounds to the chain
+	outboundFee *types.Coin
+}
+
+// NewTokenRegistry creates the registry of the given chain from the token configs
+func NewTokenRegistry(chainID uint64, tokens []config.TokenConfig) (*TokenRegistry, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("no token is configured")
+	}
+	r := TokenRegistry{
+		chainID: chainID,
+		byAddr:  make(map[common.Address]*TokenInfo),
+		byDenom: make(map[string]*TokenInfo),
+	}
+	for _, el := range tokens {
+		if !common.IsHexAddress(el.Address) {
+			return nil, fmt.Errorf("invalid token address %v", el.Address)
+		}
+		token := TokenInfo{
+			ChainID:  chainID,
+			Address:  common.HexToAddress(el.Address),
+			Denom:    el.Denom,
+			Decimals: el.Decimals,
+		}
+		if _, ok := r.byAddr[token.Address]; ok {
+			return nil, fmt.Errorf("duplicated token address %v", el.Address)
+		}
+		if _, ok := r.byDenom[token.Denom]; ok {
+			return nil, fmt.Errorf("duplicated token denom %v", el.Denom)
+		}
+		r.tokens = append(r.tokens, &token)
+		r.byAddr[token.Address] = &token
+		r.byDenom[token.Denom] = &token
+	}
+	return &r, nil
+}
+
+// ChainID returns the ID of the public chain the tokens live on
+func (r *TokenRegistry) ChainID() uint64 {
+	return r.chainID
+}
+
+// ByAddress returns the token of the given contract address
+func (r *TokenRegistry) ByAddress(addr common.Address) (*TokenInfo, bool) {
+	token, ok := r.byAddr[addr]
+	return token, ok
+}
+
+// ByDenom returns the token of the given joltify denom
+func (r *TokenRegistry) ByDenom(denom string) (*TokenInfo, bool) {
+	token, ok := r.byDenom[denom]
+	return token, ok
+}
+
+// Tokens returns all the tokens in the registry
+func (r *TokenRegistry) Tokens() []*TokenInfo {
+	return r.tokens
+}
+
+// Addresses returns the contract addresses of all the tokens
+func (r *TokenRegistry) Addresses() []common.Address {
+	addresses := make([]common.Address, len(r.tokens))
+	for i, el := range r.tokens {
+		addresses[i] = el.Address
+	}
+	return addresses
+}
+
+// SetOutboundFee sets the denom and the minimum of the fee paid on joltify by the outbounds to the chain
+func (r *TokenRegistry) SetOutboundFee(denom, minFee string) error {
+	if err := types.ValidateDenom(denom); err != nil {
+		return fmt.Errorf("invalid outbound fee denom %v", denom)
+	}
+	amount, err := types.NewDecFromStr(minFee)
+	if err != nil || amount.IsNegative() {
+		return fmt.Errorf("invalid minimal outbound fee %v", minFee)
+	}
+	fee := types.NewCoin(denom, types.NewIntFromBigInt(amount.BigInt()))
+	r.outboundFee = &fee
+	return nil
+}
+
+// OutboundFee returns the minimum fee paid on joltify by the outbounds to the chain, it returns false if no fee is set
+// so that no outbound is accepted for the chain
+func (r *TokenRegistry) OutboundFee() (types.Coin, bool) {
+	if r.outboundFee == nil {
+		return types.Coin{}, false
+	}
+	return *r.outboundFee, true
+}
+
+// ChainRegistry holds the tokens of all the public chains, a denom belongs to the token of one chain only
+// so that we know where to send the outbound
+type ChainRegistry struct {
+	chains  []*TokenRegistry
+	byChain map[uint64]*TokenRegistry
+	byDenom map[string]*TokenInfo
+}
+
+// NewChainRegistry creates the registry from the token registries of the public chains
+func NewChainRegistry(registries ...*TokenRegistry) (*ChainRegistry, error) {
+	if len(registries) == 0 {
+		return nil, errors.New("no public chain is configured")
+	}
+	c := ChainRegistry{
+		byChain: make(map[uint64]*TokenRegistry),
+		byDenom: make(map[string]*TokenInfo),
+	}
+	for _, r := range registries {
+		if _, ok := c.byChain[r.chainID]; ok {
+			return nil, fmt.Errorf("duplicated chain %v", r.chainID)
+		}
+		for _, el := range r.tokens {
+			if _, ok := c.byDenom[el.Denom]; ok {
+				return nil, fmt.Errorf("the denom %v is bridged on more than one chain", el.Denom)
+			}
+			c.byDenom[el.Denom] = el
+		}
+		c.chains = append(c.chains, r)
+		c.byChain[r.chainID] = r
+	}
+	return &c, nil
+}
+
+// ByDenom returns the token of the given joltify denom on any of the chains
+func (c *ChainRegistry) ByDenom(denom string) (*TokenInfo, bool) {
+	token, ok := c.byDenom[denom]
+	return token, ok
+}
+
+// Chain returns the tokens of the given chain
+func (c *ChainRegistry) Chain(chainID uint64) (*TokenRegistry, bool) {
+	r, ok := c.byChain[chainID]
+	return r, ok
+}
+
+// Chains returns the token registries of all the chains
+func (c *ChainRegistry) Chains() []*TokenRegistry {
+	return c.chains
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+)
+
+func TestTokenRegistry(t *testing.T) {
+	usdt := "0x33875278f7757f6b43abC223EeC4a9D6204186a0"
+	jusd := "0xeB42ff4cA651c91EB248f8923358b6144c6B4b79"
+	tokens, err := NewTokenRegistry(56, []config.TokenConfig{
+		{Address: jusd, Denom: "JUSD", Decimals: 18},
+		{Address: usdt, Denom: "JUSDT", Decimals: 6},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{common.HexToAddress(jusd), common.HexToAddress(usdt)}, tokens.Addresses())
+
+	token, ok := tokens.ByDenom("JUSDT")
+	require.True(t, ok)
+	require.Equal(t, common.HexToAddress(usdt), token.Address)
+	_, ok = tokens.ByDenom("jusdt")
+	require.False(t, ok)
+	token, ok = tokens.ByAddress(common.HexToAddress(jusd))
+	require.True(t, ok)
+	require.Equal(t, "JUSD", token.Denom)
+	require.Equal(t, uint64(56), token.ChainID)
+
+	// no outbound is accepted until the fee of the chain is set
+	_, ok = tokens.OutboundFee()
+	require.False(t, ok)
+	require.Error(t, tokens.SetOutboundFee("JOLT", "-1"))
+	require.Error(t, tokens.SetOutboundFee("", "1"))
+	require.NoError(t, tokens.SetOutboundFee("JOLT", "0.00000000000000001"))
+	fee, ok := tokens.OutboundFee()
+	require.True(t, ok)
+	require.Equal(t, types.NewCoin("JOLT", types.NewInt(10)), fee)
+
+	_, err = NewTokenRegistry(56, nil)
+	require.Error(t, err)
+	_, err = NewTokenRegistry(56, []config.TokenConfig{{Address: "0x123", Denom: "JUSD", Decimals: 18}})
+	require.Error(t, err)
+	_, err = NewTokenRegistry(56, []config.TokenConfig{{Address: jusd, Denom: "JUSD", Decimals: 18}, {Address: usdt, Denom: "JUSD", Decimals: 6}})
+	require.Error(t, err)
+	_, err = NewTokenRegistry(56, []config.TokenConfig{{Address: jusd, Denom: "JUSD", Decimals: 18}, {Address: jusd, Denom: "JUSDT", Decimals: 6}})
+	require.Error(t, err)
+}
+
+func TestChainRegistry(t *testing.T) {
+	usdt := "0x33875278f7757f6b43abC223EeC4a9D6204186a0"
+	bsc, err := NewTokenRegistry(56, []config.TokenConfig{{Address: usdt, Denom: "JUSDT", Decimals: 18}})
+	require.NoError(t, err)
+	// the same contract address is fine on another chain
+	eth, err := NewTokenRegistry(1, []config.TokenConfig{{Address: usdt, Denom: "EUSDT", Decimals: 6}})
+	require.NoError(t, err)
+
+	chains, err := NewChainRegistry(bsc, eth)
+	require.NoError(t, err)
+	require.Len(t, chains.Chains(), 2)
+	token, ok := chains.ByDenom("EUSDT")
+	require.True(t, ok)
+	require.Equal(t, uint64(1), token.ChainID)
+	r, ok := chains.Chain(56)
+	require.True(t, ok)
+	require.Equal(t, bsc, r)
+	_, ok = chains.Chain(97)
+	require.False(t, ok)
+
+	_, err = NewChainRegistry()
+	require.Error(t, err)
+	_, err = NewChainRegistry(bsc, bsc)
+	require.Error(t, err)
+	other, err := NewTokenRegistry(97, []config.TokenConfig{{Address: usdt, Denom: "JUSDT", Decimals: 18}})
+	require.NoError(t, err)
+	_, err = NewChainRegistry(bsc, other)
+	require.Error(t, err)
+}
+
+func TestTokenAmount(t *testing.T) {
+	usdt := TokenInfo{Denom: "JUSDT", Decimals: 6}
+	require.Equal(t, "1000000000000", usdt.ToJoltify(big.NewInt(1)).String())
+	amount, err := usdt.ToPubChain(types.NewInt(3000000000000))
+	require.NoError(t, err)
+	require.Equal(t, "3", amount.String())
+	// the dust that cannot be represented by the token is rejected
+	_, err = usdt.ToPubChain(types.NewInt(2999999999999))
+	require.Error(t, err)
+
+	jusd := TokenInfo{Decimals: 18}
+	require.Equal(t, "123", jusd.ToJoltify(big.NewInt(123)).String())
+	amount, err = jusd.ToPubChain(types.NewInt(123))
+	require.NoError(t, err)
+	require.Equal(t, "123", amount.String())
+
+	large := TokenInfo{Decimals: 20}
+	require.Equal(t, "1", large.ToJoltify(big.NewInt(100)).String())
+	amount, err = large.ToPubChain(types.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, "100", amount.String())
+}
@@ -2,43 +2,136 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	maddr "github.com/multiformats/go-multiaddr"
+	"gopkg.in/yaml.v3"
 )
 
 type InvoiceChainConfig struct {
-	GrpcAddress string
-	WsAddress   string
-	WsEndpoint  string
-	HTTPAddress string
+	GrpcAddress string `yaml:"grpc_address"`
+	WsAddress   string `yaml:"ws_address"`
+	WsEndpoint  string `yaml:"ws_endpoint"`
+	HTTPAddress string `yaml:"http_address"`
+	// StallTimeout defines how long we wait for a new block before we reconnect the joltify chain
+	StallTimeout time.Duration `yaml:"stall_timeout"`
+	// MintBatchBlocks defines how many public chain blocks of inbounds we mint in one tx
+	MintBatchBlocks int64 `yaml:"mint_batch_blocks"`
+	// MintBatchSize is the max number of mints in one tx
+	MintBatchSize int `yaml:"mint_batch_size"`
 }
 
 type PubChainConfig struct {
-	WsAddress    string
-	TokenAddress string
+	// ChainID is the EVM chain ID of the public chain, 0 means we take the one reported by the node
+	ChainID      uint64 `yaml:"chain_id"`
+	WsAddress    string `yaml:"ws_address"`
+	TokenAddress string `yaml:"token_address"`
+	// MaxLookBack defines how many blocks we replay at most after the bridge restarts
+	MaxLookBack int64 `yaml:"max_lookback"`
+	// ConfirmationDepth defines how many blocks we wait before we process an inbound block
+	ConfirmationDepth int64 `yaml:"confirmation_depth"`
+	// StallTimeout defines how long we wait for a new block before we reconnect the public chain
+	StallTimeout time.Duration `yaml:"stall_timeout"`
+	// Tokens is the list of the bridged tokens, if it is empty we bridge TokenAddress as the inbound denom
+	Tokens tokenList `yaml:"tokens"`
+	// LegacyTx forces the legacy gas price txs even if the chain supports the dynamic fee (EIP-1559) txs
+	LegacyTx bool `yaml:"legacy_tx"`
+	// GasTipCap is the priority fee in wei of the dynamic fee txs, all the pool members must use the same one
+	GasTipCap uint64 `yaml:"gas_tip_cap"`
+	// MaxGasFeeCap is the upper bound in wei of the fee cap of the dynamic fee txs, 0 means no bound
+	MaxGasFeeCap uint64 `yaml:"max_gas_fee_cap"`
+	// StuckTxBlocks defines how many blocks a broadcast tx may stay pending before we replace it with a higher fee
+	StuckTxBlocks int64 `yaml:"stuck_tx_blocks"`
+	// MultisendAddress is the disperse contract that pays the outbounds in batches, the batch mode is off if it is empty
+	MultisendAddress string `yaml:"multisend_address"`
+	// OutboundBatchSize is the max number of outbounds paid in one multisend tx
+	OutboundBatchSize int `yaml:"outbound_batch_size"`
+	// OutboundFeeDenom is the denom of the fee paid on joltify by the outbounds to this chain
+	OutboundFeeDenom string `yaml:"outbound_fee_denom"`
+	// OutboundFeeMin is the minimum fee of the outbounds to this chain
+	OutboundFeeMin string `yaml:"outbound_fee_min"`
+}
+
+// TokenConfig maps an ERC20 token on the public chain to its denom on joltify
+type TokenConfig struct {
+	Address  string `yaml:"address"`
+	Denom    string `yaml:"denom"`
+	Decimals uint8  `yaml:"decimals"`
+}
+
+type tokenList []TokenConfig
+
+// String implement fmt.Stringer
+func (tl *tokenList) String() string {
+	tokens := make([]string, len(*tl))
+	for i, el := range *tl {
+		tokens[i] = fmt.Sprintf("%v:%v:%v", el.Address, el.Denom, el.Decimals)
+	}
+	return strings.Join(tokens, ",")
+}
+
+// Set parses the comma separated tokens in the form of address:denom:decimals
+func (tl *tokenList) Set(value string) error {
+	*tl = nil
+	for _, el := range strings.Split(value, ",") {
+		fields := strings.Split(strings.TrimSpace(el), ":")
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid token %q, it should be address:denom:decimals", el)
+		}
+		decimals, err := strconv.ParseUint(fields[2], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid decimals of token %q", el)
+		}
+		*tl = append(*tl, TokenConfig{Address: fields[0], Denom: fields[1], Decimals: uint8(decimals)})
+	}
+	return nil
+}
+
+// TokenList returns the bridged tokens, the single TokenAddress is used if no token is configured
+func (c PubChainConfig) TokenList(defaultDenom string) []TokenConfig {
+	if len(c.Tokens) != 0 {
+		return c.Tokens
+	}
+	return []TokenConfig{{Address: c.TokenAddress, Denom: defaultDenom, Decimals: 18}}
+}
+
+// BridgeParams defines the denoms, fees and block gaps used by the bridge
+type BridgeParams struct {
+	InBoundDenomFee string `yaml:"inbound_denom_fee"`
+	InBoundFeeMin   string `yaml:"inbound_fee_min"`
+	InBoundDenom    string `yaml:"inbound_denom"`
+	OutBoundDenom   string `yaml:"outbound_denom"`
+	// TxTimeout defines how many public chain blocks we keep the pending inbound tx
+	TxTimeout uint64 `yaml:"tx_timeout"`
+	// GasFeeRatio is the ratio applied to the estimated gas
+	GasFeeRatio string `yaml:"gas_fee_ratio"`
+	DustBNB     string `yaml:"dust_bnb"`
+	// MinCheckBlockGap defines how many blocks we wait before we move the fund of the retired pool
+	MinCheckBlockGap int64 `yaml:"min_check_block_gap"`
 }
 
 type (
 	addrList  []maddr.Multiaddr
 	TssConfig struct {
 		// Party Timeout defines how long do we wait for the party to form
-		PartyTimeout time.Duration
+		PartyTimeout time.Duration `yaml:"party_timeout"`
 		// KeyGenTimeoutSeconds defines how long do we wait the keygen parties to pass messages along
-		KeyGenTimeout time.Duration
+		KeyGenTimeout time.Duration `yaml:"keygen_timeout"`
 		// KeySignTimeoutSeconds defines how long do we wait keysign
-		KeySignTimeout time.Duration
+		KeySignTimeout time.Duration `yaml:"keysign_timeout"`
 		// Pre-parameter define the pre-parameter generations timeout
-		PreParamTimeout time.Duration
+		PreParamTimeout time.Duration `yaml:"preparam_timeout"`
 		// enable the tss monitor
 
 		// Config is configuration for Tss P2P
-		RendezvousString string
-		Port             int
-		BootstrapPeers   addrList
-		ExternalIP       string
-		HTTPAddr         string
+		RendezvousString string   `yaml:"rendezvous"`
+		Port             int      `yaml:"p2p_port"`
+		BootstrapPeers   addrList `yaml:"peers"`
+		ExternalIP       string   `yaml:"external_ip"`
+		HTTPAddr         string   `yaml:"http_addr"`
 	}
 )
 
@@ -51,52 +144,170 @@ func (al *addrList) String() string {
 	return strings.Join(addresses, ",")
 }
 
-// Set add the given value to addList
+// Set add the given value to addList, the value can be a comma separated list
 func (al *addrList) Set(value string) error {
-	addr, err := maddr.NewMultiaddr(value)
-	if err != nil {
+	for _, el := range strings.Split(value, ",") {
+		el = strings.TrimSpace(el)
+		if el == "" {
+			continue
+		}
+		addr, err := maddr.NewMultiaddr(el)
+		if err != nil {
+			return err
+		}
+		*al = append(*al, addr)
+	}
+	return nil
+}
+
+// UnmarshalYAML reads the address list from a list of strings in the config file
+func (al *addrList) UnmarshalYAML(value *yaml.Node) error {
+	var addresses []string
+	if err := value.Decode(&addresses); err != nil {
 		return err
 	}
-	*al = append(*al, addr)
+	*al = nil
+	for _, el := range addresses {
+		if err := al.Set(el); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// MarshalYAML writes the address list as a list of strings
+func (al addrList) MarshalYAML() (interface{}, error) {
+	addresses := make([]string, len(al))
+	for i, addr := range al {
+		addresses[i] = addr.String()
+	}
+	return addresses, nil
+}
+
 type Config struct {
-	JoltifyChain   InvoiceChainConfig
-	PubChainConfig PubChainConfig
-	TssConfig      TssConfig
-	KeyringAddress string
-	HomeDir        string
-	EnableMonitor  bool
+	JoltifyChain   InvoiceChainConfig `yaml:"joltify_chain"`
+	PubChainConfig PubChainConfig     `yaml:"pub_chain"`
+	// PubChains is the list of the public chains we bridge, if it is empty we only bridge PubChainConfig
+	PubChains      []PubChainConfig `yaml:"pub_chains"`
+	TssConfig      TssConfig        `yaml:"tss"`
+	Params         BridgeParams     `yaml:"params"`
+	KeyringAddress string           `yaml:"keyring"`
+	KeyringBackend string           `yaml:"keyring_backend"`
+	MnemonicFile   string           `yaml:"mnemonic_file"`
+	HomeDir        string           `yaml:"home"`
+	EnableMonitor  bool             `yaml:"enable_monitor"`
+	// SubmitterTimeout defines how long each pool member waits for the ones ranked before it to broadcast a tx
+	SubmitterTimeout time.Duration `yaml:"submitter_timeout"`
+	// AdminPort is the port of the admin api on the loopback, 0 disables the admin api
+	AdminPort int `yaml:"admin_port"`
+	// AdminTokenFile holds the bearer token of the admin api, a random token is written to it if it does not exist,
+	// it is admin_token in the home directory if it is empty
+	AdminTokenFile string `yaml:"admin_token_file"`
+	// TransferRetention is how long we keep the record of a transfer after its last update
+	TransferRetention time.Duration `yaml:"transfer_retention"`
 }
 
+// PubChainList returns the configs of all the public chains, the lookback, confirmation depth, stall timeout, gas tip
+// cap, stuck tx blocks, outbound batch size and outbound fee that are not set in the pub_chains list are taken from the pub_chain
+// section
+func (c Config) PubChainList() []PubChainConfig {
+	if len(c.PubChains) == 0 {
+		return []PubChainConfig{c.PubChainConfig}
+	}
+	chains := make([]PubChainConfig, len(c.PubChains))
+	for i, el := range c.PubChains {
+		if el.MaxLookBack == 0 {
+			el.MaxLookBack = c.PubChainConfig.MaxLookBack
+		}
+		if el.ConfirmationDepth == 0 {
+			el.ConfirmationDepth = c.PubChainConfig.ConfirmationDepth
+		}
+		if el.StallTimeout == 0 {
+			el.StallTimeout = c.PubChainConfig.StallTimeout
+		}
+		if el.GasTipCap == 0 {
+			el.GasTipCap = c.PubChainConfig.GasTipCap
+		}
+		if el.StuckTxBlocks == 0 {
+			el.StuckTxBlocks = c.PubChainConfig.StuckTxBlocks
+		}
+		if el.OutboundBatchSize == 0 {
+			el.OutboundBatchSize = c.PubChainConfig.OutboundBatchSize
+		}
+		if el.OutboundFeeDenom == "" {
+			el.OutboundFeeDenom = c.PubChainConfig.OutboundFeeDenom
+		}
+		if el.OutboundFeeMin == "" {
+			el.OutboundFeeMin = c.PubChainConfig.OutboundFeeMin
+		}
+		chains[i] = el
+	}
+	return chains
+}
+
+// DefaultConfig returns the config with the default values of the flags
 func DefaultConfig() Config {
 	var config Config
-	flag.StringVar(&config.JoltifyChain.GrpcAddress, "grpc-port", "127.0.0.1:9090", "address for joltify pub_chain")
-	flag.StringVar(&config.JoltifyChain.WsAddress, "ws-port", "tcp://localhost:26657", "ws address for joltify pub_chain")
-	flag.StringVar(&config.JoltifyChain.HTTPAddress, "http-port", "http://localhost:26657", "ws address for joltify pub_chain")
-	flag.StringVar(&config.JoltifyChain.WsEndpoint, "ws-endpoint", "/websocket", "endpoint for joltify pub_chain")
-	flag.StringVar(&config.PubChainConfig.WsAddress, "pub-ws-endpoint", "ws://10.2.118.8:8456/", "endpoint for public pub_chain listener")
-	flag.StringVar(&config.PubChainConfig.TokenAddress, "pub-token-addr", "0xeB42ff4cA651c91EB248f8923358b6144c6B4b79", "monitored token address")
-	flag.StringVar(&config.KeyringAddress, "key", "./keyring.key", "operator key path")
-	flag.StringVar(&config.HomeDir, "home", "/root/.joltifyChain/config", "home director for joltify_bridge")
-	flag.StringVar(&config.TssConfig.HTTPAddr, "tss-http-port", "0.0.0.0:8321", "tss http port for info only")
+	RegisterFlags(flag.NewFlagSet("default", flag.ContinueOnError), &config)
+	return config
+}
+
+// RegisterFlags defines the flags of the config on the given flag set, the flags write to the config directly
+func RegisterFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.JoltifyChain.GrpcAddress, "grpc-port", "127.0.0.1:9090", "address for joltify pub_chain")
+	fs.StringVar(&config.JoltifyChain.WsAddress, "ws-port", "tcp://localhost:26657", "ws address for joltify pub_chain")
+	fs.StringVar(&config.JoltifyChain.HTTPAddress, "http-port", "http://localhost:26657", "ws address for joltify pub_chain")
+	fs.StringVar(&config.JoltifyChain.WsEndpoint, "ws-endpoint", "/websocket", "endpoint for joltify pub_chain")
+	fs.DurationVar(&config.JoltifyChain.StallTimeout, "stall-timeout", time.Second*30, "reconnect the joltify pub_chain if no new block arrives in this duration")
+	fs.Int64Var(&config.JoltifyChain.MintBatchBlocks, "mint-batch-blocks", 5, "number of public chain blocks whose inbounds are minted in one joltify tx")
+	fs.IntVar(&config.JoltifyChain.MintBatchSize, "mint-batch-size", 20, "maximum number of mints in one joltify tx")
+	fs.Uint64Var(&config.PubChainConfig.ChainID, "pub-chain-id", 0, "chain ID of the public chain, 0 to use the one reported by the node")
+	fs.StringVar(&config.PubChainConfig.WsAddress, "pub-ws-endpoint", "ws://10.2.118.8:8456/", "endpoint for public pub_chain listener")
+	fs.StringVar(&config.PubChainConfig.TokenAddress, "pub-token-addr", "0xeB42ff4cA651c91EB248f8923358b6144c6B4b79", "monitored token address")
+	fs.Var(&config.PubChainConfig.Tokens, "pub-tokens", "bridged tokens in the form of address:denom:decimals separated by comma")
+	fs.Int64Var(&config.PubChainConfig.MaxLookBack, "pub-max-lookback", 2000, "maximum number of public chain blocks to replay after downtime")
+	fs.Int64Var(&config.PubChainConfig.ConfirmationDepth, "pub-confirmations", 15, "number of confirmations before a public chain block is processed")
+	fs.DurationVar(&config.PubChainConfig.StallTimeout, "pub-stall-timeout", time.Minute, "reconnect the public chain if no new block arrives in this duration")
+	fs.BoolVar(&config.PubChainConfig.LegacyTx, "pub-legacy-tx", false, "send legacy txs even if the public chain supports EIP-1559")
+	fs.Uint64Var(&config.PubChainConfig.GasTipCap, "pub-gas-tip-cap", 1000000000, "priority fee in wei of the EIP-1559 txs, the same on all the pool members")
+	fs.Uint64Var(&config.PubChainConfig.MaxGasFeeCap, "pub-max-gas-fee-cap", 0, "maximum fee cap in wei of the EIP-1559 txs, 0 for no limit")
+	fs.Int64Var(&config.PubChainConfig.StuckTxBlocks, "pub-stuck-tx-blocks", 20, "number of public chain blocks before a pending tx is replaced with a higher fee")
+	fs.StringVar(&config.PubChainConfig.MultisendAddress, "pub-multisend-addr", "", "disperse contract that pays the outbounds in batches, empty to pay them one by one")
+	fs.IntVar(&config.PubChainConfig.OutboundBatchSize, "pub-outbound-batch-size", 20, "maximum number of outbounds paid in one multisend tx")
+	fs.StringVar(&config.PubChainConfig.OutboundFeeDenom, "pub-outbound-fee-denom", "JOLT", "denom of the fee paid on the joltify chain by the outbounds to the public chain")
+	fs.StringVar(&config.PubChainConfig.OutboundFeeMin, "pub-outbound-fee", "0.00000000000000001", "minimum fee of the outbounds to the public chain")
+	fs.StringVar(&config.KeyringAddress, "key", "./keyring.key", "operator key path")
+	fs.StringVar(&config.KeyringBackend, "keyring-backend", "memory", "keyring backend of the operator key (memory|file|os|test)")
+	fs.StringVar(&config.MnemonicFile, "mnemonic", "", "import the operator key from the mnemonic in this file instead of the armored key")
+	fs.StringVar(&config.HomeDir, "home", "/root/.joltifyChain/config", "home director for joltify_bridge")
+	fs.StringVar(&config.TssConfig.HTTPAddr, "tss-http-port", "0.0.0.0:8321", "tss http port for info only")
+	fs.IntVar(&config.AdminPort, "admin-port", 8322, "loopback port of the admin api, 0 to disable it")
+	fs.StringVar(&config.AdminTokenFile, "admin-token-file", "", "file of the bearer token of the admin api, admin_token in the home directory if empty")
 
 	// we setup the Tss parameter configuration
-	flag.DurationVar(&config.TssConfig.KeyGenTimeout, "gentimeout", 30*time.Second, "keygen timeout")
-	flag.DurationVar(&config.TssConfig.KeySignTimeout, "signtimeout", 30*time.Second, "keysign timeout")
-	flag.DurationVar(&config.TssConfig.PartyTimeout, "joinpartytimeout", time.Minute, "join party timeout")
+	fs.DurationVar(&config.TssConfig.KeyGenTimeout, "gentimeout", 30*time.Second, "keygen timeout")
+	fs.DurationVar(&config.TssConfig.KeySignTimeout, "signtimeout", 30*time.Second, "keysign timeout")
+	fs.DurationVar(&config.TssConfig.PartyTimeout, "joinpartytimeout", time.Minute, "join party timeout")
 
-	flag.DurationVar(&config.TssConfig.PreParamTimeout, "preparamtimeout", 5*time.Minute, "pre-parameter generation timeout")
-	flag.BoolVar(&config.EnableMonitor, "enablemonitor", true, "enable the joltifyChain monitor")
+	fs.DurationVar(&config.TssConfig.PreParamTimeout, "preparamtimeout", 5*time.Minute, "pre-parameter generation timeout")
+	fs.BoolVar(&config.EnableMonitor, "enablemonitor", true, "enable the joltifyChain monitor")
+	fs.DurationVar(&config.SubmitterTimeout, "submitter-timeout", 15*time.Second, "time each pool member waits for the elected submitter before it broadcasts the tx itself")
+	fs.DurationVar(&config.TransferRetention, "transfer-retention", 30*24*time.Hour, "time the record of a transfer is kept after its last update")
 
 	// we setup the p2p network configuration
-	flag.StringVar(&config.TssConfig.RendezvousString, "rendezvous", "joltifyChainTss",
+	fs.StringVar(&config.TssConfig.RendezvousString, "rendezvous", "joltifyChainTss",
 		"Unique string to identify group of nodes. Share this with your friends to let them connect with you")
-	flag.IntVar(&config.TssConfig.Port, "p2p-port", 6668, "listening port local")
-	flag.StringVar(&config.TssConfig.ExternalIP, "external-ip", "", "external IP of this node")
-	flag.Var(&config.TssConfig.BootstrapPeers, "peer", "Adds a peer multiaddress to the bootstrap list")
+	fs.IntVar(&config.TssConfig.Port, "p2p-port", 6668, "listening port local")
+	fs.StringVar(&config.TssConfig.ExternalIP, "external-ip", "", "external IP of this node")
+	fs.Var(&config.TssConfig.BootstrapPeers, "peer", "Adds a peer multiaddress to the bootstrap list")
 
-	flag.Parse()
-	return config
+	// we setup the bridge parameters
+	fs.StringVar(&config.Params.InBoundDenomFee, "inbound-fee-denom", InBoundDenomFee, "denom of the fee paid on the public chain")
+	fs.StringVar(&config.Params.InBoundFeeMin, "inbound-fee-min", InBoundFeeMin, "minimum fee of the inbound tx")
+	fs.StringVar(&config.Params.InBoundDenom, "inbound-denom", InBoundDenom, "denom minted for the inbound tx")
+	fs.StringVar(&config.Params.OutBoundDenom, "outbound-denom", OutBoundDenom, "denom burnt for the outbound tx")
+	fs.Uint64Var(&config.Params.TxTimeout, "tx-timeout", TxTimeout, "number of public chain blocks we keep the pending inbound tx")
+	fs.StringVar(&config.Params.GasFeeRatio, "gas-fee-ratio", GASFEERATIO, "ratio applied to the estimated gas")
+	fs.StringVar(&config.Params.DustBNB, "dust-bnb", DUSTBNB, "balance below which the pool account is treated as empty")
+	fs.Int64Var(&config.Params.MinCheckBlockGap, "min-check-block-gap", MINCHECKBLOCKGAP, "number of blocks we wait before moving the fund of the retired pool")
 }
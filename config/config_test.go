@@ -1,12 +1,135 @@
 package config
 
 import (
+	"io/ioutil"
+	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfig(t *testing.T) {
 	config := DefaultConfig()
 	assert.Equal(t, config.HomeDir, "/root/.joltifyChain/config")
+	assert.Equal(t, config.Params.TxTimeout, uint64(300))
+	assert.NoError(t, config.Validate())
+}
+
+func TestLoadConfig(t *testing.T) {
+	home := t.TempDir()
+	content := `
+joltify_chain:
+  grpc_address: 10.0.0.1:9090
+pub_chain:
+  confirmation_depth: 20
+  stall_timeout: 2m
+tss:
+  peers:
+    - /ip4/127.0.0.1/tcp/6668/p2p/16Uiu2HAmACG5DtqmQsHtXg4G2sLS65ttv84e7MrL4kapkjfmhxAp
+params:
+  inbound_denom: ABNB
+  tx_timeout: 100
+`
+	require.NoError(t, ioutil.WriteFile(path.Join(home, ConfigFileName), []byte(content), 0o600))
+
+	// the file overrides the default
+	config, err := LoadConfig([]string{"-home", home})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:9090", config.JoltifyChain.GrpcAddress)
+	assert.Equal(t, int64(20), config.PubChainConfig.ConfirmationDepth)
+	assert.Equal(t, time.Minute*2, config.PubChainConfig.StallTimeout)
+	assert.Len(t, config.TssConfig.BootstrapPeers, 1)
+	assert.Equal(t, "ABNB", config.Params.InBoundDenom)
+	assert.Equal(t, uint64(100), config.Params.TxTimeout)
+	// the value absent from the file keeps the default
+	assert.Equal(t, "JOLT", config.PubChainConfig.OutboundFeeDenom)
+
+	// the environment overrides the file and the flag overrides the environment
+	t.Setenv(EnvName("pub-confirmations"), "30")
+	t.Setenv(EnvName("grpc-port"), "10.0.0.2:9090")
+	config, err = LoadConfig([]string{"-home", home, "-grpc-port", "10.0.0.3:9090"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), config.PubChainConfig.ConfirmationDepth)
+	assert.Equal(t, "10.0.0.3:9090", config.JoltifyChain.GrpcAddress)
+
+	// the explicit config file must exist
+	_, err = LoadConfig([]string{"-config", path.Join(home, "missing.yaml")})
+	require.Error(t, err)
+
+	// the unknown fields are rejected
+	require.NoError(t, ioutil.WriteFile(path.Join(home, "bad.yaml"), []byte("unknown: 1\n"), 0o600))
+	_, err = LoadConfig([]string{"-config", path.Join(home, "bad.yaml")})
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	config := DefaultConfig()
+	config.PubChainConfig.WsAddress = "http://127.0.0.1"
+	config.PubChainConfig.TokenAddress = "0x123"
+	config.TssConfig.Port = 0
+	config.Params.GasFeeRatio = "0.5"
+	config.Params.DustBNB = "abc"
+
+	err := config.Validate()
+	require.Error(t, err)
+	errs, ok := err.(ValidationErrors)
+	require.True(t, ok)
+	assert.Len(t, errs, 5)
+
+	_, err = LoadConfig([]string{"-home", t.TempDir(), "-tx-timeout", "0", "-ws-endpoint", "websocket", "-mint-batch-size", "0", "-submitter-timeout", "0", "-admin-port", "-1"})
+	require.Error(t, err)
+	assert.Len(t, err.(ValidationErrors), 5)
+}
+
+func TestPubChainList(t *testing.T) {
+	home := t.TempDir()
+	content := `
+pub_chain:
+  confirmation_depth: 20
+pub_chains:
+  - chain_id: 56
+    ws_address: ws://127.0.0.1:8456
+    tokens:
+      - address: "0x33875278f7757f6b43abC223EeC4a9D6204186a0"
+        denom: JUSD
+        decimals: 18
+  - chain_id: 1
+    ws_address: wss://127.0.0.1:8546
+    confirmation_depth: 64
+    outbound_fee_min: "0.001"
+    tokens:
+      - address: "0xeB42ff4cA651c91EB248f8923358b6144c6B4b79"
+        denom: EJUSD
+        decimals: 6
+`
+	require.NoError(t, ioutil.WriteFile(path.Join(home, ConfigFileName), []byte(content), 0o600))
+	config, err := LoadConfig([]string{"-home", home})
+	require.NoError(t, err)
+	chains := config.PubChainList()
+	require.Len(t, chains, 2)
+	assert.Equal(t, uint64(56), chains[0].ChainID)
+	// the values not set for the chain are taken from the pub_chain section
+	assert.Equal(t, int64(20), chains[0].ConfirmationDepth)
+	assert.Equal(t, time.Minute, chains[0].StallTimeout)
+	assert.Equal(t, int64(20), chains[0].StuckTxBlocks)
+	assert.Equal(t, int64(64), chains[1].ConfirmationDepth)
+	// each chain charges its own outbound fee
+	assert.Equal(t, "JOLT", chains[0].OutboundFeeDenom)
+	assert.Equal(t, "0.00000000000000001", chains[0].OutboundFeeMin)
+	assert.Equal(t, "0.001", chains[1].OutboundFeeMin)
+	assert.Equal(t, "EJUSD", chains[1].TokenList(config.Params.InBoundDenom)[0].Denom)
+
+	// the chains must have their own chain ID and denoms
+	config.PubChains[1].ChainID = 56
+	config.PubChains[1].Tokens[0].Denom = "JUSD"
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Len(t, err.(ValidationErrors), 2)
+	config.PubChains[1].ChainID = 0
+	assert.Len(t, config.Validate().(ValidationErrors), 2)
+
+	// without the list we bridge the single public chain
+	assert.Equal(t, []PubChainConfig{DefaultConfig().PubChainConfig}, DefaultConfig().PubChainList())
 }
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFileName is the name of the config file under the home directory
+	ConfigFileName = "config.yaml"
+	// EnvPrefix is the prefix of the environment variables that override the config
+	EnvPrefix = "JOLTIFY_BRIDGE_"
+)
+
+// EnvName returns the environment variable of the given flag, e.g. pub-ws-endpoint -> JOLTIFY_BRIDGE_PUB_WS_ENDPOINT
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// LoadConfig builds the config from the defaults, the config file, the environment variables and the
+// command line arguments, the later ones override the former ones. The config file is read from the
+// -config argument or from config.yaml under the home directory.
+func LoadConfig(args []string) (Config, error) {
+	// the first pass finds the home directory and the config file
+	var probe Config
+	probeFs := newFlagSet(&probe)
+	configFile := probeFs.String("config", "", "path of the config file (default <home>/config.yaml)")
+	if err := probeFs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	homeDir := probe.HomeDir
+	if env, ok := os.LookupEnv(EnvName("home")); ok && !isFlagSet(probeFs, "home") {
+		homeDir = env
+	}
+	if env, ok := os.LookupEnv(EnvName("config")); ok && !isFlagSet(probeFs, "config") {
+		*configFile = env
+	}
+
+	config := DefaultConfig()
+	config.HomeDir = homeDir
+	mustExist := true
+	if *configFile == "" {
+		*configFile = path.Join(homeDir, ConfigFileName)
+		mustExist = false
+	}
+	if err := loadConfigFile(*configFile, mustExist, &config); err != nil {
+		return Config{}, err
+	}
+
+	// now we apply the environment variables and the command line arguments on top of the file
+	loaded := config
+	fs := newFlagSet(&config)
+	// registering the flags resets the fields to the defaults, so we put back the values from the file
+	config = loaded
+	fs.String("config", "", "path of the config file (default <home>/config.yaml)")
+	var envErr []error
+	fs.VisitAll(func(f *flag.Flag) {
+		env, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok || f.Name == "config" {
+			return
+		}
+		if f.Name == "peer" {
+			config.TssConfig.BootstrapPeers = nil
+		}
+		if err := fs.Set(f.Name, env); err != nil {
+			envErr = append(envErr, fmt.Errorf("invalid value of %v: %w", EnvName(f.Name), err))
+		}
+	})
+	if len(envErr) != 0 {
+		return Config{}, ValidationErrors(envErr)
+	}
+	if isFlagSet(probeFs, "peer") {
+		config.TssConfig.BootstrapPeers = nil
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func newFlagSet(config *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("joltifyBridge", flag.ContinueOnError)
+	RegisterFlags(fs, config)
+	return fs
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// loadConfigFile reads the yaml config file into the config, the fields absent from the file keep their values
+func loadConfigFile(filePath string, mustExist bool, config *Config) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !mustExist {
+			return nil
+		}
+		return fmt.Errorf("fail to read the config file %v: %w", filePath, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(config)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("fail to parse the config file %v: %w", filePath, err)
+	}
+	return nil
+}
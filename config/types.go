@@ -2,21 +2,31 @@ package config
 
 import "time"
 
-const (
+// the bridge parameters, they are overridden by ApplyParams with the loaded config
+var (
 	InBoundDenomFee = "BNB"
 
-	OutBoundDenomFee = "JOLT"
-
-	InBoundFeeMin    = "0.00000000000000001"
-	OUTBoundFeeOut   = "0.00000000000000001"
-	InBoundDenom     = "JUSD"
-	OutBoundDenom    = "JUSD"
-	TxTimeout        = 300
-	GASFEERATIO      = "1.5"
-	DUSTBNB          = "0.0001"
-	MINCHECKBLOCKGAP = 6
+	InBoundFeeMin           = "0.00000000000000001"
+	InBoundDenom            = "JUSD"
+	OutBoundDenom           = "JUSD"
+	TxTimeout        uint64 = 300
+	GASFEERATIO             = "1.5"
+	DUSTBNB                 = "0.0001"
+	MINCHECKBLOCKGAP int64  = 6
 )
 
+// ApplyParams sets the bridge parameters used by the chain modules
+func ApplyParams(params BridgeParams) {
+	InBoundDenomFee = params.InBoundDenomFee
+	InBoundFeeMin = params.InBoundFeeMin
+	InBoundDenom = params.InBoundDenom
+	OutBoundDenom = params.OutBoundDenom
+	TxTimeout = params.TxTimeout
+	GASFEERATIO = params.GasFeeRatio
+	DUSTBNB = params.DustBNB
+	MINCHECKBLOCKGAP = params.MinCheckBlockGap
+}
+
 const (
 	InBound = iota
 	OutBound
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ValidationErrors collects all the problems found in the config
+type ValidationErrors []error
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, el := range v {
+		msgs[i] = el.Error()
+	}
+	return fmt.Sprintf("invalid config: %v", strings.Join(msgs, "; "))
+}
+
+// Validate checks the config and reports all the errors at once
+func (c Config) Validate() error {
+	var errs ValidationErrors
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.JoltifyChain.GrpcAddress != "", "the joltify grpc address is empty")
+	check(c.JoltifyChain.WsAddress != "", "the joltify ws address is empty")
+	check(c.JoltifyChain.HTTPAddress != "", "the joltify http address is empty")
+	check(strings.HasPrefix(c.JoltifyChain.WsEndpoint, "/"), "the joltify ws endpoint %q should start with /", c.JoltifyChain.WsEndpoint)
+	check(c.JoltifyChain.StallTimeout >= 0, "the joltify stall timeout should not be negative")
+	check(c.JoltifyChain.MintBatchBlocks > 0, "the mint batch blocks should be positive")
+	check(c.JoltifyChain.MintBatchSize > 0, "the mint batch size should be positive")
+
+	chains := c.PubChainList()
+	chainIDs := make(map[uint64]bool)
+	denoms := make(map[string]bool)
+	for _, chain := range chains {
+		check(strings.HasPrefix(chain.WsAddress, "ws://") || strings.HasPrefix(chain.WsAddress, "wss://"),
+			"the public chain address %q is not a websocket address", chain.WsAddress)
+		check(len(chains) == 1 || chain.ChainID != 0, "the chain ID of %v should be set when more than one public chain is bridged", chain.WsAddress)
+		check(chain.ChainID == 0 || !chainIDs[chain.ChainID], "the chain ID %v is used by more than one public chain", chain.ChainID)
+		chainIDs[chain.ChainID] = true
+
+		// the denom decides which chain an outbound goes to, so it must be unique across all the chains
+		addresses := make(map[string]bool)
+		for _, el := range chain.TokenList(c.Params.InBoundDenom) {
+			check(common.IsHexAddress(el.Address), "the token address %q is invalid", el.Address)
+			check(sdk.ValidateDenom(el.Denom) == nil, "the denom %q of token %v is invalid", el.Denom, el.Address)
+			check(el.Decimals <= 36, "the decimals of token %v should not exceed 36", el.Address)
+			check(!addresses[strings.ToLower(el.Address)], "the token %v is duplicated", el.Address)
+			check(!denoms[el.Denom], "the denom %v is used by more than one token", el.Denom)
+			addresses[strings.ToLower(el.Address)] = true
+			denoms[el.Denom] = true
+		}
+		check(chain.MaxLookBack >= 0, "the max lookback should not be negative")
+		check(chain.ConfirmationDepth >= 0, "the confirmation depth should not be negative")
+		check(chain.StallTimeout >= 0, "the public chain stall timeout should not be negative")
+		check(chain.MultisendAddress == "" || common.IsHexAddress(chain.MultisendAddress), "the multisend address %q is invalid", chain.MultisendAddress)
+		check(chain.OutboundBatchSize > 0, "the outbound batch size of %v should be positive", chain.WsAddress)
+		check(chain.StuckTxBlocks > 0, "the stuck tx blocks of %v should be positive", chain.WsAddress)
+		check(chain.LegacyTx || chain.GasTipCap > 0, "the gas tip cap of %v should be set unless it sends the legacy txs", chain.WsAddress)
+		check(chain.MaxGasFeeCap == 0 || chain.GasTipCap <= chain.MaxGasFeeCap, "the gas tip cap of %v should not exceed the max gas fee cap", chain.WsAddress)
+		check(sdk.ValidateDenom(chain.OutboundFeeDenom) == nil, "the outbound fee denom %q of %v is invalid", chain.OutboundFeeDenom, chain.WsAddress)
+		feeMin, err := sdk.NewDecFromStr(chain.OutboundFeeMin)
+		check(err == nil && !feeMin.IsNegative(), "the outbound fee %q of %v is not a valid amount", chain.OutboundFeeMin, chain.WsAddress)
+	}
+
+	check(c.TssConfig.KeyGenTimeout > 0, "the keygen timeout should be positive")
+	check(c.TssConfig.KeySignTimeout > 0, "the keysign timeout should be positive")
+	check(c.TssConfig.PartyTimeout > 0, "the join party timeout should be positive")
+	check(c.TssConfig.PreParamTimeout > 0, "the pre-parameter timeout should be positive")
+	check(c.TssConfig.RendezvousString != "", "the rendezvous string is empty")
+	check(c.TssConfig.Port > 0 && c.TssConfig.Port < 65536, "the p2p port %v is invalid", c.TssConfig.Port)
+	check(c.TssConfig.HTTPAddr != "", "the tss http address is empty")
+	check(c.SubmitterTimeout > 0, "the submitter timeout should be positive")
+	check(c.TransferRetention > 0, "the transfer retention should be positive")
+	check(c.AdminPort >= 0 && c.AdminPort < 65536, "the admin port %v is invalid", c.AdminPort)
+
+	check(c.KeyringAddress != "", "the keyring path is empty")
+	check(c.HomeDir != "", "the home directory is empty")
+	switch c.KeyringBackend {
+	case "memory", "file", "os", "test":
+	default:
+		errs = append(errs, fmt.Errorf("the keyring backend %q is not supported", c.KeyringBackend))
+	}
+
+	p := c.Params
+	for _, el := range [][2]string{
+		{"inbound denom", p.InBoundDenom},
+		{"inbound fee denom", p.InBoundDenomFee},
+		{"outbound denom", p.OutBoundDenom},
+	} {
+		check(sdk.ValidateDenom(el[1]) == nil, "the %v %q is invalid", el[0], el[1])
+	}
+	for _, el := range [][2]string{
+		{"inbound fee min", p.InBoundFeeMin},
+		{"dust bnb", p.DustBNB},
+		{"gas fee ratio", p.GasFeeRatio},
+	} {
+		dec, err := sdk.NewDecFromStr(el[1])
+		check(err == nil && !dec.IsNegative(), "the %v %q is not a valid amount", el[0], el[1])
+	}
+	ratio, err := sdk.NewDecFromStr(p.GasFeeRatio)
+	check(err != nil || ratio.GTE(sdk.OneDec()), "the gas fee ratio should not be less than 1")
+	check(p.TxTimeout > 0, "the tx timeout should be positive")
+	check(p.MinCheckBlockGap > 0, "the min check block gap should be positive")
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package joltifybridge
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// QueueItems lists the items waiting in the queues of the joltify chain
+func (jc *JoltifyChainInstance) QueueItems() []bcommon.QueueItem {
+	var items []bcommon.QueueItem
+	jc.RetryOutboundReq.Range(func(_, value interface{}) bool {
+		el := value.(*OutBoundReq)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.RetryOutboundQueue, ID: el.Hash().Hex(), Height: el.blockHeight, Item: el})
+		return true
+	})
+	jc.droppedOutbounds.Range(func(_, value interface{}) bool {
+		el := value.(*OutBoundReq)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.DroppedQueue, ID: el.Hash().Hex(), Height: el.blockHeight, Item: el})
+		return true
+	})
+	jc.moveFundReq.Range(func(key, value interface{}) bool {
+		el := value.(*bcommon.PoolInfo)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.MoveFundQueue, ID: el.JoltifyAddress.String(), Height: key.(int64), Item: el})
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Queue != items[j].Queue {
+			return items[i].Queue < items[j].Queue
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// findRetryItem returns the key of the retry outbound with the given hash
+func (jc *JoltifyChainInstance) findRetryItem(id string) (*big.Int, *OutBoundReq) {
+	var foundKey *big.Int
+	var found *OutBoundReq
+	jc.RetryOutboundReq.Range(func(key, value interface{}) bool {
+		el := value.(*OutBoundReq)
+		if strings.EqualFold(el.Hash().Hex(), id) {
+			foundKey, found = key.(*big.Int), el
+			return false
+		}
+		return true
+	})
+	return foundKey, found
+}
+
+// findMoveFundItem returns the height of the move fund item of the given pool
+func (jc *JoltifyChainInstance) findMoveFundItem(id string) (int64, *bcommon.PoolInfo) {
+	var height int64
+	var found *bcommon.PoolInfo
+	jc.moveFundReq.Range(func(key, value interface{}) bool {
+		el := value.(*bcommon.PoolInfo)
+		if strings.EqualFold(el.JoltifyAddress.String(), id) {
+			height, found = key.(int64), el
+			return false
+		}
+		return true
+	})
+	return height, found
+}
+
+// DropItem removes the item from its queue and returns the queue, the dropped retry outbound is kept aside so it
+// can be requeued later
+func (jc *JoltifyChainInstance) DropItem(id string) (string, error) {
+	if key, req := jc.findRetryItem(id); req != nil {
+		if _, ok := jc.RetryOutboundReq.LoadAndDelete(key); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		jc.unpersist(storage.RetryOutboundBucket, req.Hash().Hex())
+		jc.ForgetOutbound(req)
+		jc.droppedOutbounds.Store(req.Hash().Hex(), req)
+		jc.persist(storage.DroppedOutboundBucket, req.Hash().Hex(), req)
+		return bcommon.RetryOutboundQueue, nil
+	}
+	if height, pool := jc.findMoveFundItem(id); pool != nil {
+		if _, ok := jc.moveFundReq.LoadAndDelete(height); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		jc.unpersist(storage.JoltMoveFundBucket, strconv.FormatInt(height, 10))
+		jc.ForgetRetiredPool(pool)
+		return bcommon.MoveFundQueue, nil
+	}
+	return "", bcommon.ErrItemNotFound
+}
+
+// RequeueItem puts the dropped outbound back to the retry queue
+func (jc *JoltifyChainInstance) RequeueItem(id string) (string, error) {
+	var found *OutBoundReq
+	jc.droppedOutbounds.Range(func(_, value interface{}) bool {
+		el := value.(*OutBoundReq)
+		if strings.EqualFold(el.Hash().Hex(), id) {
+			found = el
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return "", bcommon.ErrItemNotFound
+	}
+	jc.droppedOutbounds.Delete(found.Hash().Hex())
+	jc.unpersist(storage.DroppedOutboundBucket, found.Hash().Hex())
+	jc.observeOutbound(found)
+	jc.AddItem(found)
+	return bcommon.DroppedQueue, nil
+}
+
+// RetryItem hands the retry outbound to the outbound processing at once, the move fund item is retried in the next
+// block
+func (jc *JoltifyChainInstance) RetryItem(id string) (string, error) {
+	if key, req := jc.findRetryItem(id); req != nil {
+		if _, ok := jc.RetryOutboundReq.LoadAndDelete(key); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		jc.markInflight(req)
+		jc.unpersist(storage.RetryOutboundBucket, req.Hash().Hex())
+		select {
+		case jc.OutboundReqChan <- req:
+			return bcommon.RetryOutboundQueue, nil
+		default:
+			jc.AddItem(req)
+			return "", errors.New("the outbound channel is full, try again later")
+		}
+	}
+	if height, pool := jc.findMoveFundItem(id); pool != nil {
+		if _, ok := jc.moveFundReq.LoadAndDelete(height); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		jc.unpersist(storage.JoltMoveFundBucket, strconv.FormatInt(height, 10))
+		jc.AddMoveFundItem(pool, jc.CurrentHeight-config.MINCHECKBLOCKGAP-1)
+		return bcommon.MoveFundQueue, nil
+	}
+	return "", bcommon.ErrItemNotFound
+}
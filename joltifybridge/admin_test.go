@@ -0,0 +1,109 @@
+package joltifybridge
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+func TestAdminQueueItems(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	jc := newStateTestInstance(store)
+	jc.OutboundReqChan = make(chan *OutBoundReq, 1)
+
+	req1 := newOutboundReq("tx1", accs[0].commAddr, accs[1].commAddr, sdk.NewCoin("test", sdk.NewInt(10)), 100)
+	req2 := newOutboundReq("tx2", accs[0].commAddr, accs[1].commAddr, sdk.NewCoin("test", sdk.NewInt(20)), 101)
+	jc.AddItem(&req1)
+	jc.AddItem(&req2)
+	pool := bcommon.PoolInfo{
+		Pk:             accs[2].pk,
+		JoltifyAddress: accs[2].joltAddr,
+		EthAddress:     accs[2].commAddr,
+	}
+	jc.AddMoveFundItem(&pool, 10)
+
+	items := jc.QueueItems()
+	require.Len(t, items, 3)
+	require.Equal(t, bcommon.MoveFundQueue, items[0].Queue)
+	require.Equal(t, accs[2].joltAddr.String(), items[0].ID)
+
+	// the dropped item is kept aside and survives the restart
+	queue, err := jc.DropItem(req1.Hash().Hex())
+	require.NoError(t, err)
+	require.Equal(t, bcommon.RetryOutboundQueue, queue)
+	require.Equal(t, 1, jc.Size())
+	restarted := newStateTestInstance(store)
+	restarted.OutboundReqChan = make(chan *OutBoundReq, 1)
+	require.NoError(t, restarted.RestoreState())
+	require.Equal(t, 1, restarted.Size())
+	items = restarted.QueueItems()
+	require.Equal(t, bcommon.DroppedQueue, items[0].Queue)
+	require.Equal(t, req1.Hash().Hex(), items[0].ID)
+
+	queue, err = restarted.RequeueItem(req1.Hash().Hex())
+	require.NoError(t, err)
+	require.Equal(t, bcommon.DroppedQueue, queue)
+	require.Equal(t, 2, restarted.Size())
+
+	queue, err = restarted.RetryItem(req2.Hash().Hex())
+	require.NoError(t, err)
+	require.Equal(t, bcommon.RetryOutboundQueue, queue)
+	require.Equal(t, req2.Hash().Hex(), (<-restarted.OutboundReqChan).Hash().Hex())
+	require.Equal(t, 1, restarted.Size())
+
+	restarted.CurrentHeight = 100
+	queue, err = restarted.RetryItem(accs[2].joltAddr.String())
+	require.NoError(t, err)
+	require.Equal(t, bcommon.MoveFundQueue, queue)
+	_, height := restarted.PopMoveFundItemAfterBlock(restarted.CurrentHeight)
+	require.Equal(t, 100-config.MINCHECKBLOCKGAP-1, height)
+
+	_, err = restarted.DropItem("unknown")
+	require.ErrorIs(t, err, bcommon.ErrItemNotFound)
+}
+
+func TestAddRefund(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	jc := newStateTestInstance(store)
+	jc.OutboundReqChan = make(chan *OutBoundReq, 1)
+
+	coin := sdk.NewCoin(config.InBoundDenom, sdk.NewInt(9))
+	jc.AddRefund("deposit1", accs[0].commAddr, accs[1].commAddr, coin, 100)
+	require.Equal(t, 1, jc.Size())
+
+	// the refund is paid like any outbound and survives the restart
+	restarted := newStateTestInstance(store)
+	restarted.OutboundReqChan = make(chan *OutBoundReq, 1)
+	require.NoError(t, restarted.RestoreState())
+	items := restarted.QueueItems()
+	require.Len(t, items, 1)
+	require.Equal(t, bcommon.RetryOutboundQueue, items[0].Queue)
+	_, req := restarted.findRetryItem(items[0].ID)
+	require.NotNil(t, req)
+	to, pool, got, height := req.GetOutBoundInfo()
+	require.Equal(t, accs[0].commAddr, to)
+	require.Equal(t, accs[1].commAddr, pool)
+	require.True(t, coin.IsEqual(got))
+	require.Equal(t, int64(100), height)
+}
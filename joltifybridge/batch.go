@@ -0,0 +1,137 @@
+package joltifybridge
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/pubchain"
+)
+
+// MintBatch is the inbound requests minted in one tx, Height is the keysign height of the tx and SeqHeight is the
+// joltify height the sequence of the tx is derived from, the batches of a public chain window have no SeqHeight until
+// the event loop anchors them to joltify
+type MintBatch struct {
+	Height    int64
+	SeqHeight int64
+	Items     []*pubchain.InboundReq
+}
+
+// RequestID returns the ID of the batch used to elect the node that broadcasts its tx
+func (b MintBatch) RequestID() []byte {
+	hashes := make([][]byte, len(b.Items))
+	for i, el := range b.Items {
+		hashes[i] = el.Hash().Bytes()
+	}
+	return bcommon.RequestID(hashes...)
+}
+
+// NewMintBatches sorts the requests by hash and splits them into batches of at most maxSize, so all the nodes build
+// the same txs from the same requests
+func NewMintBatches(height int64, items []*pubchain.InboundReq, maxSize int) []MintBatch {
+	sorted := append([]*pubchain.InboundReq{}, items...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].Hash(), sorted[j].Hash()
+		return bytes.Compare(a.Bytes(), b.Bytes()) == -1
+	})
+	var batches []MintBatch
+	for len(sorted) > 0 {
+		n := maxSize
+		if len(sorted) < n {
+			n = len(sorted)
+		}
+		batches = append(batches, MintBatch{Height: height, Items: sorted[:n]})
+		sorted = sorted[n:]
+	}
+	return batches
+}
+
+// MintBatcher collects the inbound requests of a window of public chain blocks, the requests of a window are minted
+// together once the window is processed by all the nodes
+type MintBatcher struct {
+	lock    sync.Mutex
+	window  int64
+	maxSize int
+	// windows are the collected requests keyed by the last block of their window
+	windows map[int64][]*pubchain.InboundReq
+}
+
+// NewMintBatcher creates the batcher with the window of the given number of blocks
+func NewMintBatcher(window int64, maxSize int) *MintBatcher {
+	return &MintBatcher{
+		window:  window,
+		maxSize: maxSize,
+		windows: make(map[int64][]*pubchain.InboundReq),
+	}
+}
+
+func (b *MintBatcher) windowEnd(height int64) int64 {
+	return height - height%b.window + b.window - 1
+}
+
+// Add puts the request into the window of the public chain block that completes it, the chain keeps the request in
+// its in-flight store until it is minted, so the windows are rebuilt from the store after a restart
+func (b *MintBatcher) Add(item *pubchain.InboundReq) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	end := b.windowEnd(item.SourceHeight())
+	b.windows[end] = append(b.windows[end], item)
+}
+
+// Size returns the number of the requests waiting for their window to close
+func (b *MintBatcher) Size() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	i := 0
+	for _, el := range b.windows {
+		i += len(el)
+	}
+	return i
+}
+
+// PopReady returns the batches of the windows that end before the processed height, we wait one more block as the
+// requests of the last block may still be on the way
+func (b *MintBatcher) PopReady(processed int64) []MintBatch {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var ends []int64
+	for end := range b.windows {
+		if end < processed {
+			ends = append(ends, end)
+		}
+	}
+	sort.Slice(ends, func(i, j int) bool {
+		return ends[i] < ends[j]
+	})
+	var batches []MintBatch
+	for _, end := range ends {
+		batches = append(batches, NewMintBatches(end, b.windows[end], b.maxSize)...)
+		delete(b.windows, end)
+	}
+	return batches
+}
+
+// RemoveFromHeights removes the requests derived from any of the given public chain blocks, the blocks have been
+// reorged out, so the requests must not be minted
+func (b *MintBatcher) RemoveFromHeights(heights map[int64]bool) []*pubchain.InboundReq {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var removed []*pubchain.InboundReq
+	for end, items := range b.windows {
+		var kept []*pubchain.InboundReq
+		for _, el := range items {
+			if el.FromHeights(heights) {
+				removed = append(removed, el)
+				continue
+			}
+			kept = append(kept, el)
+		}
+		if len(kept) == 0 {
+			delete(b.windows, end)
+			continue
+		}
+		b.windows[end] = kept
+	}
+	return removed
+}
@@ -0,0 +1,101 @@
+package joltifybridge
+
+import (
+	"encoding/json"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/pubchain"
+)
+
+func newTestInbound(txID byte, height int64) *pubchain.InboundReq {
+	item := pubchain.NewAccountInboundReq(sdk.AccAddress{txID}, common.Address{}, sdk.NewCoin("JUSD", sdk.NewInt(1)), []byte{txID}, height)
+	return &item
+}
+
+// newSourcedInbound returns the request derived from the given public chain blocks, only the chain sets the source
+// blocks, so we build the request from its stored form
+func newSourcedInbound(t *testing.T, txID byte, heights ...int64) *pubchain.InboundReq {
+	item := newTestInbound(txID, 0)
+	data, err := json.Marshal(item)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	fields["source_heights"] = heights
+	data, err = json.Marshal(fields)
+	require.NoError(t, err)
+	var ret pubchain.InboundReq
+	require.NoError(t, json.Unmarshal(data, &ret))
+	return &ret
+}
+
+func TestNewMintBatches(t *testing.T) {
+	var items []*pubchain.InboundReq
+	for i := 0; i < 5; i++ {
+		items = append(items, newTestInbound(byte(i), 10))
+	}
+	batches := NewMintBatches(12, items, 2)
+	require.Len(t, batches, 3)
+	require.Len(t, batches[2].Items, 1)
+
+	// the nodes receiving the requests in another order build the same batches
+	reversed := []*pubchain.InboundReq{items[4], items[3], items[2], items[1], items[0]}
+	require.Equal(t, batches, NewMintBatches(12, reversed, 2))
+	for _, el := range batches {
+		require.Equal(t, int64(12), el.Height)
+		if len(el.Items) == 2 {
+			require.Equal(t, -1, el.Items[0].Hash().Big().Cmp(el.Items[1].Hash().Big()))
+		}
+	}
+}
+
+func TestMintBatcher(t *testing.T) {
+	b := NewMintBatcher(5, 10)
+	b.Add(newTestInbound(1, 10))
+	b.Add(newTestInbound(2, 14))
+	b.Add(newTestInbound(3, 15))
+	require.Equal(t, 3, b.Size())
+
+	// the window 10-14 closes one block after its last block
+	require.Len(t, b.PopReady(14), 0)
+	batches := b.PopReady(15)
+	require.Len(t, batches, 1)
+	require.Equal(t, int64(14), batches[0].Height)
+	require.Len(t, batches[0].Items, 2)
+	require.Equal(t, 1, b.Size())
+
+	// a late request of a closed window is minted with the next ready batches
+	b.Add(newTestInbound(4, 12))
+	batches = b.PopReady(20)
+	require.Len(t, batches, 2)
+	require.Equal(t, int64(14), batches[0].Height)
+	require.Equal(t, int64(19), batches[1].Height)
+	require.Equal(t, 0, b.Size())
+}
+
+func TestMintBatcherReorg(t *testing.T) {
+	b := NewMintBatcher(5, 10)
+	b.Add(newSourcedInbound(t, 1, 9, 10))
+	b.Add(newSourcedInbound(t, 2, 11))
+	b.Add(newSourcedInbound(t, 3, 10, 12))
+	b.Add(newSourcedInbound(t, 4, 16))
+	require.Equal(t, 4, b.Size())
+
+	// the blocks 11 and 12 are reorged out before the window 10-14 closes
+	removed := b.RemoveFromHeights(map[int64]bool{11: true, 12: true})
+	require.Len(t, removed, 2)
+	require.Equal(t, 2, b.Size())
+
+	// the batch of the window only mints the requests of the blocks still on the chain
+	batches := b.PopReady(15)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0].Items, 1)
+	require.Equal(t, newSourcedInbound(t, 1, 9, 10).Hash(), batches[0].Items[0].Hash())
+
+	// the window left empty is dropped
+	require.Len(t, b.RemoveFromHeights(map[int64]bool{16: true}), 1)
+	require.Equal(t, 0, b.Size())
+	require.Len(t, b.PopReady(30), 0)
+}
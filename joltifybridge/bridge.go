@@ -3,7 +3,9 @@ package joltifybridge
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"strconv"
 	"sync"
 
@@ -15,12 +17,15 @@ import (
 	tendertypes "github.com/tendermint/tendermint/types"
 
 	coscrypto "github.com/cosmos/cosmos-sdk/crypto/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	"github.com/joltify-finance/tss/common"
 	"github.com/joltify-finance/tss/keysign"
 	"github.com/tendermint/tendermint/crypto"
 	tmclienthttp "github.com/tendermint/tendermint/rpc/client/http"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 	"gitlab.com/joltify/joltifychain/x/vault/types"
 
@@ -35,13 +40,22 @@ import (
 	xauthsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
 )
 
+// countRPCError counts the failed grpc calls to the joltify node by their method
+func (jc *JoltifyChainInstance) countRPCError(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		jc.Metric.AddRPCError(monitor.JoltifyChain + method)
+	}
+	return err
+}
+
 // NewJoltifyBridge new the instance for the joltify pub_chain
-func NewJoltifyBridge(grpcAddr, httpAddr string, tssServer tssclient.TssSign) (*JoltifyChainInstance, error) {
+func NewJoltifyBridge(grpcAddr, httpAddr string, tssServer tssclient.TssSign, stateStore *storage.StateStore, tokens *bcommon.ChainRegistry) (*JoltifyChainInstance, error) {
 	var joltifyBridge JoltifyChainInstance
 	var err error
 	joltifyBridge.logger = zlog.With().Str("module", "joltifyChain").Logger()
 
-	joltifyBridge.grpcClient, err = grpc.Dial(grpcAddr, grpc.WithInsecure())
+	joltifyBridge.grpcClient, err = grpc.Dial(grpcAddr, grpc.WithInsecure(), grpc.WithUnaryInterceptor(joltifyBridge.countRPCError))
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +70,7 @@ func NewJoltifyBridge(grpcAddr, httpAddr string, tssServer tssclient.TssSign) (*
 	}
 
 	joltifyBridge.wsClient = client
+	joltifyBridge.wsAddress = httpAddr
 
 	joltifyBridge.Keyring = keyring.NewInMemory()
 
@@ -79,6 +94,16 @@ func NewJoltifyBridge(grpcAddr, httpAddr string, tssServer tssclient.TssSign) (*
 	joltifyBridge.OutboundReqChan = make(chan *OutBoundReq, reqCacheSize)
 	joltifyBridge.RetryOutboundReq = &sync.Map{}
 	joltifyBridge.moveFundReq = &sync.Map{}
+	joltifyBridge.droppedOutbounds = &sync.Map{}
+	joltifyBridge.sentOutbounds = &sync.Map{}
+	joltifyBridge.outboundTxSeen = &sync.Map{}
+	joltifyBridge.outboundTxRetry = &sync.Map{}
+	joltifyBridge.stateStore = stateStore
+	joltifyBridge.tokens = tokens
+	joltifyBridge.sequences = newSequenceManager(joltifyBridge.logger, func(addr string, height int64) (authtypes.AccountI, error) {
+		return queryAccountAt(addr, joltifyBridge.grpcClient, height)
+	})
+	joltifyBridge.ctx, joltifyBridge.cancel = context.WithCancel(context.Background())
 	return &joltifyBridge, nil
 }
 
@@ -87,7 +112,8 @@ func (jc *JoltifyChainInstance) GetTssNodeID() string {
 }
 
 func (jc *JoltifyChainInstance) TerminateBridge() error {
-	err := jc.wsClient.Stop()
+	jc.cancel()
+	err := jc.getWsClient().Stop()
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("fail to terminate the ws")
 		return err
@@ -114,7 +140,7 @@ func (jc *JoltifyChainInstance) genSendTx(sdkMsg []sdk.Msg, accSeq, accNum, gasW
 	// txBuilder.SetMemo(...)
 	// txBuilder.SetTimeoutHeight(...)
 
-	key, err := jc.Keyring.Key("operator")
+	key, err := jc.Keyring.Key(OperatorKeyName)
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("fail to get the operator key")
 		return nil, err
@@ -186,12 +212,23 @@ func (jc *JoltifyChainInstance) signTx(txConfig client.TxConfig, txBuilder clien
 	var pk coscrypto.PubKey
 	if signMsg == nil {
 		// Sign those bytes by the node itself
-		signature, pk, err = jc.Keyring.Sign("operator", signBytes)
+		signature, pk, err = jc.Keyring.Sign(OperatorKeyName, signBytes)
 		if err != nil {
 			return sigV2, err
 		}
 	} else {
 		hashedMsg := crypto.Sha256(signBytes)
+		if jc.SignPolicy != nil {
+			keys, err := jc.txRequests(txBuilder.GetTx().GetMsgs())
+			if err != nil {
+				jc.logger.Error().Err(err).Msg("we refuse to sign the tx")
+				return signing.SignatureV2{}, err
+			}
+			if err := jc.SignPolicy.Expect(signMsg.Pk, hashedMsg, keys...); err != nil {
+				return signing.SignatureV2{}, err
+			}
+			defer jc.SignPolicy.Discard(hashedMsg)
+		}
 		encodedMsg := base64.StdEncoding.EncodeToString(hashedMsg)
 		signMsg.Msgs = []string{encodedMsg}
 		resp, err := jc.doTssSign(signMsg)
@@ -199,9 +236,9 @@ func (jc *JoltifyChainInstance) signTx(txConfig client.TxConfig, txBuilder clien
 			return signing.SignatureV2{}, err
 		}
 		if resp.Status != common.Success {
-			jc.logger.Error().Err(err).Msg("fail to generate the signature")
-			// todo we need to handle the blame
-			return signing.SignatureV2{}, err
+			// the tss server records the blame, so the peers blamed repeatedly by the pool are left out when we retry
+			jc.logger.Error().Msgf("fail to generate the signature, blame: %v", resp.Blame.String())
+			return signing.SignatureV2{}, errors.New("fail to generate the signature")
 		}
 		if len(resp.Signatures) != 1 {
 			jc.logger.Error().Msgf("we should only have 1 signature")
@@ -272,7 +309,7 @@ func (jc *JoltifyChainInstance) GasEstimation(sdkMsg []sdk.Msg, accSeq uint64, t
 	}
 	txBuilder.SetGasLimit(200000)
 
-	key, err := jc.Keyring.Key("operator")
+	key, err := jc.Keyring.Key(OperatorKeyName)
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("fail to get the operator key")
 		return 0, err
@@ -342,7 +379,8 @@ func (jc *JoltifyChainInstance) BroadcastTx(ctx context.Context, txBytes []byte)
 
 	if grpcRes.GetTxResponse().Code != 0 {
 		jc.logger.Error().Err(err).Msgf("fail to broadcast with response %v", grpcRes.TxResponse)
-		return false, "", nil
+		// the caller needs to know whether the sequence is used
+		return false, "", broadcastError(grpcRes.GetTxResponse())
 	}
 	txHash := grpcRes.GetTxResponse().TxHash
 	return true, txHash, nil
@@ -429,36 +467,94 @@ func (jc *JoltifyChainInstance) CheckAndUpdatePool(blockHeight int64) (bool, str
 	return false, ""
 }
 
-// CheckOutBoundTx checks
+// CheckOutBoundTx checks the outbounds to the pools in the tx, the tx failed for a reason that may go away is
+// processed again on the next block
 func (jc *JoltifyChainInstance) CheckOutBoundTx(blockHeight int64, rawTx tendertypes.Tx) {
+	txID := hex.EncodeToString(rawTx.Hash())
+	// the tx may be delivered by both the subscription and the catch up scanner
+	if jc.outboundTxProcessed(txID) {
+		jc.finishOutboundTxRetry(txID)
+		return
+	}
+	err := jc.checkOutBoundTx(txID, blockHeight, rawTx)
+	if err != nil {
+		jc.logger.Error().Err(err).Msgf("fail to process the outbound tx %v, we try it again on the next block", txID)
+		jc.retryOutboundTx(txID, blockHeight, rawTx)
+		return
+	}
+	jc.markOutboundTx(txID, blockHeight)
+	jc.finishOutboundTxRetry(txID)
+}
+
+// checkOutBoundTx queues the outbounds of the messages to the pools active at the block height, each message is
+// marked once it is handled, the error means the tx should be processed again
+func (jc *JoltifyChainInstance) checkOutBoundTx(txID string, blockHeight int64, rawTx tendertypes.Tx) error {
 	pools := jc.GetPool()
 	if pools[0] == nil || pools[1] == nil {
-		return
+		return errors.New("the pools are not known yet")
+	}
+	poolAddress, err := jc.poolAddressesAt(blockHeight)
+	if err != nil {
+		return err
 	}
-	poolAddress := []sdk.AccAddress{pools[0].JoltifyAddress, pools[1].JoltifyAddress}
 	config := jc.encoding
 
 	tx, err := config.TxConfig.TxDecoder()(rawTx)
 	if err != nil {
 		jc.logger.Info().Msgf("fail to decode the data and skip this tx")
-		return
+		return nil
 	}
 
 	txWithMemo, ok := tx.(sdk.TxWithMemo)
 	if !ok {
-		return
+		return nil
 	}
-	for _, msg := range txWithMemo.GetMsgs() {
+	for i, msg := range txWithMemo.GetMsgs() {
 		switch eachMsg := msg.(type) {
 		case *banktypes.MsgSend:
-			err := jc.processMsg(blockHeight, poolAddress, pools[1].EthAddress, eachMsg, rawTx.Hash())
+			// the messages queued before the failure of the tx are not queued again
+			msgID := txID + "/" + strconv.Itoa(i)
+			if jc.outboundTxProcessed(msgID) {
+				continue
+			}
+			err := jc.processMsg(blockHeight, poolAddress, pools[1].EthAddress, eachMsg, txWithMemo.GetMemo(), rawTx.Hash())
+			if errors.Is(err, errQueryAccount) {
+				return err
+			}
 			if err != nil {
 				if err.Error() != "not a top up message to the pool" {
 					jc.logger.Error().Err(err).Msgf("fail to process the message, it may")
 				}
 			}
+			jc.markOutboundTx(msgID, blockHeight)
 		default:
 			continue
 		}
 	}
+	return nil
+}
+
+// poolAddressesAt returns the addresses of the last two pools at the given joltify height, the outbound is sent to
+// the pools active at its height, which are not the current ones if we process an old block
+func (jc *JoltifyChainInstance) poolAddressesAt(blockHeight int64) ([]sdk.AccAddress, error) {
+	pools, err := queryLastPoolsAt(jc.grpcClient, blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("no pool at height %v", blockHeight)
+	}
+	var addresses []sdk.AccAddress
+	for _, el := range pools {
+		addr, err := misc.PoolPubKeyToJoltAddress(el.CreatePool.PoolPubKey)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, addr)
+	}
+	// only one pool is created at the start of the chain
+	if len(addresses) == 1 {
+		addresses = append(addresses, addresses[0])
+	}
+	return addresses, nil
 }
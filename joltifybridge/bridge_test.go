@@ -99,7 +99,7 @@ func (b BridgeTestSuite) TestBridge() {
 		true,
 		false,
 	}
-	jc, err := NewJoltifyBridge(b.network.Validators[0].APIAddress, b.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(b.network.Validators[0].APIAddress, b.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	b.Require().NoError(err)
 	jc.Keyring = b.validatorky
 
@@ -137,7 +137,7 @@ func (b BridgeTestSuite) TestBridgeTx() {
 		true,
 		true,
 	}
-	jc, err := NewJoltifyBridge(b.network.Validators[0].APIAddress, b.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(b.network.Validators[0].APIAddress, b.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	b.Require().NoError(err)
 	jc.Keyring = b.validatorky
 
@@ -208,7 +208,7 @@ func (b BridgeTestSuite) TestCheckAndUpdatePool() {
 		true,
 		true,
 	}
-	jc, err := NewJoltifyBridge(b.network.Validators[0].APIAddress, b.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(b.network.Validators[0].APIAddress, b.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	b.Require().NoError(err)
 	jc.Keyring = b.validatorky
 
@@ -0,0 +1,179 @@
+package joltifybridge
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	tendertypes "github.com/tendermint/tendermint/types"
+
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// outboundScanBatch is the maximum number of blocks we scan for each new joltify block
+const outboundScanBatch = 100
+
+// SaveProcessedHeight records the last joltify block whose outbound txs have been handled
+func (jc *JoltifyChainInstance) SaveProcessedHeight(height int64) {
+	jc.persist(storage.ChainHeightBucket, storage.JoltifyHeightKey, strconv.FormatInt(height, 10))
+}
+
+// GetProcessedHeight returns the last processed joltify block, 0 if we have no record
+func (jc *JoltifyChainInstance) GetProcessedHeight() int64 {
+	if jc.stateStore == nil {
+		return 0
+	}
+	var heightStr string
+	found, err := jc.stateStore.Get(storage.ChainHeightBucket, storage.JoltifyHeightKey, &heightStr)
+	if err != nil || !found {
+		return 0
+	}
+	height, err := strconv.ParseInt(heightStr, 10, 64)
+	if err != nil {
+		jc.logger.Error().Err(err).Msgf("invalid processed height %v", heightStr)
+		return 0
+	}
+	return height
+}
+
+// outboundTxProcessed tells whether the outbound tx has been processed
+func (jc *JoltifyChainInstance) outboundTxProcessed(txID string) bool {
+	_, ok := jc.outboundTxSeen.Load(txID)
+	return ok
+}
+
+// markOutboundTx records the outbound tx, it returns false if the tx has been processed before
+func (jc *JoltifyChainInstance) markOutboundTx(txID string, blockHeight int64) bool {
+	_, loaded := jc.outboundTxSeen.LoadOrStore(txID, blockHeight)
+	if loaded {
+		jc.logger.Debug().Msgf("the outbound tx %v has been processed", txID)
+		return false
+	}
+	jc.persist(storage.JoltOutboundTxBucket, txID, blockHeight)
+	return true
+}
+
+// pruneOutboundTx removes the records of the txs at or below the processed height as they will never be scanned again
+func (jc *JoltifyChainInstance) pruneOutboundTx(processedHeight int64) {
+	var expired []string
+	jc.outboundTxSeen.Range(func(key, value interface{}) bool {
+		// the messages of the tx to retry are kept so they are not queued twice
+		txID := strings.SplitN(key.(string), "/", 2)[0]
+		if _, ok := jc.outboundTxRetry.Load(txID); ok {
+			return true
+		}
+		if value.(int64) <= processedHeight {
+			expired = append(expired, key.(string))
+		}
+		return true
+	})
+	for _, el := range expired {
+		jc.outboundTxSeen.Delete(el)
+		jc.unpersist(storage.JoltOutboundTxBucket, el)
+	}
+}
+
+// retryTx is an outbound tx processed again on the next block
+type retryTx struct {
+	Height int64  `json:"height"`
+	Tx     []byte `json:"tx"`
+}
+
+// retryOutboundTx keeps the outbound tx that fails for a reason that may go away, it is processed again on each new
+// block until it succeeds
+func (jc *JoltifyChainInstance) retryOutboundTx(txID string, blockHeight int64, rawTx tendertypes.Tx) {
+	item := retryTx{Height: blockHeight, Tx: rawTx}
+	jc.outboundTxRetry.Store(txID, item)
+	jc.persist(storage.JoltRetryTxBucket, txID, item)
+}
+
+// finishOutboundTxRetry removes the processed tx from the retry list
+func (jc *JoltifyChainInstance) finishOutboundTxRetry(txID string) {
+	if _, ok := jc.outboundTxRetry.LoadAndDelete(txID); ok {
+		jc.unpersist(storage.JoltRetryTxBucket, txID)
+	}
+}
+
+// RetryOutboundTxs processes the outbound txs failed before again, in the order of their heights
+func (jc *JoltifyChainInstance) RetryOutboundTxs() {
+	var items []retryTx
+	jc.outboundTxRetry.Range(func(_, value interface{}) bool {
+		items = append(items, value.(retryTx))
+		return true
+	})
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Height < items[j].Height
+	})
+	for _, el := range items {
+		jc.CheckOutBoundTx(el.Height, el.Tx)
+	}
+}
+
+// scanBlock feeds the successful txs in the given block to CheckOutBoundTx
+func (jc *JoltifyChainInstance) scanBlock(height int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	block, err := jc.getWsClient().Block(ctx, &height)
+	if err != nil {
+		return err
+	}
+	results, err := jc.getWsClient().BlockResults(ctx, &height)
+	if err != nil {
+		return err
+	}
+	for i, tx := range block.Block.Data.Txs {
+		if i >= len(results.TxsResults) || results.TxsResults[i].Code != 0 {
+			continue
+		}
+		jc.CheckOutBoundTx(height, tx)
+	}
+	return nil
+}
+
+// CatchUpOutbound scans the joltify blocks that were missed by the tx subscription before the given block,
+// either because the bridge was offline or the subscription has a gap. The reconnects is the number of times the
+// subscriptions have been reconnected, after a reconnection the tx subscription may have missed the txs of the blocks
+// whose heads were delivered, so all of them are scanned. At most outboundScanBatch blocks are scanned each time so
+// that the event loop is not blocked
+func (jc *JoltifyChainInstance) CatchUpOutbound(currentBlockHeight, reconnects int64) {
+	pools := jc.GetPool()
+	if pools[0] == nil || pools[1] == nil {
+		return
+	}
+	jc.RetryOutboundTxs()
+	lastSeen := jc.lastBlockSeen
+	jc.lastBlockSeen = currentBlockHeight
+	if reconnects != jc.txReconnects {
+		jc.txReconnects = reconnects
+		jc.rescanTo = currentBlockHeight
+	}
+
+	processed := jc.GetProcessedHeight()
+	end := currentBlockHeight - 1
+	// if the previous block was delivered by the subscription, its txs have been handled already, unless the
+	// subscription has been reconnected since then
+	noGap := lastSeen == end && processed == end-1 && processed >= jc.rescanTo
+	if processed == 0 || processed >= end || noGap {
+		if processed < end {
+			jc.SaveProcessedHeight(end)
+		}
+		jc.pruneOutboundTx(end)
+		return
+	}
+	if end-processed > outboundScanBatch {
+		end = processed + outboundScanBatch
+	}
+
+	jc.logger.Info().Msgf("we scan the missed joltify blocks from %v to %v", processed+1, end)
+	for h := processed + 1; h <= end; h++ {
+		err := jc.scanBlock(h)
+		if err != nil {
+			jc.logger.Error().Err(err).Msgf("fail to scan the joltify block %v", h)
+			end = h - 1
+			break
+		}
+	}
+	jc.SaveProcessedHeight(end)
+	jc.pruneOutboundTx(end)
+}
@@ -0,0 +1,151 @@
+package joltifybridge
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tmclienthttp "github.com/tendermint/tendermint/rpc/client/http"
+	tendertypes "github.com/tendermint/tendermint/types"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+	"google.golang.org/grpc"
+)
+
+func TestMarkOutboundTx(t *testing.T) {
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	jc := newStateTestInstance(store)
+
+	require.True(t, jc.markOutboundTx("tx1", 10))
+	require.True(t, jc.markOutboundTx("tx2", 12))
+	// the same tx delivered by the scanner is ignored
+	require.False(t, jc.markOutboundTx("tx1", 10))
+
+	jc.pruneOutboundTx(10)
+	require.True(t, jc.markOutboundTx("tx1", 10))
+	jc.pruneOutboundTx(10)
+
+	// now we kill the bridge and restart it with the same state store
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newStateTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+	require.False(t, restarted.markOutboundTx("tx2", 12))
+	require.True(t, restarted.markOutboundTx("tx1", 10))
+}
+
+func TestCatchUpOutboundNoGap(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	jc := newStateTestInstance(store)
+	jc.poolUpdateLocker = &sync.RWMutex{}
+	jc.lastTwoPools = make([]*bcommon.PoolInfo, 2)
+
+	// we do nothing until the pools are ready
+	jc.CatchUpOutbound(10, 0)
+	require.Equal(t, int64(0), jc.GetProcessedHeight())
+
+	jc.lastTwoPools[0] = &bcommon.PoolInfo{JoltifyAddress: accs[0].joltAddr}
+	jc.lastTwoPools[1] = &bcommon.PoolInfo{JoltifyAddress: accs[1].joltAddr}
+
+	// the first run without any record starts from the current block
+	jc.CatchUpOutbound(10, 0)
+	require.Equal(t, int64(9), jc.GetProcessedHeight())
+
+	require.True(t, jc.markOutboundTx("tx1", 10))
+	// the blocks are delivered one by one by the subscription, no scan is needed
+	jc.CatchUpOutbound(11, 0)
+	require.Equal(t, int64(10), jc.GetProcessedHeight())
+	jc.CatchUpOutbound(12, 0)
+	require.Equal(t, int64(11), jc.GetProcessedHeight())
+	require.True(t, jc.markOutboundTx("tx1", 10))
+
+	// the heads keep coming after the subscriptions are reconnected, but the txs of the delivered blocks may be
+	// missed, so we scan them and the processed height stays until the scan succeeds
+	client, err := tmclienthttp.New("tcp://127.0.0.1:1", "/websocket")
+	require.NoError(t, err)
+	jc.wsClient = client
+	jc.CatchUpOutbound(13, 1)
+	require.Equal(t, int64(11), jc.GetProcessedHeight())
+	jc.CatchUpOutbound(14, 1)
+	require.Equal(t, int64(11), jc.GetProcessedHeight())
+}
+
+// unavailableConn fails all the grpc queries
+type unavailableConn struct{}
+
+func (unavailableConn) Invoke(_ context.Context, _ string, _, _ interface{}, _ ...grpc.CallOption) error {
+	return errors.New("unavailable")
+}
+
+func (unavailableConn) NewStream(_ context.Context, _ *grpc.StreamDesc, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestRetryOutboundTx(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	jc := newStateTestInstance(store)
+	jc.poolUpdateLocker = &sync.RWMutex{}
+	jc.lastTwoPools = []*bcommon.PoolInfo{{JoltifyAddress: accs[0].joltAddr}, {JoltifyAddress: accs[1].joltAddr}}
+	jc.grpcClient = unavailableConn{}
+
+	// the tx is not marked as processed if we fail to query the pools at its height
+	rawTx := tendertypes.Tx("tx1")
+	txID := hex.EncodeToString(rawTx.Hash())
+	jc.CheckOutBoundTx(10, rawTx)
+	require.False(t, jc.outboundTxProcessed(txID))
+	_, ok := jc.outboundTxRetry.Load(txID)
+	require.True(t, ok)
+
+	// the messages handled before the failure are kept until the tx is processed
+	require.True(t, jc.markOutboundTx(txID+"/0", 10))
+	jc.pruneOutboundTx(10)
+	require.True(t, jc.outboundTxProcessed(txID+"/0"))
+
+	// the tx is retried after the restart
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newStateTestInstance(store)
+	restarted.poolUpdateLocker = jc.poolUpdateLocker
+	restarted.lastTwoPools = jc.lastTwoPools
+	restarted.grpcClient = unavailableConn{}
+	require.NoError(t, restarted.RestoreState())
+	restarted.RetryOutboundTxs()
+	_, ok = restarted.outboundTxRetry.Load(txID)
+	require.True(t, ok)
+
+	// the tx processed by the scanner leaves the retry list
+	require.True(t, restarted.markOutboundTx(txID, 10))
+	restarted.RetryOutboundTxs()
+	_, ok = restarted.outboundTxRetry.Load(txID)
+	require.False(t, ok)
+	restarted.pruneOutboundTx(10)
+	require.False(t, restarted.outboundTxProcessed(txID+"/0"))
+}
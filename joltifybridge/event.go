@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"strconv"
 
-	"github.com/cosmos/cosmos-sdk/types/bech32/legacybech32" // nolint
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 
 	"github.com/joltify-finance/tss/common"
@@ -20,7 +19,7 @@ const capacity = 10000
 
 // AddSubscribe add the subscirbe to the chain
 func (jc *JoltifyChainInstance) AddSubscribe(ctx context.Context, query string) (<-chan ctypes.ResultEvent, error) {
-	out, err := jc.wsClient.Subscribe(ctx, "joltifyBridge", query, capacity)
+	out, err := jc.getWsClient().Subscribe(ctx, subscriberName, query, capacity)
 	if err != nil {
 		jc.logger.Error().Err(err).Msgf("Failed to subscribe to query with error %v", err)
 		return nil, err
@@ -47,9 +46,7 @@ func (jc *JoltifyChainInstance) HandleUpdateValidators(validatorUpdates []*tmtyp
 		key := ed25519.PubKey{
 			Key: el.PubKey,
 		}
-		ret := &key
-
-		pk, err := legacybech32.MarshalPubKey(legacybech32.AccPK, ret) // nolint
+		pk, err := tssPubKey(el.PubKey)
 		if err != nil {
 			return err
 		}
@@ -75,12 +72,12 @@ func (jc *JoltifyChainInstance) HandleUpdateValidators(validatorUpdates []*tmtyp
 			return err
 		}
 		if resp.Status != common.Success {
-			// todo we need to put our blame on pub_chain as well
-			jc.logger.Error().Msgf("we fail to ge the valid key")
+			// the tss server records the blame of the failed keygen
+			jc.logger.Error().Msgf("we fail to ge the valid key, blame: %v", resp.Blame.String())
 			return nil
 		}
 		// now we put the tss key on pub_chain
-		creator, err := jc.Keyring.Key("operator")
+		creator, err := jc.Keyring.Key(OperatorKeyName)
 		if err != nil {
 			jc.logger.Error().Msgf("fail to get the operator key :%v", err)
 			return err
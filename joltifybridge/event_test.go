@@ -98,7 +98,7 @@ func (e EventTestSuite) TestSubscribe() {
 		true,
 		true,
 	}
-	jc, err := NewJoltifyBridge(e.network.Validators[0].APIAddress, e.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(e.network.Validators[0].APIAddress, e.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	e.Require().NoError(err)
 	defer func() {
 		err := jc.TerminateBridge()
@@ -122,7 +122,7 @@ func (e EventTestSuite) TestHandleUpdateEvent() {
 		false,
 		true,
 	}
-	jc, err := NewJoltifyBridge(e.network.Validators[0].APIAddress, e.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(e.network.Validators[0].APIAddress, e.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	e.Require().NoError(err)
 	defer func() {
 		err := jc.TerminateBridge()
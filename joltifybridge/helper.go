@@ -2,21 +2,35 @@ package joltifybridge
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
 	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	grpc1 "github.com/gogo/protobuf/grpc"
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
+	"google.golang.org/grpc/metadata"
 )
 
 // queryAccount get the current sender account info
 func queryAccount(addr string, grpcClient grpc1.ClientConn) (authtypes.AccountI, error) {
+	return queryAccountAt(addr, grpcClient, 0)
+}
+
+// queryAccountAt gets the account info in the state of the given height, the latest state is queried if the height
+// is zero
+func queryAccountAt(addr string, grpcClient grpc1.ClientConn, height int64) (authtypes.AccountI, error) {
 	accQuery := authtypes.NewQueryClient(grpcClient)
 	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
 	defer cancel()
+	if height != 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10))
+	}
 	accResp, err := accQuery.Account(ctx, &authtypes.QueryAccountRequest{Address: addr})
 	if err != nil {
 		return nil, err
@@ -57,6 +71,20 @@ func queryLastValidatorSet(grpcClient grpc1.ClientConn) ([]*vaulttypes.PoolInfo,
 	return resp.Pools, nil
 }
 
+// queryLastPoolsAt gets the last two pools at the given height
+func queryLastPoolsAt(grpcClient grpc1.ClientConn, height int64) ([]*vaulttypes.PoolInfo, error) {
+	ts := vaulttypes.NewQueryClient(grpcClient)
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10))
+
+	resp, err := ts.GetLastPool(ctx, &vaulttypes.QueryLatestPoolRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Pools, nil
+}
+
 // queryLastValidatorSet get the last two validator sets
 func queryGivenToeknIssueTx(grpcClient grpc1.ClientConn, index string) (*vaulttypes.IssueToken, error) {
 	ts := vaulttypes.NewQueryClient(grpcClient)
@@ -102,27 +130,102 @@ func GetLastBlockHeight(grpcClient grpc1.ClientConn) (int64, error) {
 	return resp.Block.Header.Height, nil
 }
 
-func (jc *JoltifyChainInstance) composeAndSend(sendMsg sdk.Msg, accSeq, accNum uint64, signMsg *tssclient.TssSignigMsg) (bool, string, error) {
-	gasWanted, err := jc.GasEstimation([]sdk.Msg{sendMsg}, accSeq, signMsg)
+// queryBlockTime gets the time of the block at the given height
+func queryBlockTime(grpcClient grpc1.ClientConn, height int64) (time.Time, error) {
+	ts := tmservice.NewServiceClient(grpcClient)
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+
+	resp, err := ts.GetBlockByHeight(ctx, &tmservice.GetBlockByHeightRequest{Height: height})
 	if err != nil {
-		jc.logger.Error().Err(err).Msg("Fail to get the gas estimation")
-		return false, "", err
+		return time.Time{}, err
 	}
-	txBuilder, err := jc.genSendTx([]sdk.Msg{sendMsg}, accSeq, accNum, gasWanted, signMsg)
+	return resp.Block.Header.Time, nil
+}
+
+// HeightAt returns the height of the last joltify block with a time not after t, the block is the same for all the
+// nodes once joltify has a later block, so we return an error until then
+func (jc *JoltifyChainInstance) HeightAt(t time.Time) (int64, error) {
+	latest, err := GetLastBlockHeight(jc.grpcClient)
+	if err != nil {
+		return 0, err
+	}
+	latestTime, err := queryBlockTime(jc.grpcClient, latest)
+	if err != nil {
+		return 0, err
+	}
+	if !latestTime.After(t) {
+		return 0, fmt.Errorf("the joltify block at %v is not later than %v", latest, t)
+	}
+	return searchHeight(latest, t, func(height int64) (time.Time, error) {
+		return queryBlockTime(jc.grpcClient, height)
+	})
+}
+
+// searchHeight returns the last height before the latest one whose block time is not after t, it steps back from the
+// latest block, so only the recent blocks are queried
+func searchHeight(latest int64, t time.Time, blockTime func(height int64) (time.Time, error)) (int64, error) {
+	// the block at hi is after t, the one at lo is not
+	hi, lo := latest, latest
+	for step := int64(1); ; step *= 2 {
+		lo = hi - step
+		if lo < 1 {
+			return 0, fmt.Errorf("no joltify block is before %v", t)
+		}
+		bt, err := blockTime(lo)
+		if err != nil {
+			return 0, err
+		}
+		if !bt.After(t) {
+			break
+		}
+		hi = lo
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		bt, err := blockTime(mid)
+		if err != nil {
+			return 0, err
+		}
+		if bt.After(t) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return lo, nil
+}
+
+func (jc *JoltifyChainInstance) composeAndSend(sendMsgs []sdk.Msg, accSeq, accNum uint64, signMsg *tssclient.TssSignigMsg) (bool, string, error) {
+	txBytes, err := jc.composeTx(sendMsgs, accSeq, accNum, signMsg)
 	if err != nil {
-		jc.logger.Error().Err(err).Msg("fail to generate the tx")
 		return false, "", err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
 	defer cancel()
 
+	ok, resp, err := jc.BroadcastTx(ctx, txBytes)
+	return ok, resp, err
+}
+
+// composeTx signs the msgs with tss and returns the encoded tx ready to be broadcast
+func (jc *JoltifyChainInstance) composeTx(sendMsgs []sdk.Msg, accSeq, accNum uint64, signMsg *tssclient.TssSignigMsg) ([]byte, error) {
+	gasWanted, err := jc.GasEstimation(sendMsgs, accSeq, signMsg)
+	if err != nil {
+		jc.logger.Error().Err(err).Msg("Fail to get the gas estimation")
+		return nil, err
+	}
+	txBuilder, err := jc.genSendTx(sendMsgs, accSeq, accNum, gasWanted, signMsg)
+	if err != nil {
+		jc.logger.Error().Err(err).Msg("fail to generate the tx")
+		return nil, err
+	}
+
 	txBytes, err := jc.encoding.TxConfig.TxEncoder()(txBuilder.GetTx())
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("fail to encode the tx")
-		return false, "", err
+		return nil, err
 	}
-
-	ok, resp, err := jc.BroadcastTx(ctx, txBytes)
-	return ok, resp, err
+	return txBytes, nil
 }
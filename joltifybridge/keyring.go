@@ -0,0 +1,99 @@
+package joltifybridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// OperatorKeyName is the name of the operator key in the keyring
+const OperatorKeyName = "operator"
+
+const keyringAppName = "joltifyBridge"
+
+// ErrDecryptKey is returned if the armored operator key cannot be decrypted with the passcode
+var ErrDecryptKey = errors.New("fail to decrypt the operator key, please check the passcode")
+
+// passcodeReader feeds the passcode to the keyring whenever it asks for the passphrase
+type passcodeReader struct {
+	line []byte
+	pos  int
+}
+
+func (p *passcodeReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		copied := copy(b[n:], p.line[p.pos:])
+		n += copied
+		p.pos = (p.pos + copied) % len(p.line)
+	}
+	return n, nil
+}
+
+// NewKeyring opens the keyring of the given backend, the file backend is encrypted with the passcode
+func NewKeyring(backend, rootDir, passcode string) (keyring.Keyring, error) {
+	switch backend {
+	case keyring.BackendMemory:
+		return keyring.NewInMemory(), nil
+	case keyring.BackendFile, keyring.BackendOS, keyring.BackendTest:
+		return keyring.New(keyringAppName, backend, rootDir, &passcodeReader{line: []byte(passcode + "\n")})
+	default:
+		return nil, fmt.Errorf("unsupported keyring backend %v", backend)
+	}
+}
+
+// ImportOperatorKey makes sure the keyring has the operator key. The key in a persistent keyring is used
+// directly, otherwise we import it from the mnemonic file if given, or from the armored key file that
+// is encrypted with the passcode.
+func ImportOperatorKey(kr keyring.Keyring, armorPath, mnemonicPath, passcode string) (keyring.Info, error) {
+	info, err := kr.Key(OperatorKeyName)
+	if err == nil {
+		return info, nil
+	}
+
+	if mnemonicPath != "" {
+		dat, err := ioutil.ReadFile(mnemonicPath)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read the mnemonic file: %w", err)
+		}
+		info, err = kr.NewAccount(OperatorKeyName, strings.TrimSpace(string(dat)), "", sdk.FullFundraiserPath, hd.Secp256k1)
+		if err != nil {
+			return nil, fmt.Errorf("fail to import the operator key from mnemonic: %w", err)
+		}
+		return info, nil
+	}
+
+	dat, err := ioutil.ReadFile(armorPath)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read the keyring file: %w", err)
+	}
+	err = kr.ImportPrivKey(OperatorKeyName, string(dat), passcode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptKey, err)
+	}
+	return kr.Key(OperatorKeyName)
+}
+
+// ReadPasscode reads the passcode from the first line of the input
+func ReadPasscode(in io.Reader, maxLength int) (string, error) {
+	buf := make([]byte, maxLength+2)
+	n, err := io.ReadAtLeast(in, buf, 1)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	passcode := string(buf[:n])
+	if idx := strings.IndexByte(passcode, '\n'); idx >= 0 {
+		passcode = passcode[:idx]
+	}
+	passcode = strings.TrimSuffix(passcode, "\r")
+	if len(passcode) > maxLength {
+		return "", errors.New("the passcode is too long")
+	}
+	return passcode, nil
+}
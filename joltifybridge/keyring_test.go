@@ -0,0 +1,87 @@
+package joltifybridge
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestKey(t *testing.T, passcode string) (string, string, sdk.AccAddress) {
+	kr := keyring.NewInMemory()
+	info, mnemonic, err := kr.NewMnemonic("test", keyring.English, sdk.FullFundraiserPath, keyring.DefaultBIP39Passphrase, hd.Secp256k1)
+	require.NoError(t, err)
+	armor, err := kr.ExportPrivKeyArmor("test", passcode)
+	require.NoError(t, err)
+	return armor, mnemonic, info.GetAddress()
+}
+
+func TestImportOperatorKey(t *testing.T) {
+	folder := t.TempDir()
+	armor, mnemonic, addr := exportTestKey(t, "passcode1")
+	armorPath := path.Join(folder, "keyring.key")
+	require.NoError(t, ioutil.WriteFile(armorPath, []byte(armor), 0o600))
+	mnemonicPath := path.Join(folder, "mnemonic")
+	require.NoError(t, ioutil.WriteFile(mnemonicPath, []byte(mnemonic+"\n"), 0o600))
+
+	// the wrong passcode cannot decrypt the key
+	kr, err := NewKeyring(keyring.BackendMemory, folder, "wrong")
+	require.NoError(t, err)
+	_, err = ImportOperatorKey(kr, armorPath, "", "wrong")
+	require.True(t, errors.Is(err, ErrDecryptKey))
+
+	info, err := ImportOperatorKey(kr, armorPath, "", "passcode1")
+	require.NoError(t, err)
+	require.Equal(t, addr, info.GetAddress())
+
+	kr, err = NewKeyring(keyring.BackendMemory, folder, "")
+	require.NoError(t, err)
+	info, err = ImportOperatorKey(kr, armorPath, mnemonicPath, "")
+	require.NoError(t, err)
+	require.Equal(t, addr, info.GetAddress())
+
+	_, err = NewKeyring("unknown", folder, "")
+	require.Error(t, err)
+}
+
+func TestFileKeyring(t *testing.T) {
+	folder := t.TempDir()
+	armor, _, addr := exportTestKey(t, "passcode1")
+	armorPath := path.Join(folder, "keyring.key")
+	require.NoError(t, ioutil.WriteFile(armorPath, []byte(armor), 0o600))
+
+	kr, err := NewKeyring(keyring.BackendFile, folder, "passcode1")
+	require.NoError(t, err)
+	_, err = ImportOperatorKey(kr, armorPath, "", "passcode1")
+	require.NoError(t, err)
+
+	// the key is kept in the file keyring, so we do not need the armored key any more
+	kr, err = NewKeyring(keyring.BackendFile, folder, "passcode1")
+	require.NoError(t, err)
+	info, err := ImportOperatorKey(kr, path.Join(folder, "missing"), "", "passcode1")
+	require.NoError(t, err)
+	require.Equal(t, addr, info.GetAddress())
+}
+
+func TestReadPasscode(t *testing.T) {
+	passcode, err := ReadPasscode(strings.NewReader("12345678\n"), 32)
+	require.NoError(t, err)
+	require.Equal(t, "12345678", passcode)
+
+	passcode, err = ReadPasscode(strings.NewReader("12345678\r\n"), 32)
+	require.NoError(t, err)
+	require.Equal(t, "12345678", passcode)
+
+	passcode, err = ReadPasscode(strings.NewReader("12345678"), 32)
+	require.NoError(t, err)
+	require.Equal(t, "12345678", passcode)
+
+	_, err = ReadPasscode(strings.NewReader(strings.Repeat("a", 40)), 32)
+	require.Error(t, err)
+}
@@ -2,6 +2,9 @@ package joltifybridge
 
 import (
 	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
 	"gitlab.com/joltify/joltifychain-bridge/pubchain"
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
@@ -18,43 +21,72 @@ func prepareIssueTokenRequest(item *pubchain.InboundReq, creatorAddr, index stri
 	return a, nil
 }
 
+// MintResult is the result of one inbound request of a mint batch, the Err is nil if the mint is in the broadcast
+// tx or has been done by others, the TxHash is empty in the latter case
+type MintResult struct {
+	Item   *pubchain.InboundReq
+	Index  string
+	TxHash string
+	Err    error
+}
+
 // ProcessInBound mint the token in joltify chain
 func (jc *JoltifyChainInstance) ProcessInBound(item *pubchain.InboundReq) (string, string, error) {
+	_, _, _, height := item.GetInboundReqInfo()
+	txHash, results, err := jc.ProcessInBoundBatch([]*pubchain.InboundReq{item}, height, height, bcommon.Submitter{})
+	if err != nil {
+		return "", "", err
+	}
+	if results[0].Err != nil {
+		return "", "", results[0].Err
+	}
+	if txHash == "" {
+		return "", "", nil
+	}
+	return txHash, results[0].Index, nil
+}
+
+// ProcessInBoundBatch mints the tokens of the inbound requests in one tx signed with one keysign round at the given
+// height, the sequence of the tx is derived from the state of the joltify height seqHeight, the requests minted by
+// others already are skipped, only the leader of the submitters broadcasts the tx at once
+func (jc *JoltifyChainInstance) ProcessInBoundBatch(items []*pubchain.InboundReq, height, seqHeight int64, submitter bcommon.Submitter) (string, []MintResult, error) {
 	pool := jc.GetPool()
 	if pool[0] == nil {
 		jc.logger.Info().Msgf("fail to query the pool with length 1")
-		return "", "", errors.New("not enough signer")
+		return "", nil, errors.New("not enough signer")
 	}
 	// we need to get the address from the pubkey rather than the eth address
 	joltCreatorAddr, err := misc.PoolPubKeyToJoltAddress(pool[1].Pk)
 	if err != nil {
 		jc.logger.Info().Msgf("fail to convert the eth address to jolt address")
-		return "", "", errors.New("invalid address")
+		return "", nil, errors.New("invalid address")
 	}
 
-	// we always increase the account seq regardless the tx successful or not
-	acc, err := queryAccount(pool[1].JoltifyAddress.String(), jc.grpcClient)
-	if err != nil {
-		jc.logger.Error().Err(err).Msgf("fail to query the account")
-		return "", "", errors.New("invalid account query")
-	}
-	accSeq, accNum := acc.GetSequence(), acc.GetAccountNumber()
-	// we need to check against the previous account sequence
-	index := item.Hash().Hex()
-	if jc.CheckWhetherAlreadyExist(index) {
-		jc.logger.Warn().Msg("already submitted by others")
-		return "", "", nil
+	results := make([]MintResult, len(items))
+	var msgs []sdk.Msg
+	var included []int
+	for i, item := range items {
+		index := item.Hash().Hex()
+		results[i] = MintResult{Item: item, Index: index}
+		if jc.CheckWhetherAlreadyExist(index) {
+			jc.logger.Warn().Msgf("the inbound %v is already submitted by others", index)
+			continue
+		}
+		issueReq, err := prepareIssueTokenRequest(item, joltCreatorAddr.String(), index)
+		if err != nil {
+			jc.logger.Error().Err(err).Msg("fail to prepare the issuing of the token")
+			results[i].Err = err
+			continue
+		}
+		jc.logger.Info().Msgf("we do the top up for %v with index %v", issueReq.Receiver.String(), index)
+		msgs = append(msgs, issueReq)
+		included = append(included, i)
 	}
-
-	jc.logger.Info().Msgf("we are about to prepare the tx with other nodes with index %v", index)
-	issueReq, err := prepareIssueTokenRequest(item, joltCreatorAddr.String(), index)
-	if err != nil {
-		jc.logger.Error().Err(err).Msg("fail to prepare the issuing of the token")
-		return "", "", err
+	if len(msgs) == 0 {
+		return "", results, nil
 	}
 
-	_, _, _, height := item.GetInboundReqInfo()
-	jc.logger.Info().Msgf("we do the top up for %v at height %v", issueReq.Receiver.String(), height)
+	jc.logger.Info().Msgf("we are about to mint %v inbounds with other nodes at height %v", len(msgs), height)
 	signMsg := tssclient.TssSignigMsg{
 		Pk:          pool[1].Pk,
 		Signers:     nil,
@@ -62,10 +94,17 @@ func (jc *JoltifyChainInstance) ProcessInBound(item *pubchain.InboundReq) (strin
 		Version:     tssclient.TssVersion,
 	}
 
-	ok, txHash, err := jc.composeAndSend(issueReq, accSeq, accNum, &signMsg)
+	ok, txHash, err := jc.sendFromPool(pool[1].JoltifyAddress, msgs, &signMsg, seqHeight, submitter)
 	if err != nil || !ok {
 		jc.logger.Error().Err(err).Msgf("fail to broadcast the tx->%v", txHash)
-		return "", "", errors.New("fail to process the inbound tx")
+		// the msgs of a tx succeed or fail together
+		for _, i := range included {
+			results[i].Err = errors.New("fail to process the inbound tx")
+		}
+		return "", results, nil
+	}
+	for _, i := range included {
+		results[i].TxHash = txHash
 	}
-	return txHash, index, nil
+	return txHash, results, nil
 }
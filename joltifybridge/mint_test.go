@@ -111,7 +111,7 @@ func (m MintTestSuite) TestProcessInbound() {
 		true,
 		true,
 	}
-	jc, err := NewJoltifyBridge(m.network.Validators[0].APIAddress, m.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(m.network.Validators[0].APIAddress, m.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	m.Require().NoError(err)
 	jc.Keyring = m.validatorky
 
@@ -0,0 +1,71 @@
+package joltifybridge
+
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
+)
+
+// policyKey is the observation of the outbound checked before we sign its payout on the public chain
+func (jc *JoltifyChainInstance) policyKey(req *OutBoundReq) (string, bool) {
+	token, ok := jc.tokens.ByDenom(req.coin.Denom)
+	if !ok {
+		return "", false
+	}
+	amount, err := token.ToPubChain(req.coin.Amount)
+	if err != nil {
+		return "", false
+	}
+	return tssclient.PayoutKey(token.ChainID, req.fromPoolAddr, req.outReceiverAddress, token.Address, amount), true
+}
+
+// observeOutbound records the outbound we have seen on joltify ourselves
+func (jc *JoltifyChainInstance) observeOutbound(req *OutBoundReq) {
+	if jc.SignPolicy == nil {
+		return
+	}
+	if key, ok := jc.policyKey(req); ok {
+		jc.SignPolicy.Observe(key, req.txID)
+	}
+}
+
+// ForgetOutbound drops the observation of the paid outbound
+func (jc *JoltifyChainInstance) ForgetOutbound(req *OutBoundReq) {
+	jc.FinishInflight(req)
+	if jc.SignPolicy == nil {
+		return
+	}
+	if key, ok := jc.policyKey(req); ok {
+		jc.SignPolicy.Forget(key, req.txID)
+	}
+}
+
+// ForgetRetiredPool drops the observation of the retired pool once its fund has moved
+func (jc *JoltifyChainInstance) ForgetRetiredPool(pool *bcommon.PoolInfo) {
+	jc.SignPolicy.Forget(tssclient.RetiredPoolKey(pool.JoltifyAddress.String()), pool.Pk)
+}
+
+// txRequests rebuilds the requests of the msgs we are about to sign, the pool may only mint the observed inbounds
+// or move the fund of a retired pool to the current pool
+func (jc *JoltifyChainInstance) txRequests(msgs []sdk.Msg) ([]string, error) {
+	current := jc.GetPool()[1]
+	keys := make([]string, len(msgs))
+	for i, el := range msgs {
+		switch msg := el.(type) {
+		case *vaulttypes.MsgCreateIssueToken:
+			keys[i] = tssclient.MintKey(msg.Index, msg.Receiver, msg.Coin)
+		case *banktypes.MsgSend:
+			if current == nil || msg.ToAddress != current.JoltifyAddress.String() {
+				return nil, fmt.Errorf("%v is not the current pool", msg.ToAddress)
+			}
+			keys[i] = tssclient.RetiredPoolKey(msg.FromAddress)
+		default:
+			return nil, fmt.Errorf("unexpected msg %v", sdk.MsgTypeURL(el))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,54 @@
+package joltifybridge
+
+import (
+	"sync"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
+)
+
+func TestTxRequests(t *testing.T) {
+	misc.SetupBech32Prefix()
+	tokenAddr := "0xeB42ff4cA651c91EB248f8923358b6144c6B4b79"
+	registry, err := bcommon.NewTokenRegistry(56, []config.TokenConfig{{Address: tokenAddr, Denom: "JUSD", Decimals: 6}})
+	require.NoError(t, err)
+	tokens, err := bcommon.NewChainRegistry(registry)
+	require.NoError(t, err)
+	retired, current := sdk.AccAddress{1}, sdk.AccAddress{2}
+	jc := &JoltifyChainInstance{
+		lastTwoPools:     []*bcommon.PoolInfo{nil, {JoltifyAddress: current}},
+		poolUpdateLocker: &sync.RWMutex{},
+		tokens:           tokens,
+		SignPolicy:       tssclient.NewPolicy(),
+	}
+
+	coin := sdk.NewCoin("JUSD", sdk.NewInt(1))
+	mint, err := vaulttypes.NewMsgCreateIssueToken(current.String(), "0xab", coin.String(), sdk.AccAddress{3}.String())
+	require.NoError(t, err)
+	keys, err := jc.txRequests([]sdk.Msg{mint, banktypes.NewMsgSend(retired, current, sdk.NewCoins(coin))})
+	require.NoError(t, err)
+	require.Equal(t, []string{tssclient.MintKey("0xab", sdk.AccAddress{3}, coin), tssclient.RetiredPoolKey(retired.String())}, keys)
+
+	// the pool only sends its fund to the current pool
+	_, err = jc.txRequests([]sdk.Msg{banktypes.NewMsgSend(retired, sdk.AccAddress{3}, sdk.NewCoins(coin))})
+	require.Error(t, err)
+	_, err = jc.txRequests([]sdk.Msg{&banktypes.MsgMultiSend{}})
+	require.Error(t, err)
+
+	// the outbound is observed with the payout on the public chain in the token decimals
+	req := newOutboundReq("tx1", common.Address{3}, common.Address{4}, sdk.NewCoin("JUSD", sdk.NewIntWithDecimal(1, 18)), 10)
+	payout := tssclient.PayoutKey(56, common.Address{4}, common.Address{3}, common.HexToAddress(tokenAddr), sdk.NewIntWithDecimal(1, 6).BigInt())
+	require.Error(t, jc.SignPolicy.Expect("pk", []byte("msg"), payout))
+	jc.observeOutbound(&req)
+	require.NoError(t, jc.SignPolicy.Expect("pk", []byte("msg"), payout))
+	jc.ForgetOutbound(&req)
+	require.Error(t, jc.SignPolicy.Expect("pk", []byte("msg"), payout))
+}
@@ -0,0 +1,166 @@
+package joltifybridge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/rs/zerolog"
+	tendertypes "github.com/tendermint/tendermint/types"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+)
+
+// sequenceWindow is how many joltify heights we remember the sequences handed out at
+const sequenceWindow = 256
+
+// heightSequence is the sequences of one pool account handed out for the txs signed at one height
+type heightSequence struct {
+	accNum uint64
+	// base is the sequence of the account in the state of the height
+	base uint64
+	used uint64
+}
+
+// SequenceManager hands out the sequences of the pool accounts, the sequence only depends on the chain state at the
+// joltify height the tx is signed at and the txs signed from the account at the same height before, so all the
+// signers of a tx pick the same sequence whatever the broadcast of the earlier txs returned on each node
+type SequenceManager struct {
+	lock     sync.Mutex
+	query    func(addr string, height int64) (authtypes.AccountI, error)
+	accounts map[string]map[int64]*heightSequence
+	logger   zerolog.Logger
+}
+
+func newSequenceManager(logger zerolog.Logger, query func(addr string, height int64) (authtypes.AccountI, error)) *SequenceManager {
+	return &SequenceManager{
+		query:    query,
+		accounts: make(map[string]map[int64]*heightSequence),
+		logger:   logger,
+	}
+}
+
+// Next returns the account number and the sequence of the tx signed from the account at the joltify height, it is
+// the sequence of the account in the state of the height plus the number of the txs signed from the account at the
+// same height before. A tx of an earlier height that is not in the state of the height takes the same sequence, the
+// chain rejects our tx then and it is retried at a later height.
+func (sm *SequenceManager) Next(addr string, height int64) (uint64, uint64, error) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	heights, ok := sm.accounts[addr]
+	if !ok {
+		heights = make(map[int64]*heightSequence)
+		sm.accounts[addr] = heights
+	}
+	slot, ok := heights[height]
+	if !ok {
+		chainAcc, err := sm.query(addr, height)
+		if err != nil {
+			return 0, 0, err
+		}
+		slot = &heightSequence{accNum: chainAcc.GetAccountNumber(), base: chainAcc.GetSequence()}
+		heights[height] = slot
+		for h := range heights {
+			if h <= height-sequenceWindow {
+				delete(heights, h)
+			}
+		}
+	}
+	seq := slot.base + slot.used
+	slot.used++
+	return slot.accNum, seq, nil
+}
+
+// isSequenceMismatch returns true if the tx is rejected for its sequence, the simulation reports it as a plain
+// grpc error, so we match the message
+func isSequenceMismatch(err error) bool {
+	if err == nil {
+		return false
+	}
+	return sdkerrors.ErrWrongSequence.Is(err) || strings.Contains(err.Error(), sdkerrors.ErrWrongSequence.Error())
+}
+
+// checkTxError is the rejection of the tx by CheckTx, the tx is not in the chain and its sequence is not used
+type checkTxError struct {
+	codespace string
+	code      uint32
+	log       string
+}
+
+func (e *checkTxError) Error() string {
+	return fmt.Sprintf("the tx is rejected with code %v/%v: %v", e.codespace, e.code, e.log)
+}
+
+// broadcastError returns the error of the tx response with a non-zero code, the rejection by CheckTx is reported as
+// checkTxError, while a tx that failed in DeliverTx or is already in the mempool has used its sequence
+func broadcastError(resp *sdk.TxResponse) error {
+	if resp.Codespace == sdkerrors.RootCodespace {
+		switch resp.Code {
+		case sdkerrors.ErrWrongSequence.ABCICode():
+			return sdkerrors.Wrap(sdkerrors.ErrWrongSequence, resp.RawLog)
+		case sdkerrors.ErrTxInMempoolCache.ABCICode():
+			return nil
+		}
+	}
+	// the tx has no height unless it is in a block
+	if resp.Height == 0 {
+		return &checkTxError{codespace: resp.Codespace, code: resp.Code, log: resp.RawLog}
+	}
+	return nil
+}
+
+// sendFromPool signs the msgs with the pool key and broadcasts them in one tx with the sequence of the pool account
+// derived from the state of the joltify height seqHeight, all the pool members sign the tx while only the elected
+// submitter broadcasts it at once, the others take over in the background if the sequence is still not used after
+// their delay
+func (jc *JoltifyChainInstance) sendFromPool(poolAddr sdk.AccAddress, msgs []sdk.Msg, signMsg *tssclient.TssSignigMsg, seqHeight int64, submitter bcommon.Submitter) (bool, string, error) {
+	addr := poolAddr.String()
+	accNum, accSeq, err := jc.sequences.Next(addr, seqHeight)
+	if err != nil {
+		jc.logger.Error().Err(err).Msgf("fail to query the account %v", addr)
+		return false, "", err
+	}
+	if !submitter.IsLeader() {
+		txBytes, err := jc.composeTx(msgs, accSeq, accNum, signMsg)
+		if err != nil {
+			return false, "", err
+		}
+		go jc.takeOver(jc.ctx, addr, accSeq, txBytes, submitter.Delay())
+		return true, fmt.Sprintf("%X", tendertypes.Tx(txBytes).Hash()), nil
+	}
+	ok, resp, err := jc.composeAndSend(msgs, accSeq, accNum, signMsg)
+	if isSequenceMismatch(err) {
+		jc.logger.Warn().Msgf("the sequence %v of %v is taken by a tx not in the state of %v", accSeq, addr, seqHeight)
+	}
+	return ok, resp, err
+}
+
+// takeOver broadcasts the signed tx if the pool account has not used its sequence after the delay, which means the
+// members ranked before us failed to broadcast it
+func (jc *JoltifyChainInstance) takeOver(ctx context.Context, addr string, accSeq uint64, txBytes []byte, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	acc, err := jc.sequences.query(addr, 0)
+	if err == nil && acc.GetSequence() > accSeq {
+		return
+	}
+	jc.logger.Warn().Msgf("the tx with sequence %v of %v is not broadcast in time, we take over", accSeq, addr)
+	sendCtx, cancel := context.WithTimeout(ctx, grpcTimeout)
+	defer cancel()
+	ok, resp, err := jc.BroadcastTx(sendCtx, txBytes)
+	if err == nil && ok {
+		jc.logger.Info().Msgf("we have broadcast the tx %v of %v", resp, addr)
+		return
+	}
+	jc.logger.Error().Err(err).Msgf("fail to take over the tx with sequence %v of %v", accSeq, addr)
+}
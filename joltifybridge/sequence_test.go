@@ -0,0 +1,110 @@
+package joltifybridge
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequenceManager(t *testing.T) {
+	chainSeqs := map[int64]uint64{10: 5, 11: 7}
+	queries := 0
+	sm := newSequenceManager(zerolog.Nop(), func(addr string, height int64) (authtypes.AccountI, error) {
+		queries++
+		seq, ok := chainSeqs[height]
+		if !ok {
+			return nil, errors.New("unknown height")
+		}
+		return authtypes.NewBaseAccount(nil, nil, 9, seq), nil
+	})
+
+	// the txs signed at the same height take the sequences after the one in the state of the height
+	accNum, seq, err := sm.Next("pool", 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), accNum)
+	require.Equal(t, uint64(5), seq)
+	_, seq, err = sm.Next("pool", 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), seq)
+	require.Equal(t, 1, queries)
+
+	// a later height starts from its own state, whatever happened to the txs before
+	_, seq, err = sm.Next("pool", 11)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), seq)
+	require.Equal(t, 2, queries)
+
+	// each account has its own sequence
+	chainSeqs[11] = 12
+	_, seq, err = sm.Next("other", 11)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), seq)
+	_, seq, err = sm.Next("pool", 11)
+	require.NoError(t, err)
+	require.Equal(t, uint64(8), seq)
+
+	_, _, err = sm.Next("pool", 12)
+	require.Error(t, err)
+}
+
+func TestSequenceAgreement(t *testing.T) {
+	// the leader sees its tx rejected while the follower does not, both still pick the same sequences
+	query := func(addr string, height int64) (authtypes.AccountI, error) {
+		return authtypes.NewBaseAccount(nil, nil, 9, uint64(height)), nil
+	}
+	leader := newSequenceManager(zerolog.Nop(), query)
+	follower := newSequenceManager(zerolog.Nop(), query)
+
+	rejected := broadcastError(&sdk.TxResponse{Codespace: sdkerrors.RootCodespace, Code: sdkerrors.ErrInsufficientFee.ABCICode()})
+	require.Error(t, rejected)
+	require.False(t, isSequenceMismatch(rejected))
+	for _, height := range []int64{20, 20, 21} {
+		_, a, err := leader.Next("pool", height)
+		require.NoError(t, err)
+		_, b, err := follower.Next("pool", height)
+		require.NoError(t, err)
+		require.Equal(t, a, b)
+	}
+
+	// the tx failed in DeliverTx or already in the mempool has used its sequence
+	require.NoError(t, broadcastError(&sdk.TxResponse{Codespace: sdkerrors.RootCodespace, Code: sdkerrors.ErrOutOfGas.ABCICode(), Height: 10}))
+	require.NoError(t, broadcastError(&sdk.TxResponse{Codespace: sdkerrors.RootCodespace, Code: sdkerrors.ErrTxInMempoolCache.ABCICode()}))
+	mismatch := broadcastError(&sdk.TxResponse{Codespace: sdkerrors.RootCodespace, Code: sdkerrors.ErrWrongSequence.ABCICode()})
+	require.True(t, isSequenceMismatch(mismatch))
+}
+
+func TestSearchHeight(t *testing.T) {
+	base := time.Unix(1000, 0)
+	// the block at height h is at base+5h seconds
+	queried := map[int64]bool{}
+	blockTime := func(height int64) (time.Time, error) {
+		queried[height] = true
+		return base.Add(time.Duration(height*5) * time.Second), nil
+	}
+	height, err := searchHeight(1000, base.Add(4990*time.Second), blockTime)
+	require.NoError(t, err)
+	require.Equal(t, int64(998), height)
+	height, err = searchHeight(1000, base.Add(4000*time.Second+time.Second), blockTime)
+	require.NoError(t, err)
+	require.Equal(t, int64(800), height)
+	// only the recent blocks are queried
+	for h := range queried {
+		require.Greater(t, h, int64(500))
+	}
+
+	_, err = searchHeight(1000, base, blockTime)
+	require.Error(t, err)
+}
+
+func TestIsSequenceMismatch(t *testing.T) {
+	require.False(t, isSequenceMismatch(nil))
+	require.False(t, isSequenceMismatch(errors.New("insufficient fee")))
+	require.True(t, isSequenceMismatch(sdkerrors.Wrap(sdkerrors.ErrWrongSequence, "account sequence mismatch, expected 5, got 4")))
+	require.True(t, isSequenceMismatch(errors.New("rpc error: code = Unknown desc = account sequence mismatch, expected 5, got 4: incorrect account sequence")))
+}
@@ -0,0 +1,128 @@
+package joltifybridge
+
+import (
+	"encoding/json"
+	"strconv"
+
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+)
+
+// persist writes the item through to the state store if the store is enabled
+func (jc *JoltifyChainInstance) persist(bucket, key string, value interface{}) {
+	if jc.stateStore == nil {
+		return
+	}
+	err := jc.stateStore.Put(bucket, key, value)
+	if err != nil {
+		jc.logger.Error().Err(err).Msgf("fail to persist the item %v in %v", key, bucket)
+	}
+}
+
+// unpersist removes the item from the state store if the store is enabled
+func (jc *JoltifyChainInstance) unpersist(bucket, key string) {
+	if jc.stateStore == nil {
+		return
+	}
+	err := jc.stateStore.Delete(bucket, key)
+	if err != nil {
+		jc.logger.Error().Err(err).Msgf("fail to delete the item %v in %v", key, bucket)
+	}
+}
+
+// RestoreState reloads the outbound retry and move fund queues from the state store
+func (jc *JoltifyChainInstance) RestoreState() error {
+	if jc.stateStore == nil {
+		return nil
+	}
+	err := jc.stateStore.Iterate(storage.RetryOutboundBucket, func(key string, value []byte) error {
+		var req OutBoundReq
+		if err := json.Unmarshal(value, &req); err != nil {
+			return err
+		}
+		jc.RetryOutboundReq.Store(req.Hash().Big(), &req)
+		jc.observeOutbound(&req)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the outbounds handed to the processing when the bridge stopped go back to the retry queue, unless their txs
+	// were signed, then the txs are checked first so we do not pay them twice
+	var inflight []*OutBoundReq
+	err = jc.stateStore.Iterate(storage.InflightOutboundBucket, func(key string, value []byte) error {
+		var item SentOutbound
+		if err := json.Unmarshal(value, &item); err != nil {
+			return err
+		}
+		jc.observeOutbound(item.Req)
+		if item.TxHash != "" {
+			jc.sentOutbounds.Store(key, item)
+			return nil
+		}
+		inflight = append(inflight, item.Req)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, el := range inflight {
+		jc.AddItem(el)
+	}
+
+	// the dropped outbounds are not observed, so they cannot be signed until they are requeued
+	err = jc.stateStore.Iterate(storage.DroppedOutboundBucket, func(key string, value []byte) error {
+		var req OutBoundReq
+		if err := json.Unmarshal(value, &req); err != nil {
+			return err
+		}
+		jc.droppedOutbounds.Store(key, &req)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = jc.stateStore.Iterate(storage.JoltMoveFundBucket, func(key string, value []byte) error {
+		height, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return err
+		}
+		var pool bcommon.PoolInfo
+		if err := json.Unmarshal(value, &pool); err != nil {
+			return err
+		}
+		jc.moveFundReq.Store(height, &pool)
+		jc.SignPolicy.Observe(tssclient.RetiredPoolKey(pool.JoltifyAddress.String()), pool.Pk)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = jc.stateStore.Iterate(storage.JoltOutboundTxBucket, func(key string, value []byte) error {
+		var height int64
+		if err := json.Unmarshal(value, &height); err != nil {
+			return err
+		}
+		jc.outboundTxSeen.Store(key, height)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = jc.stateStore.Iterate(storage.JoltRetryTxBucket, func(key string, value []byte) error {
+		var item retryTx
+		if err := json.Unmarshal(value, &item); err != nil {
+			return err
+		}
+		jc.outboundTxRetry.Store(key, item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	jc.logger.Info().Msgf("we have restored %v retry outbound items from the state store", jc.Size())
+	return nil
+}
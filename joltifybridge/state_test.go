@@ -0,0 +1,156 @@
+package joltifybridge
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+func newStateTestInstance(store *storage.StateStore) *JoltifyChainInstance {
+	return &JoltifyChainInstance{
+		logger:           log.With().Str("module", "joltifyChain").Logger(),
+		RetryOutboundReq: &sync.Map{},
+		moveFundReq:      &sync.Map{},
+		droppedOutbounds: &sync.Map{},
+		sentOutbounds:    &sync.Map{},
+		outboundTxSeen:   &sync.Map{},
+		outboundTxRetry:  &sync.Map{},
+		stateStore:       store,
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	jc := newStateTestInstance(store)
+
+	req1 := newOutboundReq("tx1", accs[0].commAddr, accs[1].commAddr, sdk.NewCoin("test", sdk.NewInt(10)), 100)
+	req2 := newOutboundReq("tx2", accs[0].commAddr, accs[1].commAddr, sdk.NewCoin("test", sdk.NewInt(20)), 101)
+	jc.AddItem(&req1)
+	jc.AddItem(&req2)
+
+	pool := bcommon.PoolInfo{
+		Pk:             accs[2].pk,
+		JoltifyAddress: accs[2].joltAddr,
+		EthAddress:     accs[2].commAddr,
+	}
+	jc.AddMoveFundItem(&pool, 10)
+	jc.AddMoveFundItem(&pool, 20)
+	// the first move fund request is handled before the bridge is killed
+	_, height := jc.PopMoveFundItem()
+	require.Equal(t, int64(10), height)
+
+	// now we kill the bridge and restart it with the same state store
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newStateTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+
+	require.Equal(t, 2, restarted.Size())
+	hashes := map[string]bool{req1.Hash().Hex(): true, req2.Hash().Hex(): true}
+	for i := 0; i < 2; i++ {
+		item := restarted.PopItem()
+		require.NotNil(t, item)
+		require.True(t, hashes[item.Hash().Hex()])
+		to, from, _, _ := item.GetOutBoundInfo()
+		require.Equal(t, accs[0].commAddr, to)
+		require.Equal(t, accs[1].commAddr, from)
+	}
+	require.Nil(t, restarted.PopItem())
+
+	restoredPool, height := restarted.PopMoveFundItem()
+	require.Equal(t, int64(20), height)
+	require.True(t, restoredPool.JoltifyAddress.Equals(accs[2].joltAddr))
+	restoredPool, _ = restarted.PopMoveFundItem()
+	require.Nil(t, restoredPool)
+}
+
+func TestPopItemsMatching(t *testing.T) {
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	jc := newStateTestInstance(store)
+	for i, denom := range []string{"JUSD", "JUSD", "EJUSD", "JUSD"} {
+		req := newOutboundReq(fmt.Sprintf("tx%v", i), accs[0].commAddr, accs[1].commAddr, sdk.NewCoin(denom, sdk.NewInt(10)), 100)
+		jc.AddItem(&req)
+	}
+	isJUSD := func(req *OutBoundReq) bool {
+		_, _, coin, _ := req.GetOutBoundInfo()
+		return coin.Denom == "JUSD"
+	}
+
+	items := jc.PopItemsMatching(2, isJUSD)
+	require.Len(t, items, 2)
+	require.Equal(t, 1, items[0].Hash().Big().Cmp(items[1].Hash().Big()))
+	paid := items
+	items = jc.PopItemsMatching(2, isJUSD)
+	require.Len(t, items, 1)
+	require.Len(t, jc.PopItemsMatching(2, isJUSD), 0)
+
+	// the popped items are removed from the state store once they are paid
+	for _, el := range append(paid, items...) {
+		jc.ForgetOutbound(el)
+	}
+	restarted := newStateTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+	require.Equal(t, 1, restarted.Size())
+	_, _, coin, _ := restarted.PopItem().GetOutBoundInfo()
+	require.Equal(t, "EJUSD", coin.Denom)
+}
+
+func TestInflightOutbounds(t *testing.T) {
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	jc := newStateTestInstance(store)
+	jc.OutboundReqChan = make(chan *OutBoundReq, 3)
+
+	var reqs []OutBoundReq
+	for i := 0; i < 3; i++ {
+		reqs = append(reqs, newOutboundReq(fmt.Sprintf("tx%v", i), accs[0].commAddr, accs[1].commAddr, sdk.NewCoin("test", sdk.NewInt(10)), 100))
+		jc.SendOutbound(&reqs[i])
+	}
+	// the first outbound is paid, the second one is signed and the last one is still waiting for its keysign
+	jc.ForgetOutbound(&reqs[0])
+	jc.MarkOutboundSent(&reqs[1], "0xabc")
+
+	// now we kill the bridge and restart it with the same state store
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newStateTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+
+	require.Equal(t, 1, restarted.Size())
+	require.Equal(t, reqs[2].Hash().Hex(), restarted.PopItem().Hash().Hex())
+	sent := restarted.PopSentOutbounds()
+	require.Len(t, sent, 1)
+	require.Equal(t, "0xabc", sent[0].TxHash)
+	require.Equal(t, reqs[1].Hash().Hex(), sent[0].Req.Hash().Hex())
+	require.Len(t, restarted.PopSentOutbounds(), 0)
+}
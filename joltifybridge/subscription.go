@@ -0,0 +1,268 @@
+package joltifybridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/rs/zerolog"
+	tmclienthttp "github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+)
+
+const (
+	QueryValidatorUpdate = "tm.event = 'ValidatorSetUpdates'"
+	QueryNewBlock        = "tm.event = 'NewBlock'"
+	QueryTx              = "tm.event = 'Tx'"
+
+	subscribeTimeout = time.Second * 5
+	subscriberName   = "joltifyBridge"
+)
+
+// eventSubscriber is the part of the tendermint client used by the subscriptions
+type eventSubscriber interface {
+	Subscribe(ctx context.Context, subscriber, query string, outCapacity ...int) (<-chan ctypes.ResultEvent, error)
+	UnsubscribeAll(ctx context.Context, subscriber string) error
+}
+
+// SubscriptionManager keeps the subscriptions of the joltify chain alive. The events are forwarded to
+// the channels of the manager, so the consumers are not affected when we reconnect the chain.
+type SubscriptionManager struct {
+	logger              zerolog.Logger
+	client              eventSubscriber
+	dial                func() (eventSubscriber, error)
+	stallTimeout        time.Duration
+	ValidatorUpdateChan chan ctypes.ResultEvent
+	NewBlockChan        chan ctypes.ResultEvent
+	TxChan              chan ctypes.ResultEvent
+	Metric              *monitor.Metric
+	reconnectCount      int64
+	healthy             int32
+	lastBlockTime       int64 // the unix nano time we received the last block
+}
+
+// getWsClient returns the tendermint client, the client may be replaced after reconnection
+func (jc *JoltifyChainInstance) getWsClient() *tmclienthttp.HTTP {
+	jc.wsLocker.RLock()
+	defer jc.wsLocker.RUnlock()
+	return jc.wsClient
+}
+
+// redial replaces the tendermint client with a new connection to the joltify chain
+func (jc *JoltifyChainInstance) redial() (eventSubscriber, error) {
+	client, err := tmclienthttp.New(jc.wsAddress, "/websocket")
+	if err != nil {
+		return nil, err
+	}
+	err = client.Start()
+	if err != nil {
+		return nil, err
+	}
+	jc.wsLocker.Lock()
+	oldClient := jc.wsClient
+	jc.wsClient = client
+	jc.wsLocker.Unlock()
+	if oldClient != nil && oldClient.IsRunning() {
+		if err := oldClient.Stop(); err != nil {
+			jc.logger.Warn().Err(err).Msg("fail to stop the old ws client")
+		}
+	}
+	return client, nil
+}
+
+// NewSubscriptionManager creates the subscription manager on the current ws client, if no new block
+// arrives in the stall timeout, we reconnect the chain. Zero stall timeout disables the check.
+func (jc *JoltifyChainInstance) NewSubscriptionManager(stallTimeout time.Duration) *SubscriptionManager {
+	return newSubscriptionManager(jc.logger, jc.getWsClient(), jc.redial, stallTimeout)
+}
+
+func newSubscriptionManager(logger zerolog.Logger, client eventSubscriber, dial func() (eventSubscriber, error), stallTimeout time.Duration) *SubscriptionManager {
+	return &SubscriptionManager{
+		logger:              logger,
+		client:              client,
+		dial:                dial,
+		stallTimeout:        stallTimeout,
+		ValidatorUpdateChan: make(chan ctypes.ResultEvent, capacity),
+		NewBlockChan:        make(chan ctypes.ResultEvent, capacity),
+		TxChan:              make(chan ctypes.ResultEvent, capacity),
+	}
+}
+
+// Healthy returns true if the subscriptions are working
+func (sm *SubscriptionManager) Healthy() bool {
+	return atomic.LoadInt32(&sm.healthy) == 1
+}
+
+// ReconnectCount returns how many times we have reconnected to the joltify chain
+func (sm *SubscriptionManager) ReconnectCount() int64 {
+	return atomic.LoadInt64(&sm.reconnectCount)
+}
+
+// StallTimeout returns how long we wait for a new block before we reconnect the joltify chain, zero disables the check
+func (sm *SubscriptionManager) StallTimeout() time.Duration {
+	return sm.stallTimeout
+}
+
+// LastBlockTime returns when we received the last block of the joltify chain, it is zero if no block is received yet
+func (sm *SubscriptionManager) LastBlockTime() time.Time {
+	last := atomic.LoadInt64(&sm.lastBlockTime)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+func (sm *SubscriptionManager) setHealthy(healthy bool) {
+	var val int32
+	if healthy {
+		val = 1
+	}
+	old := atomic.SwapInt32(&sm.healthy, val)
+	if old != val {
+		sm.logger.Info().Msgf("the joltify subscription healthy status changes to %v", healthy)
+	}
+}
+
+// subscribeAll registers the three queries on the client
+func (sm *SubscriptionManager) subscribeAll(ctx context.Context) ([]<-chan ctypes.ResultEvent, error) {
+	ctxLocal, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	defer cancel()
+	var channels []<-chan ctypes.ResultEvent
+	for _, query := range []string{QueryValidatorUpdate, QueryNewBlock, QueryTx} {
+		out, err := sm.client.Subscribe(ctxLocal, subscriberName, query, capacity)
+		if err != nil {
+			sm.logger.Error().Err(err).Msgf("fail to subscribe the query %v", query)
+			return nil, err
+		}
+		channels = append(channels, out)
+	}
+	return channels, nil
+}
+
+// resubscribe reconnects the chain and registers the queries again, it retries until it succeeds or
+// the context is cancelled
+func (sm *SubscriptionManager) resubscribe(ctx context.Context) ([]<-chan ctypes.ResultEvent, error) {
+	sm.setHealthy(false)
+	ctxLocal, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	err := sm.client.UnsubscribeAll(ctxLocal, subscriberName)
+	cancel()
+	if err != nil {
+		sm.logger.Warn().Err(err).Msg("fail to unsubscribe the broken subscriptions")
+	}
+
+	bf := backoff.NewExponentialBackOff()
+	bf.InitialInterval = time.Second
+	bf.MaxInterval = time.Second * 30
+	bf.MaxElapsedTime = 0
+
+	var channels []<-chan ctypes.ResultEvent
+	op := func() error {
+		client, err := sm.dial()
+		if err != nil {
+			sm.logger.Error().Err(err).Msg("fail to redial the joltify chain")
+			return err
+		}
+		sm.client = client
+		channels, err = sm.subscribeAll(ctx)
+		return err
+	}
+	err = backoff.Retry(op, backoff.WithContext(bf, ctx))
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&sm.reconnectCount, 1)
+	sm.Metric.AddJoltifyReconnect()
+	sm.setHealthy(true)
+	return channels, nil
+}
+
+// Start subscribes the queries and keeps forwarding the events until the context is cancelled. If any
+// subscription channel is closed or no new block arrives in the stall timeout, we reconnect the chain.
+// The outbound txs in the blocks missed during the reconnection are picked up by CatchUpOutbound.
+func (sm *SubscriptionManager) Start(ctx context.Context, wg *sync.WaitGroup) error {
+	channels, err := sm.subscribeAll(ctx)
+	if err != nil {
+		return err
+	}
+	if len(channels) != 3 {
+		return errors.New("incorrect number of subscriptions")
+	}
+	sm.setHealthy(true)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer sm.setHealthy(false)
+
+		var stallC <-chan time.Time
+		var stallTimer *time.Timer
+		if sm.stallTimeout > 0 {
+			stallTimer = time.NewTimer(sm.stallTimeout)
+			defer stallTimer.Stop()
+			stallC = stallTimer.C
+		}
+		resetStall := func() {
+			if stallTimer == nil {
+				return
+			}
+			if !stallTimer.Stop() {
+				select {
+				case <-stallTimer.C:
+				default:
+				}
+			}
+			stallTimer.Reset(sm.stallTimeout)
+		}
+
+		for {
+			var event ctypes.ResultEvent
+			var out chan ctypes.ResultEvent
+			ok := true
+			reconnect := false
+			select {
+			case <-ctx.Done():
+				sm.logger.Info().Msgf("shutdown the joltify subscriptions")
+				return
+			case event, ok = <-channels[0]:
+				out = sm.ValidatorUpdateChan
+			case event, ok = <-channels[1]:
+				out = sm.NewBlockChan
+				atomic.StoreInt64(&sm.lastBlockTime, time.Now().UnixNano())
+				resetStall()
+			case event, ok = <-channels[2]:
+				out = sm.TxChan
+			case <-stallC:
+				sm.logger.Warn().Msgf("no new block from the joltify chain in %v", sm.stallTimeout)
+				reconnect = true
+			}
+
+			if !ok {
+				sm.logger.Error().Msg("the joltify subscription is closed")
+				reconnect = true
+			}
+
+			if reconnect {
+				channels, err = sm.resubscribe(ctx)
+				if err != nil {
+					sm.logger.Info().Msgf("shutdown the joltify subscriptions")
+					return
+				}
+				sm.logger.Info().Msgf("we have reconnected to the joltify chain")
+				resetStall()
+				continue
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				sm.logger.Info().Msgf("shutdown the joltify subscriptions")
+				return
+			}
+		}
+	}()
+	return nil
+}
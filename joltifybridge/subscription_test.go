@@ -0,0 +1,135 @@
+package joltifybridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// fakeSubscriber hands out the channels of the subscriptions so the test can close or feed them
+type fakeSubscriber struct {
+	lock     sync.Mutex
+	channels map[string]chan ctypes.ResultEvent
+	failSub  bool
+}
+
+func newFakeSubscriber() *fakeSubscriber {
+	return &fakeSubscriber{channels: make(map[string]chan ctypes.ResultEvent)}
+}
+
+func (f *fakeSubscriber) Subscribe(_ context.Context, _, query string, _ ...int) (<-chan ctypes.ResultEvent, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.failSub {
+		return nil, errors.New("fail to subscribe")
+	}
+	out := make(chan ctypes.ResultEvent, 10)
+	f.channels[query] = out
+	return out, nil
+}
+
+func (f *fakeSubscriber) UnsubscribeAll(_ context.Context, _ string) error {
+	return nil
+}
+
+func (f *fakeSubscriber) send(query string, event ctypes.ResultEvent) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.channels[query] <- event
+}
+
+func (f *fakeSubscriber) close(query string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	close(f.channels[query])
+}
+
+func waitEvent(t *testing.T, ch chan ctypes.ResultEvent) ctypes.ResultEvent {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second * 5):
+		t.Fatalf("timeout in waiting for the event")
+	}
+	return ctypes.ResultEvent{}
+}
+
+func TestSubscriptionManagerReconnect(t *testing.T) {
+	first := newFakeSubscriber()
+	second := newFakeSubscriber()
+	dialed := 0
+	dial := func() (eventSubscriber, error) {
+		dialed++
+		if dialed == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return second, nil
+	}
+	sm := newSubscriptionManager(log.Logger, first, dial, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	require.NoError(t, sm.Start(ctx, &wg))
+	require.True(t, sm.Healthy())
+	require.True(t, sm.LastBlockTime().IsZero())
+
+	first.send(QueryNewBlock, ctypes.ResultEvent{Query: "block1"})
+	first.send(QueryTx, ctypes.ResultEvent{Query: "tx1"})
+	require.Equal(t, "block1", waitEvent(t, sm.NewBlockChan).Query)
+	require.WithinDuration(t, time.Now(), sm.LastBlockTime(), time.Second)
+	require.Equal(t, "tx1", waitEvent(t, sm.TxChan).Query)
+
+	// the websocket drops, the first redial fails and we retry
+	first.close(QueryTx)
+	require.Eventually(t, func() bool {
+		return sm.ReconnectCount() == 1
+	}, time.Second*10, time.Millisecond*50)
+	require.True(t, sm.Healthy())
+
+	second.send(QueryValidatorUpdate, ctypes.ResultEvent{Query: "vals"})
+	second.send(QueryTx, ctypes.ResultEvent{Query: "tx2"})
+	require.Equal(t, "vals", waitEvent(t, sm.ValidatorUpdateChan).Query)
+	require.Equal(t, "tx2", waitEvent(t, sm.TxChan).Query)
+
+	cancel()
+	wg.Wait()
+	require.False(t, sm.Healthy())
+}
+
+func TestSubscriptionManagerStall(t *testing.T) {
+	first := newFakeSubscriber()
+	second := newFakeSubscriber()
+	dial := func() (eventSubscriber, error) {
+		return second, nil
+	}
+	sm := newSubscriptionManager(log.Logger, first, dial, time.Millisecond*300)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	require.NoError(t, sm.Start(ctx, &wg))
+
+	// no block arrives so we reconnect
+	require.Eventually(t, func() bool {
+		return sm.ReconnectCount() >= 1
+	}, time.Second*5, time.Millisecond*50)
+	second.send(QueryNewBlock, ctypes.ResultEvent{Query: "block2"})
+	require.Equal(t, "block2", waitEvent(t, sm.NewBlockChan).Query)
+	cancel()
+	wg.Wait()
+}
+
+func TestSubscriptionManagerFailToStart(t *testing.T) {
+	first := newFakeSubscriber()
+	first.failSub = true
+	sm := newSubscriptionManager(log.Logger, first, nil, 0)
+	wg := sync.WaitGroup{}
+	require.Error(t, sm.Start(context.Background(), &wg))
+	require.False(t, sm.Healthy())
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/big"
 	"strings"
 
 	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
@@ -14,35 +13,63 @@ import (
 	"github.com/cosmos/cosmos-sdk/types"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	ethcommon "github.com/ethereum/go-ethereum/common"
-	"gitlab.com/joltify/joltifychain-bridge/config"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func (jc *JoltifyChainInstance) processMsg(blockHeight int64, address []types.AccAddress, curEthAddr ethcommon.Address, msg *banktypes.MsgSend, txHash []byte) error {
-	txID := strings.ToLower(hex.EncodeToString(txHash))
+// errQueryAccount is returned if we fail to query the sender account for a reason that may go away, the outbound is
+// processed again later
+var errQueryAccount = errors.New("fail to query the account")
 
-	toAddress, err := types.AccAddressFromBech32(msg.ToAddress)
-	if err != nil {
-		jc.logger.Error().Err(err).Msg("fail to parse the to outReceiverAddress")
-		return err
+// transientQueryError tells whether the grpc query may succeed if we try it again
+func transientQueryError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// outboundReceiver returns the ETH address the outbound is paid to, it is the address in the memo, or the address of
+// the sender pubkey if the memo is empty
+func (jc *JoltifyChainInstance) outboundReceiver(from, memo string) (ethcommon.Address, error) {
+	if strings.TrimSpace(memo) != "" {
+		addr, err := misc.ParseEthAddress(memo)
+		if err != nil {
+			return ethcommon.Address{}, fmt.Errorf("invalid receiver %q in the memo: %w", memo, err)
+		}
+		return addr, nil
 	}
 
 	// here we need to calculate the node's eth address from public key rather than the joltify chain address
-	acc, err := queryAccount(msg.FromAddress, jc.grpcClient)
+	acc, err := queryAccount(from, jc.grpcClient)
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("Fail to query the account")
-		return err
+		if transientQueryError(err) {
+			return ethcommon.Address{}, fmt.Errorf("%w: %v", errQueryAccount, err)
+		}
+		return ethcommon.Address{}, err
+	}
+	if acc.GetPubKey() == nil {
+		return ethcommon.Address{}, errors.New("the sender has no pubkey, the receiver must be set in the memo")
 	}
-
 	fromEthAddr, err := misc.AccountPubKeyToEthAddress(acc.GetPubKey())
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("Fail to get the eth address")
-		return err
+		return ethcommon.Address{}, err
 	}
-	// now we wrap the fromEthAddress with joltify hex address
-	wrapFromEthAddr, err := types.AccAddressFromHex(fromEthAddr.Hex()[2:])
+	return fromEthAddr, nil
+}
+
+func (jc *JoltifyChainInstance) processMsg(blockHeight int64, address []types.AccAddress, curEthAddr ethcommon.Address, msg *banktypes.MsgSend, memo string, txHash []byte) error {
+	txID := strings.ToLower(hex.EncodeToString(txHash))
+
+	toAddress, err := types.AccAddressFromBech32(msg.ToAddress)
 	if err != nil {
-		jc.logger.Error().Err(err).Msg("Fail to wrap the eth address")
+		jc.logger.Error().Err(err).Msg("fail to parse the to outReceiverAddress")
 		return err
 	}
 
@@ -51,59 +78,81 @@ func (jc *JoltifyChainInstance) processMsg(blockHeight int64, address []types.Ac
 		jc.logger.Warn().Msg("not a top up message to the pool")
 		return errors.New("not a top up message to the pool")
 	}
+	jc.Transfers.Observe(txID, monitor.Outbound, monitor.JoltifyChain)
+
+	receiver, err := jc.outboundReceiver(msg.FromAddress, memo)
+	if err != nil {
+		jc.Transfers.Fail(txID, err)
+		return err
+	}
 
 	// it means the sender pay the fee in one tx
 	if len(msg.Amount) == 2 {
-		// now we search for the index of the outboundemo and the outbounddemofee
+		// now we search for the index of the bridged token and the outbounddemofee
 		found := false
 		indexDemo := 0
 		indexDemoFee := 0
-		if msg.Amount[0].GetDenom() == config.OutBoundDenom && msg.Amount[1].GetDenom() == config.OutBoundDenomFee {
-			indexDemo = 0
-			indexDemoFee = 1
-			found = true
-		}
-
-		if msg.Amount[1].GetDenom() == config.OutBoundDenom && msg.Amount[0].GetDenom() == config.OutBoundDenomFee {
-			indexDemo = 1
-			indexDemoFee = 0
-			found = true
+		var token *bcommon.TokenInfo
+		var minFee types.Coin
+		for i := 0; i < 2; i++ {
+			tokenInfo, isToken := jc.tokens.ByDenom(msg.Amount[i].GetDenom())
+			if !isToken {
+				continue
+			}
+			// the fee is set by the chain the token is paid out on
+			chainFee, ok := jc.outboundFee(tokenInfo)
+			if ok && msg.Amount[1-i].GetDenom() == chainFee.Denom {
+				indexDemo = i
+				indexDemoFee = 1 - i
+				token = tokenInfo
+				minFee = chainFee
+				found = true
+			}
 		}
 		if !found {
+			jc.Transfers.Fail(txID, errors.New("invalid fee pair"))
 			return errors.New("invalid fee pair")
 		}
+		// the token on the public chain may have fewer decimals, we do not pay the outbound with its dust dropped
+		if _, err := token.ToPubChain(msg.Amount[indexDemo].Amount); err != nil {
+			jc.Transfers.Fail(txID, err)
+			return err
+		}
 
-		item := jc.processDemonAndFee(txID, blockHeight, wrapFromEthAddr, msg.Amount[indexDemo].Amount, msg.Amount[indexDemoFee].Amount)
-		// since the cosmos address is different from the eth address, we need to derive the eth address from the public key
+		item := jc.processDemonAndFee(txID, blockHeight, receiver, msg.Amount[indexDemo], msg.Amount[indexDemoFee], minFee)
 		if item != nil {
 			itemReq := newOutboundReq(txID, item.outReceiverAddress, curEthAddr, item.token, blockHeight)
+			jc.observeOutbound(&itemReq)
+			jc.Transfers.Update(txID, monitor.TransferFeeMatched)
 			jc.AddItem(&itemReq)
 			return nil
 		}
+		jc.Transfers.Fail(txID, errors.New("not enough fee"))
 		return errors.New("not enough fee")
 	}
 
+	jc.Transfers.Fail(txID, errors.New("we only allow fee and top up in one tx now"))
 	return errors.New("we only allow fee and top up in one tx now")
 }
 
-func (jc *JoltifyChainInstance) processDemonAndFee(txID string, blockHeight int64, fromAddress types.AccAddress, DemonAmount, feeAmount types.Int) *outboundTx {
-	token := types.Coin{
-		Denom:  config.OutBoundDenom,
-		Amount: DemonAmount,
-	}
-	fee := types.Coin{
-		Denom:  config.OutBoundDenomFee,
-		Amount: feeAmount,
+// outboundFee returns the minimum fee of the outbounds paid out in the token
+func (jc *JoltifyChainInstance) outboundFee(token *bcommon.TokenInfo) (types.Coin, bool) {
+	chain, ok := jc.tokens.Chain(token.ChainID)
+	if !ok {
+		return types.Coin{}, false
 	}
+	return chain.OutboundFee()
+}
 
+func (jc *JoltifyChainInstance) processDemonAndFee(txID string, blockHeight int64, receiver ethcommon.Address, token, fee, minFee types.Coin) *outboundTx {
 	tx := outboundTx{
-		ethcommon.BytesToAddress(fromAddress.Bytes()),
+		receiver,
 		uint64(blockHeight),
 		token,
 		fee,
 	}
 	jc.logger.Info().Msgf("we add the outbound tokens tx(%v):%v", txID, tx.token.String())
-	err := tx.Verify()
+	err := tx.Verify(minFee)
 	if err != nil {
 		return nil
 	}
@@ -146,6 +195,8 @@ func (jc *JoltifyChainInstance) UpdatePool(pool *vaulttypes.PoolInfo) *bcommon.P
 
 	if jc.lastTwoPools[1] != nil {
 		jc.lastTwoPools[0] = jc.lastTwoPools[1]
+		// the fund of the retired pool moves to the new pool
+		jc.SignPolicy.Observe(tssclient.RetiredPoolKey(jc.lastTwoPools[0].JoltifyAddress.String()), jc.lastTwoPools[0].Pk)
 	}
 	jc.lastTwoPools[1] = &p
 	jc.poolUpdateLocker.Unlock()
@@ -154,11 +205,6 @@ func (jc *JoltifyChainInstance) UpdatePool(pool *vaulttypes.PoolInfo) *bcommon.P
 
 func (jc *JoltifyChainInstance) MoveFunds(fromPool *bcommon.PoolInfo, to types.AccAddress, height int64) (bool, error) {
 	from := fromPool.JoltifyAddress
-	acc, err := queryAccount(from.String(), jc.grpcClient)
-	if err != nil {
-		jc.logger.Error().Err(err).Msg("Fail to query the pool account")
-		return false, err
-	}
 	coins, err := queryBalance(from.String(), jc.grpcClient)
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("Fail to query the balance")
@@ -178,7 +224,8 @@ func (jc *JoltifyChainInstance) MoveFunds(fromPool *bcommon.PoolInfo, to types.A
 		Version:     tssclient.TssVersion,
 	}
 
-	ok, resp, err := jc.composeAndSend(msg, acc.GetSequence(), acc.GetAccountNumber(), &signMsg)
+	// the retired pool is moved by every member as before
+	ok, resp, err := jc.sendFromPool(from, []types.Msg{msg}, &signMsg, height, bcommon.Submitter{})
 	if err != nil || !ok {
 		jc.logger.Error().Err(err).Msgf("fail to broadcast the tx->%v", resp)
 		return false, errors.New("fail to process the inbound tx")
@@ -187,21 +234,17 @@ func (jc *JoltifyChainInstance) MoveFunds(fromPool *bcommon.PoolInfo, to types.A
 }
 
 // GetOutBoundInfo return the outbound tx info
-func (o *OutBoundReq) GetOutBoundInfo() (ethcommon.Address, ethcommon.Address, *big.Int, int64) {
-	return o.outReceiverAddress, o.fromPoolAddr, o.coin.Amount.BigInt(), o.blockHeight
+func (o *OutBoundReq) GetOutBoundInfo() (ethcommon.Address, ethcommon.Address, types.Coin, int64) {
+	return o.outReceiverAddress, o.fromPoolAddr, o.coin, o.blockHeight
 }
 
 // Verify checks whether the outbound tx has paid enough fee
-func (a *outboundTx) Verify() error {
-	if a.fee.Denom != config.OutBoundDenomFee {
+func (a *outboundTx) Verify(minFee types.Coin) error {
+	if a.fee.Denom != minFee.Denom {
 		return errors.New("invalid outbound fee denom")
 	}
-	amount, err := types.NewDecFromStr(config.OUTBoundFeeOut)
-	if err != nil {
-		return errors.New("invalid minimal inbound fee")
-	}
-	if a.fee.Amount.LT(types.NewIntFromBigInt(amount.BigInt())) {
-		return fmt.Errorf("the fee is not enough with %s<%s", a.fee.Amount, amount.BigInt().String())
+	if a.fee.Amount.LT(minFee.Amount) {
+		return fmt.Errorf("the fee is not enough with %s<%s", a.fee.Amount, minFee.Amount)
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package joltifybridge
 
 import (
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,11 +16,15 @@ import (
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
 	"gitlab.com/joltify/joltifychain-bridge/config"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
 	"gitlab.com/joltify/joltifychain/testutil/network"
 	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type OutBoundTestSuite struct {
@@ -133,7 +138,7 @@ func (o OutBoundTestSuite) TestUpdatePool() {
 		true,
 	}
 	//
-	jc, err := NewJoltifyBridge(o.network.Validators[0].APIAddress, o.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(o.network.Validators[0].APIAddress, o.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	o.Require().NoError(err)
 	defer func() {
 		err := jc.TerminateBridge()
@@ -231,14 +236,15 @@ func (o OutBoundTestSuite) TestOutTx() {
 		sdk.NewCoin("test", sdk.NewInt(1)),
 		sdk.NewCoin("fee", sdk.NewInt(10)),
 	}
-	err = tx.Verify()
+	minFee := sdk.NewCoin(testFeeDenom, sdk.NewInt(10))
+	err = tx.Verify(minFee)
 	o.Require().Errorf(err, "invalid outbound fee denom")
-	tx.fee = sdk.NewCoin(config.OutBoundDenomFee, sdk.NewInt(1))
-	err = tx.Verify()
+	tx.fee = sdk.NewCoin(testFeeDenom, sdk.NewInt(1))
+	err = tx.Verify(minFee)
 	o.Require().Error(err, "the fee is not enough with 1<10")
 
-	tx.fee = sdk.NewCoin(config.OutBoundDenomFee, sdk.NewInt(100))
-	err = tx.Verify()
+	tx.fee = sdk.NewCoin(testFeeDenom, sdk.NewInt(100))
+	err = tx.Verify(minFee)
 	o.Require().NoError(err)
 }
 
@@ -252,7 +258,7 @@ func (o OutBoundTestSuite) TestProcessMsg() {
 		true,
 	}
 
-	jc, err := NewJoltifyBridge(o.network.Validators[0].RPCAddress, o.network.Validators[0].RPCAddress, &tss)
+	jc, err := NewJoltifyBridge(o.network.Validators[0].RPCAddress, o.network.Validators[0].RPCAddress, &tss, nil, newTestTokens())
 	o.Require().NoError(err)
 	defer func() {
 		err2 := jc.TerminateBridge()
@@ -266,50 +272,112 @@ func (o OutBoundTestSuite) TestProcessMsg() {
 	baseBlockHeight := int64(100)
 	msg := banktypes.MsgSend{}
 
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "empty address string is not allowed")
 
 	msg.FromAddress = o.network.Validators[0].Address.String()
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "empty address string is not allowed")
 
 	ret := jc.CheckWhetherAlreadyExist("testindex")
 	o.Require().True(ret)
 
 	msg.ToAddress = accs[3].joltAddr.String()
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "not a top up message to the pool")
 
 	msg.ToAddress = accs[1].joltAddr.String()
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "we only allow fee and top up in one tx now")
 
 	coin1 := sdk.NewCoin(config.OutBoundDenom, sdk.NewInt(100))
-	coin2 := sdk.NewCoin(config.OutBoundDenomFee, sdk.NewInt(1))
+	coin2 := sdk.NewCoin(testFeeDenom, sdk.NewInt(1))
 	coin3 := sdk.NewCoin(config.InBoundDenomFee, sdk.NewInt(100))
-	coin4 := sdk.NewCoin(config.OutBoundDenomFee, sdk.NewInt(100))
+	coin4 := sdk.NewCoin(testFeeDenom, sdk.NewInt(100))
 
 	msg.Amount = sdk.NewCoins(coin1, coin3)
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "invalid fee pair")
 	msg.Amount = sdk.NewCoins(coin2, coin3)
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "invalid fee pair")
 
 	msg.Amount = sdk.NewCoins(coin1, coin2)
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "not enough fee")
 
 	msg.Amount = sdk.NewCoins(coin1, coin4)
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().NoError(err)
 
 	// we set the wrong account
 	msg.FromAddress = accs[1].commAddr.String()
-	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, []byte("msg1"))
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "", []byte("msg1"))
 	o.Require().EqualError(err, "rpc error: code = InvalidArgument desc = decoding bech32 failed: string not all lowercase or all uppercase: invalid request")
+
+	// the receiver in the memo is paid without querying the sender
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, accs[0].commAddr.Hex(), []byte("msg2"))
+	o.Require().NoError(err)
+	err = jc.processMsg(baseBlockHeight, []sdk.AccAddress{accs[1].joltAddr, accs[2].joltAddr}, accs[3].commAddr, &msg, "withdraw please", []byte("msg3"))
+	o.Require().Error(err)
+}
+
+func TestOutboundReceiver(t *testing.T) {
+	accs, err := generateRandomPrivKey(1)
+	require.NoError(t, err)
+	jc := JoltifyChainInstance{}
+
+	receiver, err := jc.outboundReceiver("", strings.ToLower(accs[0].commAddr.Hex()))
+	require.NoError(t, err)
+	require.Equal(t, accs[0].commAddr, receiver)
+	_, err = jc.outboundReceiver("", "0x1234")
+	require.Error(t, err)
+}
+
+func TestOutboundDust(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+	registry, err := bcommon.NewTokenRegistry(56, []config.TokenConfig{{Address: accs[2].commAddr.Hex(), Denom: "JUSDT", Decimals: 6}})
+	require.NoError(t, err)
+	require.NoError(t, registry.SetOutboundFee(testFeeDenom, "0.00000000000000001"))
+	tokens, err := bcommon.NewChainRegistry(registry)
+	require.NoError(t, err)
+	jc := newStateTestInstance(nil)
+	jc.tokens = tokens
+
+	fee := sdk.NewCoin(testFeeDenom, sdk.NewInt(100))
+	msg := banktypes.MsgSend{FromAddress: accs[0].joltAddr.String(), ToAddress: accs[1].joltAddr.String()}
+	pools := []sdk.AccAddress{accs[1].joltAddr, accs[1].joltAddr}
+	// the token has 6 decimals on the public chain, so the amount cannot be paid without dropping the dust
+	msg.Amount = sdk.NewCoins(sdk.NewCoin("JUSDT", sdk.NewInt(2999999999999)), fee)
+	err = jc.processMsg(10, pools, accs[1].commAddr, &msg, accs[0].commAddr.Hex(), []byte("dust"))
+	require.Error(t, err)
+	require.Equal(t, 0, jc.Size())
+
+	msg.Amount = sdk.NewCoins(sdk.NewCoin("JUSDT", sdk.NewInt(3000000000000)), fee)
+	err = jc.processMsg(10, pools, accs[1].commAddr, &msg, accs[0].commAddr.Hex(), []byte("exact"))
+	require.NoError(t, err)
+	require.Equal(t, 1, jc.Size())
+
+	// the fee is charged in the denom set for the chain of the token
+	require.NoError(t, registry.SetOutboundFee("ETHJOLT", "0.00000000000000001"))
+	err = jc.processMsg(10, pools, accs[1].commAddr, &msg, accs[0].commAddr.Hex(), []byte("other fee"))
+	require.EqualError(t, err, "invalid fee pair")
+	msg.Amount = sdk.NewCoins(sdk.NewCoin("JUSDT", sdk.NewInt(3000000000000)), sdk.NewCoin("ETHJOLT", sdk.NewInt(100)))
+	err = jc.processMsg(10, pools, accs[1].commAddr, &msg, accs[0].commAddr.Hex(), []byte("other fee"))
+	require.NoError(t, err)
+	require.Equal(t, 2, jc.Size())
 }
 
 func TestTxOutBound(t *testing.T) {
 	suite.Run(t, new(OutBoundTestSuite))
 }
+
+func TestTransientQueryError(t *testing.T) {
+	require.True(t, transientQueryError(status.Error(codes.Unavailable, "connection refused")))
+	require.True(t, transientQueryError(status.Error(codes.DeadlineExceeded, "timeout")))
+	// the invalid sender will never be found
+	require.False(t, transientQueryError(status.Error(codes.InvalidArgument, "decoding bech32 failed")))
+	require.False(t, transientQueryError(status.Error(codes.NotFound, "account not found")))
+}
@@ -1,7 +1,11 @@
 package joltifybridge
 
 import (
+	"context"
+	"encoding/json"
 	"math/big"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,6 +19,8 @@ import (
 	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	"github.com/ethereum/go-ethereum/common"
 	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 	"gitlab.com/joltify/joltifychain-bridge/validators"
 	"gitlab.com/joltify/joltifychain/x/vault/types"
@@ -40,6 +46,7 @@ type tssPoolMsg struct {
 
 func (pi *JoltifyChainInstance) AddMoveFundItem(pool *bcommon.PoolInfo, height int64) {
 	pi.moveFundReq.Store(height, pool)
+	pi.persist(storage.JoltMoveFundBucket, strconv.FormatInt(height, 10), pool)
 }
 
 // PopMoveFundItemAfterBlock pop a move fund item after give block duration
@@ -55,6 +62,7 @@ func (pi *JoltifyChainInstance) PopMoveFundItemAfterBlock(currentBlockHeight int
 
 	if min < math.MaxInt64 && (currentBlockHeight-min > config.MINCHECKBLOCKGAP) {
 		item, _ := pi.moveFundReq.LoadAndDelete(min)
+		pi.unpersist(storage.JoltMoveFundBucket, strconv.FormatInt(min, 10))
 		return item.(*bcommon.PoolInfo), min
 	}
 	return nil, 0
@@ -71,6 +79,7 @@ func (pi *JoltifyChainInstance) PopMoveFundItem() (*bcommon.PoolInfo, int64) {
 	})
 	if min < math.MaxInt64 {
 		item, _ := pi.moveFundReq.LoadAndDelete(min)
+		pi.unpersist(storage.JoltMoveFundBucket, strconv.FormatInt(min, 10))
 		return item.(*bcommon.PoolInfo), min
 	}
 	return nil, 0
@@ -78,6 +87,63 @@ func (pi *JoltifyChainInstance) PopMoveFundItem() (*bcommon.PoolInfo, int64) {
 
 func (pi *JoltifyChainInstance) AddItem(req *OutBoundReq) {
 	pi.RetryOutboundReq.Store(req.Hash().Big(), req)
+	pi.persist(storage.RetryOutboundBucket, req.Hash().Hex(), req)
+	// the retry queue keeps the request from now on
+	pi.FinishInflight(req)
+	pi.Transfers.Update(req.SourceTx(), monitor.TransferQueued)
+}
+
+// AddRefund queues the payout of the deposit that cannot be credited on joltify back to its depositor, it is paid like
+// an outbound from the pool that received the deposit. The height is the public chain block of the deposit, so all
+// the nodes build the same request.
+func (pi *JoltifyChainInstance) AddRefund(txID string, depositor, pool common.Address, coin sdk.Coin, height int64) {
+	req := newOutboundReq(txID, depositor, pool, coin, height)
+	pi.observeOutbound(&req)
+	pi.AddItem(&req)
+}
+
+// SentOutbound is an outbound handed to the processing, TxHash is the public chain tx paying it once it is signed
+type SentOutbound struct {
+	Req    *OutBoundReq `json:"req"`
+	TxHash string       `json:"tx_hash,omitempty"`
+}
+
+// markInflight keeps the outbound in the store until it is paid or back in the retry queue, so a crash in between
+// does not lose it
+func (pi *JoltifyChainInstance) markInflight(req *OutBoundReq) {
+	pi.persist(storage.InflightOutboundBucket, req.Hash().Hex(), &SentOutbound{Req: req})
+}
+
+// SendOutbound hands the outbound to the processing channel
+func (pi *JoltifyChainInstance) SendOutbound(req *OutBoundReq) {
+	pi.markInflight(req)
+	pi.OutboundReqChan <- req
+}
+
+// MarkOutboundSent records the public chain tx paying the outbound once it is signed and before it is broadcast, after
+// a restart we check this tx instead of paying the outbound again
+func (pi *JoltifyChainInstance) MarkOutboundSent(req *OutBoundReq, txHash string) {
+	pi.persist(storage.InflightOutboundBucket, req.Hash().Hex(), &SentOutbound{Req: req, TxHash: txHash})
+}
+
+// FinishInflight removes the outbound handed to the processing from the store
+func (pi *JoltifyChainInstance) FinishInflight(req *OutBoundReq) {
+	pi.unpersist(storage.InflightOutboundBucket, req.Hash().Hex())
+}
+
+// PopSentOutbounds returns the outbounds whose txs were signed when the bridge stopped, the caller checks the txs
+// and either confirms or requeues them
+func (pi *JoltifyChainInstance) PopSentOutbounds() []SentOutbound {
+	var items []SentOutbound
+	pi.sentOutbounds.Range(func(key, value interface{}) bool {
+		items = append(items, value.(SentOutbound))
+		pi.sentOutbounds.Delete(key)
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].TxHash < items[j].TxHash
+	})
+	return items
 }
 
 func (pi *JoltifyChainInstance) PopItem() *OutBoundReq {
@@ -91,11 +157,45 @@ func (pi *JoltifyChainInstance) PopItem() *OutBoundReq {
 	})
 	if max.Cmp(big.NewInt(0)) == 1 {
 		item, _ := pi.RetryOutboundReq.LoadAndDelete(max)
-		return item.(*OutBoundReq)
+		req := item.(*OutBoundReq)
+		pi.markInflight(req)
+		pi.unpersist(storage.RetryOutboundBucket, req.Hash().Hex())
+		return req
 	}
 	return nil
 }
 
+// PopItemsMatching pops at most n items accepted by match from the retry queue, the items are popped in the same order
+// as PopItem
+func (pi *JoltifyChainInstance) PopItemsMatching(n int, match func(req *OutBoundReq) bool) []*OutBoundReq {
+	// the keys are the pointers stored by AddItem, so we keep them to delete the items
+	var keys []*big.Int
+	pi.RetryOutboundReq.Range(func(key, value interface{}) bool {
+		if match(value.(*OutBoundReq)) {
+			keys = append(keys, key.(*big.Int))
+		}
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Cmp(keys[j]) == 1
+	})
+	var items []*OutBoundReq
+	for _, key := range keys {
+		if len(items) == n {
+			break
+		}
+		item, ok := pi.RetryOutboundReq.LoadAndDelete(key)
+		if !ok {
+			continue
+		}
+		req := item.(*OutBoundReq)
+		pi.markInflight(req)
+		pi.unpersist(storage.RetryOutboundBucket, req.Hash().Hex())
+		items = append(items, req)
+	}
+	return items
+}
+
 func (jc *JoltifyChainInstance) Size() int {
 	i := 0
 	jc.RetryOutboundReq.Range(func(key, value interface{}) bool {
@@ -117,6 +217,8 @@ func (pi *JoltifyChainInstance) ShowItems() {
 type JoltifyChainInstance struct {
 	grpcClient       grpc1.ClientConn
 	wsClient         *tmclienthttp.HTTP
+	wsLocker         sync.RWMutex
+	wsAddress        string
 	encoding         *params.EncodingConfig
 	Keyring          keyring.Keyring
 	logger           zerolog.Logger
@@ -126,10 +228,28 @@ type JoltifyChainInstance struct {
 	poolUpdateLocker *sync.RWMutex
 	msgSendCache     []tssPoolMsg
 	lastTwoPools     []*bcommon.PoolInfo
+	tokens           *bcommon.ChainRegistry
 	OutboundReqChan  chan *OutBoundReq
 	RetryOutboundReq *sync.Map // if a tx fail to process, we need to put in this channel and wait for retry
 	moveFundReq      *sync.Map
-	CurrentHeight    int64
+	droppedOutbounds *sync.Map // the retry outbounds dropped by the operator
+	sentOutbounds    *sync.Map // the restored outbounds whose txs were signed before the bridge stopped
+	outboundTxSeen   *sync.Map // the outbound txs we have processed, to avoid handling a tx twice in catch up
+	outboundTxRetry  *sync.Map // the outbound txs that failed for a reason that may go away, retried on each block
+	stateStore       *storage.StateStore
+	sequences        *SequenceManager
+	lastBlockSeen    int64 // the last joltify block delivered by the subscription
+	txReconnects     int64 // the reconnects of the subscriptions seen by the last catch up
+	rescanTo         int64 // the blocks up to this height are scanned as the subscription has been reconnected
+	// ctx is cancelled once the bridge is terminated, it stops the goroutines waiting to take over the txs
+	ctx           context.Context
+	cancel        context.CancelFunc
+	CurrentHeight int64
+	// SignPolicy holds the requests we observed, we only sign the txs rebuilt from them
+	SignPolicy *tssclient.Policy
+	Metric     *monitor.Metric
+	// Transfers records the lifecycle of the inbounds and outbounds
+	Transfers *monitor.TransferTracker
 }
 
 // info the import structure of the cosmos validator info
@@ -153,6 +273,11 @@ type outboundTx struct {
 	fee                sdk.Coin
 }
 
+// SourceTx returns the hash of the joltify tx that requests the outbound
+func (i *OutBoundReq) SourceTx() string {
+	return i.txID
+}
+
 func (i *OutBoundReq) Hash() common.Hash {
 	blockHeight := new(big.Int).SetInt64(i.blockHeight)
 	hash := crypto.Keccak256Hash(i.outReceiverAddress.Bytes(), i.fromPoolAddr.Bytes(), []byte(i.txID), blockHeight.Bytes())
@@ -168,6 +293,36 @@ type OutBoundReq struct {
 	blockHeight        int64
 }
 
+// outBoundReqJSON is the persisted form of the OutBoundReq
+type outBoundReqJSON struct {
+	TxID               string         `json:"tx_id"`
+	OutReceiverAddress common.Address `json:"out_receiver_address"`
+	FromPoolAddr       common.Address `json:"from_pool_addr"`
+	Coin               sdk.Coin       `json:"coin"`
+	BlockHeight        int64          `json:"block_height"`
+}
+
+// MarshalJSON encodes the outbound request for the state store
+func (i *OutBoundReq) MarshalJSON() ([]byte, error) {
+	return json.Marshal(outBoundReqJSON{
+		TxID:               i.txID,
+		OutReceiverAddress: i.outReceiverAddress,
+		FromPoolAddr:       i.fromPoolAddr,
+		Coin:               i.coin,
+		BlockHeight:        i.blockHeight,
+	})
+}
+
+// UnmarshalJSON decodes the outbound request loaded from the state store
+func (i *OutBoundReq) UnmarshalJSON(data []byte) error {
+	var v outBoundReqJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*i = newOutboundReq(v.TxID, v.OutReceiverAddress, v.FromPoolAddr, v.Coin, v.BlockHeight)
+	return nil
+}
+
 func newOutboundReq(txID string, address, fromPoolAddr common.Address, coin sdk.Coin, blockHeight int64) OutBoundReq {
 	return OutBoundReq{
 		txID,
@@ -14,8 +14,29 @@ import (
 	"github.com/joltify-finance/tss/common"
 	"github.com/joltify-finance/tss/keygen"
 	"github.com/joltify-finance/tss/keysign"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
 )
 
+// newTestTokens creates the registry that bridges the outbound denom
+// testFeeDenom is the denom of the outbound fee of the test chains
+const testFeeDenom = "JOLT"
+
+func newTestTokens() *bcommon.ChainRegistry {
+	tokens, err := bcommon.NewTokenRegistry(97, []config.TokenConfig{{Address: "0x33875278f7757f6b43abC223EeC4a9D6204186a0", Denom: config.OutBoundDenom, Decimals: 18}})
+	if err != nil {
+		panic(err)
+	}
+	if err := tokens.SetOutboundFee(testFeeDenom, "0.00000000000000001"); err != nil {
+		panic(err)
+	}
+	chains, err := bcommon.NewChainRegistry(tokens)
+	if err != nil {
+		panic(err)
+	}
+	return chains
+}
+
 type TssMock struct {
 	sk             *secp256k1.PrivKey
 	keys           keyring.Keyring
@@ -8,14 +8,19 @@ import (
 	"github.com/cenkalti/backoff"
 	"time"
 
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
 	"gitlab.com/joltify/joltifychain-bridge/validators"
 	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
 
 	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
 	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
 	"github.com/cosmos/cosmos-sdk/types/rest"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
 
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
 	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32/legacybech32" // nolint
 	zlog "github.com/rs/zerolog/log"
 
 	tmtypes "github.com/tendermint/tendermint/types"
@@ -23,22 +28,22 @@ import (
 
 // InitValidators initialize the validators
 func (jc *JoltifyChainInstance) InitValidators(addr string) error {
-	ts := tmservice.NewServiceClient(jc.grpcClient)
-	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
-	defer cancel()
-
 	for {
-		result, err := ts.GetSyncing(ctx, &tmservice.GetSyncingRequest{})
+		synced, err := jc.IsSynced()
 		if err != nil {
 			return err
 		}
-		if !result.GetSyncing() {
+		if synced {
 			break
 		}
 		zlog.Logger.Info().Msg("the blockchain is not fully synced, please wait....")
 		time.Sleep(time.Second * 5)
 	}
 
+	ts := tmservice.NewServiceClient(jc.grpcClient)
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+
 	nodeInfo, err := ts.GetNodeInfo(ctx, &tmservice.GetNodeInfoRequest{})
 	if err != nil {
 		return err
@@ -84,10 +89,41 @@ func (jc *JoltifyChainInstance) QueryLastPoolAddress() ([]*vaulttypes.PoolInfo,
 	return poolInfo, nil
 }
 
+// IsSynced returns true if the joltify node has caught up with the chain
+func (jc *JoltifyChainInstance) IsSynced() (bool, error) {
+	ts := tmservice.NewServiceClient(jc.grpcClient)
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	result, err := ts.GetSyncing(ctx, &tmservice.GetSyncingRequest{})
+	if err != nil {
+		return false, err
+	}
+	return !result.GetSyncing(), nil
+}
+
+// tssPubKey returns the tss pubkey of the validator, the tss peer of the validator is derived from it
+func tssPubKey(validatorPubKey []byte) (string, error) {
+	return legacybech32.MarshalPubKey(legacybech32.AccPK, &ed25519.PubKey{Key: validatorPubKey}) // nolint
+}
+
+// PoolMemberPubKeys returns the tss pubkeys of the last validator set, which generates the current pool
+func (jc *JoltifyChainInstance) PoolMemberPubKeys() ([]string, error) {
+	lastValidators, _ := jc.GetLastValidator()
+	pubKeys := make([]string, 0, len(lastValidators))
+	for _, el := range lastValidators {
+		pk, err := tssPubKey(el.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pk)
+	}
+	return pubKeys, nil
+}
+
 // CheckWhetherSigner check whether the current signer is the
 func (jc *JoltifyChainInstance) CheckWhetherSigner(lastPoolInfo *vaulttypes.PoolInfo) (bool, error) {
 	found := false
-	creator, err := jc.Keyring.Key("operator")
+	creator, err := jc.Keyring.Key(OperatorKeyName)
 	if err != nil {
 		jc.logger.Error().Err(err).Msg("fail to get the validator outReceiverAddress")
 		return found, err
@@ -102,6 +138,17 @@ func (jc *JoltifyChainInstance) CheckWhetherSigner(lastPoolInfo *vaulttypes.Pool
 	return found, nil
 }
 
+// SubmitterRank returns our rank among the pool members in broadcasting the tx of the request, -1 means we are not
+// a member of the pool
+func (jc *JoltifyChainInstance) SubmitterRank(lastPoolInfo *vaulttypes.PoolInfo, requestID []byte) (int, error) {
+	creator, err := jc.Keyring.Key(OperatorKeyName)
+	if err != nil {
+		jc.logger.Error().Err(err).Msg("fail to get the validator outReceiverAddress")
+		return -1, err
+	}
+	return bcommon.SubmitterRank(requestID, lastPoolInfo.CreatePool.Nodes, creator.GetAddress()), nil
+}
+
 // CheckWhetherAlreadyExist check whether it is already existed
 func (jc *JoltifyChainInstance) CheckWhetherAlreadyExist(index string) bool {
 	ret, err := queryGivenToeknIssueTx(jc.grpcClient, index)
@@ -133,6 +180,71 @@ func (jc *JoltifyChainInstance) CheckTxStatus(index string) error {
 
 }
 
+// mintTxPageSize is how many txs we query in one page when we look for the mint tx
+const mintTxPageSize = 100
+
+// MintTxHash returns the hash of the tx that minted the issue token of the index, so the mints submitted by the other
+// nodes have their tx hash too
+func (jc *JoltifyChainInstance) MintTxHash(index string) (string, error) {
+	issued, err := queryGivenToeknIssueTx(jc.grpcClient, index)
+	if err != nil {
+		return "", err
+	}
+	if issued == nil {
+		return "", fmt.Errorf("the issue token %v is not found", index)
+	}
+
+	ts := txtypes.NewServiceClient(jc.grpcClient)
+	// the mints of the receiver are searched from the latest one page by page, the mint we look for is a recent one
+	events := []string{
+		"message.action='" + types.MsgTypeURL(&vaulttypes.MsgCreateIssueToken{}) + "'",
+		"transfer.recipient='" + issued.Receiver.String() + "'",
+	}
+	for offset := uint64(0); ; offset += mintTxPageSize {
+		txHash, total, err := jc.findMintTx(ts, events, index, offset)
+		if err != nil {
+			return "", err
+		}
+		if txHash != "" {
+			return txHash, nil
+		}
+		if offset+mintTxPageSize >= total {
+			break
+		}
+	}
+	return "", fmt.Errorf("no tx mints the issue token %v", index)
+}
+
+// findMintTx looks for the tx minting the issue token of the index in the page of the txs with the events from the
+// offset, it returns the total number of the txs with the events as well
+func (jc *JoltifyChainInstance) findMintTx(ts txtypes.ServiceClient, events []string, index string, offset uint64) (string, uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	req := txtypes.GetTxsEventRequest{
+		Events:     events,
+		Pagination: &query.PageRequest{Offset: offset, Limit: mintTxPageSize},
+		OrderBy:    txtypes.OrderBy_ORDER_BY_DESC,
+	}
+	resp, err := ts.GetTxsEvent(ctx, &req)
+	if err != nil {
+		return "", 0, err
+	}
+	for i, el := range resp.GetTxs() {
+		if i >= len(resp.GetTxResponses()) {
+			break
+		}
+		if err := el.UnpackInterfaces(jc.encoding.InterfaceRegistry); err != nil {
+			continue
+		}
+		for _, msg := range el.GetMsgs() {
+			if issue, ok := msg.(*vaulttypes.MsgCreateIssueToken); ok && issue.Index == index {
+				return resp.GetTxResponses()[i].TxHash, 0, nil
+			}
+		}
+	}
+	return "", resp.GetPagination().GetTotal(), nil
+}
+
 func (jc *JoltifyChainInstance) doInitValidator(i info, blockHeight int64, values []*tmservice.Validator) error {
 	jc.myValidatorInfo = i
 	jc.validatorSet = validators.NewValidator()
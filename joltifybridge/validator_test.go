@@ -187,3 +187,16 @@ func (v ValidatorTestSuite) TestJoltifyChainBridge_CheckWhetherAlreadyExist() {
 	ret = jc.CheckWhetherAlreadyExist("testindexnoexist")
 	v.Require().False(ret)
 }
+
+func (v ValidatorTestSuite) TestJoltifyChainBridge_MintTxHash() {
+	jc := new(JoltifyChainInstance)
+	jc.grpcClient = v.network.Validators[0].ClientCtx
+	encoding := MakeEncodingConfig()
+	jc.encoding = &encoding
+	// the issue token in the genesis is not minted by any tx
+	_, err := jc.MintTxHash("testindex")
+	v.Require().Error(err)
+
+	_, err = jc.MintTxHash("testindexnoexist")
+	v.Require().Error(err)
+}
@@ -2,7 +2,9 @@ package misc
 
 import (
 	"encoding/base64"
+	"errors"
 	"math/big"
+	"strings"
 
 	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
 	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
@@ -56,6 +58,23 @@ func AccountPubKeyToEthAddress(pk cryptotypes.PubKey) (common.Address, error) {
 	return addr, nil
 }
 
+// ParseEthAddress parses the ETH address, the address in mixed case must carry a valid EIP-55 checksum
+func ParseEthAddress(s string) (common.Address, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "0x") || !common.IsHexAddress(s) {
+		return common.Address{}, errors.New("not a hex address")
+	}
+	addr := common.HexToAddress(s)
+	hexPart := s[2:]
+	if hexPart != strings.ToLower(hexPart) && hexPart != strings.ToUpper(hexPart) && addr.Hex() != s {
+		return common.Address{}, errors.New("invalid address checksum")
+	}
+	if addr == (common.Address{}) {
+		return common.Address{}, errors.New("the zero address is not allowed")
+	}
+	return addr, nil
+}
+
 // SerializeSig for both joltify chain and public chain
 func SerializeSig(sig *keysign.Signature, needRecovery bool) ([]byte, error) {
 	rBytes, err := base64.StdEncoding.DecodeString(sig.R)
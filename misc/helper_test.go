@@ -2,6 +2,7 @@ package misc
 
 import (
 	"crypto/ecdsa"
+	"strings"
 	"testing"
 
 	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
@@ -87,3 +88,29 @@ func TestGetJoltAddressFromETHSignature(t *testing.T) {
 	require.Nil(t, err)
 	require.True(t, expectedJoltAddr.Equals(JoltAddr))
 }
+
+func TestParseEthAddress(t *testing.T) {
+	checksummed := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	addr, err := ParseEthAddress(checksummed)
+	require.NoError(t, err)
+	require.Equal(t, checksummed, addr.Hex())
+
+	// the addresses in one case carry no checksum
+	for _, el := range []string{strings.ToLower(checksummed), "0x" + strings.ToUpper(checksummed[2:]), " " + checksummed + "\n"} {
+		addr, err = ParseEthAddress(el)
+		require.NoError(t, err)
+		require.Equal(t, checksummed, addr.Hex())
+	}
+
+	for _, el := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD",
+		checksummed[2:],
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beae",
+		"jolt1hh8s3c9l3en5dqk4pgxg6kvr87xcyh5qn6z0gr",
+		"0x0000000000000000000000000000000000000000",
+		"withdraw please",
+	} {
+		_, err = ParseEthAddress(el)
+		require.Error(t, err, el)
+	}
+}
@@ -1,15 +1,48 @@
 package monitor
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// the status of the mints
+const (
+	MintSubmitted = "submitted"
+	MintConfirmed = "confirmed"
+	MintFailed    = "failed"
+)
+
+// the status of the outbounds
+const (
+	OutboundBroadcast = "broadcast"
+	OutboundConfirmed = "confirmed"
+	OutboundResent    = "resent"
+)
+
+// JoltifyChain is the chain label of the joltify chain
+const JoltifyChain = "joltify"
+
+// Metric holds the prometheus metrics of the bridge, the counters are safe to update on a nil metric, so the chain
+// instances created without the monitor record nothing
 type Metric struct {
-	inboundTxNum  prometheus.Gauge
-	outboundTxNum prometheus.Gauge
-	logger        zerolog.Logger
+	inboundTxNum    prometheus.Gauge
+	outboundTxNum   prometheus.Gauge
+	pubReconnect    prometheus.Counter
+	joltReconnect   prometheus.Counter
+	inboundCounter  *prometheus.CounterVec
+	mintCounter     *prometheus.CounterVec
+	outboundCounter *prometheus.CounterVec
+	fundMoveCounter *prometheus.CounterVec
+	keysignCounter  *prometheus.CounterVec
+	keygenCounter   *prometheus.CounterVec
+	keysignLatency  prometheus.Histogram
+	keygenLatency   prometheus.Histogram
+	rpcErrorCounter *prometheus.CounterVec
+	headLag         *prometheus.GaugeVec
+	logger          zerolog.Logger
 }
 
 func (m *Metric) UpdateInboundTxNum(num float64) {
@@ -20,8 +53,110 @@ func (m *Metric) UpdateOutboundTxNum(num float64) {
 	m.outboundTxNum.Set(num)
 }
 
+// AddPubChainReconnect counts the reconnection to a public chain
+func (m *Metric) AddPubChainReconnect() {
+	if m == nil {
+		return
+	}
+	m.pubReconnect.Inc()
+}
+
+// AddJoltifyReconnect counts the reconnection to the joltify chain
+func (m *Metric) AddJoltifyReconnect() {
+	if m == nil {
+		return
+	}
+	m.joltReconnect.Inc()
+}
+
+// AddInbound counts the inbound deposit seen on the given chain
+func (m *Metric) AddInbound(chain string) {
+	if m == nil {
+		return
+	}
+	m.inboundCounter.WithLabelValues(chain).Inc()
+}
+
+// AddMints counts the mints of the given status
+func (m *Metric) AddMints(status string, num int) {
+	if m == nil {
+		return
+	}
+	m.mintCounter.WithLabelValues(status).Add(float64(num))
+}
+
+// AddOutbounds counts the outbounds of the given status
+func (m *Metric) AddOutbounds(status string, num int) {
+	if m == nil {
+		return
+	}
+	m.outboundCounter.WithLabelValues(status).Add(float64(num))
+}
+
+// AddFundMove counts the fund move of a retired pool on the given chain
+func (m *Metric) AddFundMove(chain string) {
+	if m == nil {
+		return
+	}
+	m.fundMoveCounter.WithLabelValues(chain).Inc()
+}
+
+// UpdateKeySign records the time and the result of the keysign
+func (m *Metric) UpdateKeySign(spent time.Duration, success bool) {
+	if m == nil {
+		return
+	}
+	m.keysignCounter.WithLabelValues(result(success)).Inc()
+	m.keysignLatency.Observe(spent.Seconds())
+}
+
+// UpdateKeyGen records the time and the result of the keygen
+func (m *Metric) UpdateKeyGen(spent time.Duration, success bool) {
+	if m == nil {
+		return
+	}
+	m.keygenCounter.WithLabelValues(result(success)).Inc()
+	m.keygenLatency.Observe(spent.Seconds())
+}
+
+// AddRPCError counts the failed call to the given endpoint
+func (m *Metric) AddRPCError(endpoint string) {
+	if m == nil {
+		return
+	}
+	m.rpcErrorCounter.WithLabelValues(endpoint).Inc()
+}
+
+// UpdateHeadLag records how many blocks the processed height is behind the head of the chain
+func (m *Metric) UpdateHeadLag(chain string, lag int64) {
+	if m == nil {
+		return
+	}
+	m.headLag.WithLabelValues(chain).Set(float64(lag))
+}
+
+func result(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
 func (m *Metric) Enable() {
 	prometheus.MustRegister(m.inboundTxNum)
+	prometheus.MustRegister(m.outboundTxNum)
+	prometheus.MustRegister(m.pubReconnect)
+	prometheus.MustRegister(m.joltReconnect)
+	prometheus.MustRegister(m.inboundCounter)
+	prometheus.MustRegister(m.mintCounter)
+	prometheus.MustRegister(m.outboundCounter)
+	prometheus.MustRegister(m.fundMoveCounter)
+	prometheus.MustRegister(m.keysignCounter)
+	prometheus.MustRegister(m.keygenCounter)
+	prometheus.MustRegister(m.keysignLatency)
+	prometheus.MustRegister(m.keygenLatency)
+	prometheus.MustRegister(m.rpcErrorCounter)
+	prometheus.MustRegister(m.headLag)
 }
 
 func NewMetric() *Metric {
@@ -45,6 +180,124 @@ func NewMetric() *Metric {
 			},
 		),
 
+		pubReconnect: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "pubchain_reconnect_total",
+				Help:      "the number of reconnections to the public chain",
+			},
+		),
+
+		joltReconnect: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "joltify_reconnect_total",
+				Help:      "the number of reconnections to the joltify chain",
+			},
+		),
+
+		inboundCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "inbound_deposit_total",
+				Help:      "the number of inbound deposits seen on each public chain",
+			},
+			[]string{"chain"},
+		),
+
+		mintCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "mint_total",
+				Help:      "the number of mints submitted, confirmed and failed",
+			},
+			[]string{"status"},
+		),
+
+		outboundCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "outbound_total",
+				Help:      "the number of outbounds broadcast, confirmed and resent",
+			},
+			[]string{"status"},
+		),
+
+		fundMoveCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "fund_move_total",
+				Help:      "the number of fund moves from the retired pools on each chain",
+			},
+			[]string{"chain"},
+		),
+
+		keysignCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "tss",
+				Name:      "keysign_total",
+				Help:      "the number of successful and failed keysigns",
+			},
+			[]string{"result"},
+		),
+
+		keygenCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "tss",
+				Name:      "keygen_total",
+				Help:      "the number of successful and failed keygens",
+			},
+			[]string{"result"},
+		),
+
+		keysignLatency: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "Joltify",
+				Subsystem: "tss",
+				Name:      "keysign_seconds",
+				Help:      "the latency of the keysigns",
+				Buckets:   prometheus.ExponentialBuckets(0.5, 2, 8),
+			},
+		),
+
+		keygenLatency: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "Joltify",
+				Subsystem: "tss",
+				Name:      "keygen_seconds",
+				Help:      "the latency of the keygens",
+				Buckets:   prometheus.ExponentialBuckets(5, 2, 8),
+			},
+		),
+
+		rpcErrorCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "rpc_error_total",
+				Help:      "the number of failed calls to each rpc endpoint",
+			},
+			[]string{"endpoint"},
+		),
+
+		headLag: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "Joltify",
+				Subsystem: "bridge",
+				Name:      "head_lag",
+				Help:      "the number of blocks the processed height is behind the chain head",
+			},
+			[]string{"chain"},
+		),
+
 		logger: log.With().Str("module", "joltifyMonitor").Logger(),
 	}
 	return &metrics
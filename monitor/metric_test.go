@@ -38,10 +38,10 @@ func TestMetric_UpdateKeySign(t *testing.T) {
 	assert.Equal(t, float64(5), val)
 
 	m := &dto.Metric{}
-	err = metrics.keySignTime.Write(m)
+	err = metrics.keysignLatency.Write(m)
 	assert.Nil(t, err)
-	val = m.Gauge.GetValue()
-	assert.Equal(t, float64(time.Second), val)
+	assert.Equal(t, uint64(8), m.Histogram.GetSampleCount())
+	assert.Equal(t, float64(8), m.Histogram.GetSampleSum())
 }
 
 func TestMetric_UpdateKeyGen(t *testing.T) {
@@ -64,3 +64,58 @@ func TestMetric_UpdateKeyGen(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, float64(5), val)
 }
+
+func TestMetric_Counters(t *testing.T) {
+	metrics := NewMetric()
+	metrics.AddMints(MintSubmitted, 3)
+	metrics.AddMints(MintConfirmed, 2)
+	metrics.AddOutbounds(OutboundResent, 1)
+	metrics.AddInbound("56")
+	metrics.AddRPCError("56/eth_getLogs")
+
+	val, err := getCounterValue(metrics.mintCounter, MintSubmitted)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), val)
+	val, err = getCounterValue(metrics.mintCounter, MintConfirmed)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(2), val)
+	val, err = getCounterValue(metrics.outboundCounter, OutboundResent)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+	val, err = getCounterValue(metrics.inboundCounter, "56")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+	val, err = getCounterValue(metrics.rpcErrorCounter, "56/eth_getLogs")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), val)
+
+	metrics.UpdateHeadLag("56", 12)
+	m := &dto.Metric{}
+	err = metrics.headLag.WithLabelValues("56").Write(m)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(12), m.Gauge.GetValue())
+
+	// the chain instances without the monitor record nothing
+	var empty *Metric
+	empty.AddMints(MintFailed, 1)
+	empty.UpdateKeySign(time.Second, false)
+}
+
+func TestMetric_Reconnect(t *testing.T) {
+	metrics := NewMetric()
+	metrics.AddPubChainReconnect()
+	metrics.AddPubChainReconnect()
+	metrics.AddJoltifyReconnect()
+
+	m := &dto.Metric{}
+	assert.Nil(t, metrics.pubReconnect.Write(m))
+	assert.Equal(t, float64(2), m.Counter.GetValue())
+	m = &dto.Metric{}
+	assert.Nil(t, metrics.joltReconnect.Write(m))
+	assert.Equal(t, float64(1), m.Counter.GetValue())
+
+	// the chain instances created without the monitor record nothing
+	var empty *Metric
+	empty.AddPubChainReconnect()
+	empty.AddJoltifyReconnect()
+}
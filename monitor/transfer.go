@@ -0,0 +1,312 @@
+package monitor
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// the directions of the transfers
+const (
+	Inbound  = "inbound"
+	Outbound = "outbound"
+)
+
+// the states a transfer goes through, a failed transfer is queued again when it is retried
+const (
+	TransferObserved   = "observed"
+	TransferFeeMatched = "fee_matched"
+	TransferQueued     = "queued"
+	TransferSigned     = "signed"
+	TransferBroadcast  = "broadcast"
+	TransferConfirmed  = "confirmed"
+	TransferFailed     = "failed"
+	// TransferRefunding is set on the deposit that cannot be credited, the token is paid back to the depositor
+	TransferRefunding = "refunding"
+)
+
+const (
+	// maxTransferSteps is how many steps we keep in the history of each transfer
+	maxTransferSteps = 50
+	// transferPruneInterval is how often we drop the records older than the retention
+	transferPruneInterval = time.Hour
+	// depositIndexLen is the length of the log index in hex that follows the tx hash in the key of each deposit of
+	// a tx carrying several deposits
+	depositIndexLen = 8
+)
+
+// TransferStep is one state change of the transfer
+type TransferStep struct {
+	State  string    `json:"state"`
+	Time   time.Time `json:"time"`
+	TxHash string    `json:"tx_hash,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// TransferRecord is the lifecycle of a transfer keyed by its source tx hash, each deposit of a tx carrying several
+// deposits is keyed by the tx hash followed by the log index of the deposit. The destination tx is the mint tx on
+// joltify for the inbounds and the payout tx on the public chain for the outbounds
+type TransferRecord struct {
+	Hash       string         `json:"hash"`
+	Direction  string         `json:"direction,omitempty"`
+	Chain      string         `json:"chain,omitempty"`
+	State      string         `json:"state"`
+	DestTxHash string         `json:"dest_tx_hash,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	History    []TransferStep `json:"history"`
+}
+
+// TransferTracker records the lifecycle of each transfer in the state store, it is safe to update a nil tracker, so
+// the chain instances created without the tracker record nothing
+type TransferTracker struct {
+	lock    sync.Mutex
+	records map[string]*TransferRecord
+	// retention is how long we keep the record after its last update
+	retention time.Duration
+	lastPrune time.Time
+	store     *storage.StateStore
+	logger    zerolog.Logger
+}
+
+// NewTransferTracker creates the transfer tracker that keeps the records for the retention after their last update, a
+// nil store keeps the records in memory only
+func NewTransferTracker(store *storage.StateStore, retention time.Duration) *TransferTracker {
+	return &TransferTracker{
+		records:   make(map[string]*TransferRecord),
+		retention: retention,
+		store:     store,
+		logger:    log.With().Str("module", "transfer").Logger(),
+	}
+}
+
+// TransferKey normalizes the tx hash, so the hex hashes of both chains are found with or without the 0x prefix
+func TransferKey(hash string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(hash, "0x"), "0X"))
+}
+
+// load returns the record of the transfer, the caller holds the lock
+func (t *TransferTracker) load(key string) (*TransferRecord, bool, error) {
+	if t.store == nil {
+		record, ok := t.records[key]
+		return record, ok, nil
+	}
+	var record TransferRecord
+	found, err := t.store.Get(storage.TransferBucket, key, &record)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return &record, true, nil
+}
+
+// save writes the record of the transfer, the caller holds the lock
+func (t *TransferTracker) save(record *TransferRecord) {
+	if t.store == nil {
+		t.records[record.Hash] = record
+		return
+	}
+	if err := t.store.Put(storage.TransferBucket, record.Hash, record); err != nil {
+		t.logger.Error().Err(err).Msgf("fail to persist the transfer %v", record.Hash)
+	}
+}
+
+// update applies the change to the record of the transfer, the record is created if we have not seen the transfer
+func (t *TransferTracker) update(hash string, fn func(record *TransferRecord) bool) {
+	if t == nil {
+		return
+	}
+	key := TransferKey(hash)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	record, ok, err := t.load(key)
+	if err != nil {
+		t.logger.Error().Err(err).Msgf("fail to load the transfer %v", key)
+		return
+	}
+	if !ok {
+		record = &TransferRecord{Hash: key}
+	}
+	if fn(record) {
+		t.save(record)
+	}
+}
+
+// step moves the transfer to the given state, nothing changes if the transfer is in the state already
+func step(record *TransferRecord, state, txHash, errMsg string) bool {
+	if record.State == state && (txHash == "" || record.DestTxHash == txHash) && errMsg == "" {
+		return false
+	}
+	now := time.Now().UTC()
+	record.State = state
+	record.Error = errMsg
+	record.UpdatedAt = now
+	if txHash != "" {
+		record.DestTxHash = txHash
+	}
+	record.History = append(record.History, TransferStep{State: state, Time: now, TxHash: txHash, Error: errMsg})
+	if len(record.History) > maxTransferSteps {
+		record.History = record.History[len(record.History)-maxTransferSteps:]
+	}
+	return true
+}
+
+// Observe records the transfer we have seen on the source chain, the transfer seen again in the catch up keeps its
+// state
+func (t *TransferTracker) Observe(hash, direction, chain string) {
+	t.update(hash, func(record *TransferRecord) bool {
+		if record.State != "" {
+			return false
+		}
+		record.Direction = direction
+		record.Chain = chain
+		return step(record, TransferObserved, "", "")
+	})
+}
+
+// Update moves the transfer to the given state
+func (t *TransferTracker) Update(hash, state string) {
+	t.UpdateTx(hash, state, "")
+}
+
+// UpdateTx moves the transfer to the given state with the destination tx
+func (t *TransferTracker) UpdateTx(hash, state, txHash string) {
+	t.update(hash, func(record *TransferRecord) bool {
+		return step(record, state, txHash, "")
+	})
+}
+
+// Fail records the failure of the transfer
+func (t *TransferTracker) Fail(hash string, err error) {
+	errMsg := "unknown error"
+	if err != nil {
+		errMsg = err.Error()
+	}
+	t.update(hash, func(record *TransferRecord) bool {
+		return step(record, TransferFailed, "", errMsg)
+	})
+}
+
+// Get returns the record of the transfer with the given source tx hash
+func (t *TransferTracker) Get(hash string) (TransferRecord, bool, error) {
+	if t == nil {
+		return TransferRecord{}, false, nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	record, ok, err := t.load(TransferKey(hash))
+	if err != nil || !ok {
+		return TransferRecord{}, false, err
+	}
+	ret := *record
+	ret.History = append([]TransferStep(nil), record.History...)
+	return ret, true, nil
+}
+
+// Find returns the records of the transfers with the given source tx hash, these are the record of the tx and the
+// records of each deposit of a tx carrying several deposits
+func (t *TransferTracker) Find(hash string) ([]TransferRecord, error) {
+	if t == nil {
+		return nil, nil
+	}
+	key := TransferKey(hash)
+	if key == "" {
+		return nil, nil
+	}
+	ofTx := func(k string) bool {
+		return k == key || (strings.HasPrefix(k, key) && len(k) == len(key)+depositIndexLen)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var ret []TransferRecord
+	if t.store == nil {
+		for k, el := range t.records {
+			if ofTx(k) {
+				record := *el
+				record.History = append([]TransferStep(nil), el.History...)
+				ret = append(ret, record)
+			}
+		}
+	} else {
+		err := t.store.IteratePrefix(storage.TransferBucket, key, func(k string, value []byte) error {
+			if !ofTx(k) {
+				return nil
+			}
+			var record TransferRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			ret = append(ret, record)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Hash < ret[j].Hash
+	})
+	return ret, nil
+}
+
+// Prune drops the records not updated within the retention before now, the records are checked once every prune
+// interval, it returns the number of the dropped records
+func (t *TransferTracker) Prune(now time.Time) int {
+	if t == nil || t.retention <= 0 {
+		return 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if now.Sub(t.lastPrune) < transferPruneInterval {
+		return 0
+	}
+	t.lastPrune = now
+	expired := func(record *TransferRecord) bool {
+		return now.Sub(record.UpdatedAt) >= t.retention
+	}
+
+	if t.store == nil {
+		pruned := 0
+		for k, el := range t.records {
+			if expired(el) {
+				delete(t.records, k)
+				pruned++
+			}
+		}
+		return pruned
+	}
+	var keys []string
+	err := t.store.Iterate(storage.TransferBucket, func(k string, value []byte) error {
+		var record TransferRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			t.logger.Warn().Err(err).Msgf("the transfer %v cannot be decoded, we drop it", k)
+			keys = append(keys, k)
+			return nil
+		}
+		if expired(&record) {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		t.logger.Error().Err(err).Msg("fail to walk through the transfers")
+		return 0
+	}
+	pruned := 0
+	for _, k := range keys {
+		if err := t.store.Delete(storage.TransferBucket, k); err != nil {
+			t.logger.Error().Err(err).Msgf("fail to drop the transfer %v", k)
+			continue
+		}
+		pruned++
+	}
+	if pruned != 0 {
+		t.logger.Info().Msgf("we have dropped %v transfers older than %v", pruned, t.retention)
+	}
+	return pruned
+}
@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+func TestTransferTracker(t *testing.T) {
+	var nilTracker *TransferTracker
+	nilTracker.Observe("0xabc", Inbound, "56")
+	_, found, err := nilTracker.Get("0xabc")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	assert.NoError(t, err)
+	tracker := NewTransferTracker(store, time.Hour)
+
+	tracker.Observe("0xABC", Inbound, "56")
+	tracker.Update("abc", TransferFeeMatched)
+	tracker.Update("abc", TransferQueued)
+	// the transfer is queued again as the event loop falls behind, it stays in the same state
+	tracker.Update("abc", TransferQueued)
+	tracker.Fail("abc", errors.New("fail to sign"))
+	tracker.Update("abc", TransferQueued)
+	tracker.UpdateTx("abc", TransferBroadcast, "MINT")
+	// the transfer seen again in the catch up keeps its state
+	tracker.Observe("abc", Inbound, "56")
+
+	record, found, err := tracker.Get("0xabc")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "abc", record.Hash)
+	assert.Equal(t, Inbound, record.Direction)
+	assert.Equal(t, "56", record.Chain)
+	assert.Equal(t, TransferBroadcast, record.State)
+	assert.Equal(t, "MINT", record.DestTxHash)
+	assert.Empty(t, record.Error)
+	var states []string
+	for _, el := range record.History {
+		states = append(states, el.State)
+	}
+	assert.Equal(t, []string{TransferObserved, TransferFeeMatched, TransferQueued, TransferFailed, TransferQueued, TransferBroadcast}, states)
+	assert.Equal(t, "fail to sign", record.History[3].Error)
+
+	// the records survive the restart
+	assert.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+	tracker = NewTransferTracker(store, time.Hour)
+	tracker.UpdateTx("ABC", TransferConfirmed, "MINT")
+	record, found, err = tracker.Get("abc")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, TransferConfirmed, record.State)
+	assert.Len(t, record.History, 7)
+
+	_, found, err = tracker.Get("def")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	// the history of the transfer retried many times is capped
+	for i := 0; i < maxTransferSteps; i++ {
+		tracker.Fail("def", errors.New("fail"))
+	}
+	record, _, err = tracker.Get("def")
+	assert.NoError(t, err)
+	assert.Len(t, record.History, maxTransferSteps)
+}
+
+func TestTransferFindAndPrune(t *testing.T) {
+	txHash := strings.Repeat("ab", 32)
+	for _, store := range []bool{false, true} {
+		var ss *storage.StateStore
+		if store {
+			var err error
+			ss, err = storage.NewStateStore(t.TempDir())
+			assert.NoError(t, err)
+		}
+		tracker := NewTransferTracker(ss, time.Minute)
+
+		// the deposits of a tx carrying several deposits are found by the tx hash
+		tracker.Observe(txHash+"00000004", Inbound, "56")
+		tracker.Observe(txHash+"00000002", Inbound, "56")
+		tracker.Observe(strings.Repeat("cd", 32), Inbound, "56")
+		tracker.Observe(txHash+"0000000400", Inbound, "56")
+		records, err := tracker.Find("0x" + strings.ToUpper(txHash))
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, txHash+"00000002", records[0].Hash)
+		assert.Equal(t, txHash+"00000004", records[1].Hash)
+
+		tracker.Observe(txHash, Inbound, "56")
+		records, err = tracker.Find(txHash)
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		records, err = tracker.Find("")
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+
+		// the records older than the retention are dropped once every prune interval
+		now := time.Now()
+		assert.Equal(t, 0, tracker.Prune(now))
+		assert.Equal(t, 0, tracker.Prune(now.Add(time.Minute*30)))
+		records, err = tracker.Find(txHash)
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.Equal(t, 5, tracker.Prune(now.Add(time.Hour*2)))
+		records, err = tracker.Find(txHash)
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+
+		var nilTracker *TransferTracker
+		assert.Equal(t, 0, nilTracker.Prune(now))
+		if ss != nil {
+			assert.NoError(t, ss.Close())
+		}
+	}
+}
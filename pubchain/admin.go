@@ -0,0 +1,187 @@
+package pubchain
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// QueueItems lists the items waiting in the queues of the chain
+func (pi *PubChainInstance) QueueItems() []bcommon.QueueItem {
+	var items []bcommon.QueueItem
+	pi.pendingInbounds.Range(func(key, value interface{}) bool {
+		el := value.(*inboundTx)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.PendingInboundQueue, ID: key.(string), Height: int64(el.pubBlockHeight), Item: el})
+		return true
+	})
+	pi.pendingInboundsBnB.Range(func(key, value interface{}) bool {
+		el := value.(*inboundTxBnb)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.PendingInboundBnBQueue, ID: key.(string), Height: int64(el.blockHeight), Item: el})
+		return true
+	})
+	pi.RetryInboundReq.Range(func(_, value interface{}) bool {
+		el := value.(*InboundReq)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.RetryInboundQueue, ID: el.Hash().Hex(), Height: el.blockHeight, Item: el})
+		return true
+	})
+	pi.droppedInbounds.Range(func(_, value interface{}) bool {
+		el := value.(*InboundReq)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.DroppedQueue, ID: el.Hash().Hex(), Height: el.blockHeight, Item: el})
+		return true
+	})
+	pi.droppedPending.Range(func(key, value interface{}) bool {
+		el := value.(*inboundTx)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.DroppedQueue, ID: key.(string), Height: int64(el.pubBlockHeight), Item: el})
+		return true
+	})
+	pi.droppedPendingBnB.Range(func(key, value interface{}) bool {
+		el := value.(*inboundTxBnb)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.DroppedQueue, ID: key.(string), Height: int64(el.blockHeight), Item: el})
+		return true
+	})
+	pi.moveFundReq.Range(func(key, value interface{}) bool {
+		el := value.(*bcommon.PoolInfo)
+		items = append(items, bcommon.QueueItem{Queue: bcommon.MoveFundQueue, ID: el.EthAddress.Hex(), Height: key.(int64), Item: el})
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Queue != items[j].Queue {
+			return items[i].Queue < items[j].Queue
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// findRetryItem returns the key of the retry inbound with the given hash
+func (pi *PubChainInstance) findRetryItem(id string) (*big.Int, *InboundReq) {
+	var foundKey *big.Int
+	var found *InboundReq
+	pi.RetryInboundReq.Range(func(key, value interface{}) bool {
+		el := value.(*InboundReq)
+		if strings.EqualFold(el.Hash().Hex(), id) {
+			foundKey, found = key.(*big.Int), el
+			return false
+		}
+		return true
+	})
+	return foundKey, found
+}
+
+// findMoveFundItem returns the height of the move fund item of the given pool
+func (pi *PubChainInstance) findMoveFundItem(id string) (int64, *bcommon.PoolInfo) {
+	var height int64
+	var found *bcommon.PoolInfo
+	pi.moveFundReq.Range(func(key, value interface{}) bool {
+		el := value.(*bcommon.PoolInfo)
+		if strings.EqualFold(el.EthAddress.Hex(), id) {
+			height, found = key.(int64), el
+			return false
+		}
+		return true
+	})
+	return height, found
+}
+
+// DropItem removes the item from its queue and returns the queue, the dropped inbounds are kept aside so they can be
+// requeued later
+func (pi *PubChainInstance) DropItem(id string) (string, error) {
+	if value, ok := pi.pendingInbounds.LoadAndDelete(id); ok {
+		pi.unpersist(pi.bucket(storage.PendingInboundBucket), id)
+		pi.droppedPending.Store(id, value)
+		pi.persist(pi.bucket(storage.DroppedPendingBucket), id, value)
+		return bcommon.PendingInboundQueue, nil
+	}
+	if value, ok := pi.pendingInboundsBnB.LoadAndDelete(id); ok {
+		pi.unpersist(pi.bucket(storage.PendingInboundBnBBucket), id)
+		pi.droppedPendingBnB.Store(id, value)
+		pi.persist(pi.bucket(storage.DroppedPendingBnBBucket), id, value)
+		return bcommon.PendingInboundBnBQueue, nil
+	}
+	if key, req := pi.findRetryItem(id); req != nil {
+		if _, ok := pi.RetryInboundReq.LoadAndDelete(key); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		pi.unpersist(pi.bucket(storage.RetryInboundBucket), req.Hash().Hex())
+		pi.ForgetInbound(req)
+		pi.droppedInbounds.Store(req.Hash().Hex(), req)
+		pi.persist(pi.bucket(storage.DroppedInboundBucket), req.Hash().Hex(), req)
+		return bcommon.RetryInboundQueue, nil
+	}
+	if height, pool := pi.findMoveFundItem(id); pool != nil {
+		if _, ok := pi.moveFundReq.LoadAndDelete(height); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		pi.unpersist(pi.bucket(storage.PubMoveFundBucket), strconv.FormatInt(height, 10))
+		pi.ForgetRetiredPool(pool)
+		return bcommon.MoveFundQueue, nil
+	}
+	return "", bcommon.ErrItemNotFound
+}
+
+// RequeueItem puts the dropped inbound back to the retry queue, the dropped pending inbound goes back to the pending
+// queue and still expires TxTimeout blocks after its own block
+func (pi *PubChainInstance) RequeueItem(id string) (string, error) {
+	if value, ok := pi.droppedPending.LoadAndDelete(id); ok {
+		pi.unpersist(pi.bucket(storage.DroppedPendingBucket), id)
+		pi.pendingInbounds.Store(id, value)
+		pi.persist(pi.bucket(storage.PendingInboundBucket), id, value)
+		return bcommon.DroppedQueue, nil
+	}
+	if value, ok := pi.droppedPendingBnB.LoadAndDelete(id); ok {
+		pi.unpersist(pi.bucket(storage.DroppedPendingBnBBucket), id)
+		pi.pendingInboundsBnB.Store(id, value)
+		pi.persist(pi.bucket(storage.PendingInboundBnBBucket), id, value)
+		return bcommon.DroppedQueue, nil
+	}
+	var found *InboundReq
+	pi.droppedInbounds.Range(func(_, value interface{}) bool {
+		el := value.(*InboundReq)
+		if strings.EqualFold(el.Hash().Hex(), id) {
+			found = el
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return "", bcommon.ErrItemNotFound
+	}
+	pi.droppedInbounds.Delete(found.Hash().Hex())
+	pi.unpersist(pi.bucket(storage.DroppedInboundBucket), found.Hash().Hex())
+	pi.observeInbound(found)
+	pi.AddItem(found)
+	return bcommon.DroppedQueue, nil
+}
+
+// RetryItem hands the retry inbound to the mint batcher at once, the move fund item is retried in the next block
+func (pi *PubChainInstance) RetryItem(id string) (string, error) {
+	if key, req := pi.findRetryItem(id); req != nil {
+		if _, ok := pi.RetryInboundReq.LoadAndDelete(key); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		pi.unpersist(pi.bucket(storage.RetryInboundBucket), req.Hash().Hex())
+		pi.persist(pi.bucket(storage.InflightInboundBucket), req.Hash().Hex(), req)
+		select {
+		case pi.InboundReqChan <- req:
+			return bcommon.RetryInboundQueue, nil
+		default:
+			pi.AddItem(req)
+			return "", errors.New("the inbound channel is full, try again later")
+		}
+	}
+	if height, pool := pi.findMoveFundItem(id); pool != nil {
+		if _, ok := pi.moveFundReq.LoadAndDelete(height); !ok {
+			return "", bcommon.ErrItemNotFound
+		}
+		pi.unpersist(pi.bucket(storage.PubMoveFundBucket), strconv.FormatInt(height, 10))
+		pi.AddMoveFundItem(pool, pi.CurrentHeight-config.MINCHECKBLOCKGAP-1)
+		return bcommon.MoveFundQueue, nil
+	}
+	return "", bcommon.ErrItemNotFound
+}
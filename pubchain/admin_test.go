@@ -0,0 +1,119 @@
+package pubchain
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	common2 "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
+)
+
+func TestAdminQueueItems(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	pi := newTestInstance(store)
+	pi.tokens = newTestTokens(accs[0].commAddr)
+
+	pendingID := hex.EncodeToString([]byte("test1"))
+	err = pi.processInboundTx(pendingID, 10, accs[1].joltAddr, accs[2].commAddr, big.NewInt(11), accs[0].commAddr)
+	require.NoError(t, err)
+	reqs, _, err := createNreq(2)
+	require.NoError(t, err)
+	for _, el := range reqs {
+		pi.AddItem(el)
+	}
+	poolInfo := vaulttypes.PoolInfo{
+		BlockHeight: "100",
+		CreatePool: &vaulttypes.PoolProposal{
+			PoolPubKey: accs[0].pk,
+			PoolAddr:   accs[0].joltAddr,
+		},
+	}
+	require.NoError(t, pi.UpdatePool(&poolInfo))
+	pool := pi.GetPool()[1]
+	pi.AddMoveFundItem(pool, 20)
+
+	items := pi.QueueItems()
+	require.Len(t, items, 4)
+	require.Equal(t, common2.MoveFundQueue, items[0].Queue)
+	require.Equal(t, pool.EthAddress.Hex(), items[0].ID)
+	require.Equal(t, common2.PendingInboundQueue, items[1].Queue)
+	require.Equal(t, pendingID, items[1].ID)
+	require.Equal(t, common2.RetryInboundQueue, items[2].Queue)
+
+	// the dropped retry item is kept aside and survives the restart
+	dropped := reqs[0].Hash().Hex()
+	queue, err := pi.DropItem(strings.ToLower(dropped))
+	require.NoError(t, err)
+	require.Equal(t, common2.RetryInboundQueue, queue)
+	require.Equal(t, 1, pi.Size())
+	_, err = pi.RetryItem(dropped)
+	require.ErrorIs(t, err, common2.ErrItemNotFound)
+
+	restarted := newTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+	require.Equal(t, 1, restarted.Size())
+	queue, err = restarted.RequeueItem(dropped)
+	require.NoError(t, err)
+	require.Equal(t, common2.DroppedQueue, queue)
+	require.Equal(t, 2, restarted.Size())
+	_, err = restarted.RequeueItem(dropped)
+	require.ErrorIs(t, err, common2.ErrItemNotFound)
+
+	// the retried item goes to the mint batcher, it goes back to the queue if the channel is full
+	queue, err = restarted.RetryItem(dropped)
+	require.NoError(t, err)
+	require.Equal(t, common2.RetryInboundQueue, queue)
+	require.Equal(t, dropped, (<-restarted.InboundReqChan).Hash().Hex())
+	restarted.InboundReqChan <- reqs[0]
+	_, err = restarted.RetryItem(reqs[1].Hash().Hex())
+	require.Error(t, err)
+	require.Equal(t, 1, restarted.Size())
+
+	// the move fund item is due in the next block
+	restarted.CurrentHeight = 1000
+	queue, err = restarted.RetryItem(pool.EthAddress.Hex())
+	require.NoError(t, err)
+	require.Equal(t, common2.MoveFundQueue, queue)
+	retried, height := restarted.PopMoveFundItemAfterBlock(restarted.CurrentHeight)
+	require.NotNil(t, retried)
+	require.Equal(t, 1000-config.MINCHECKBLOCKGAP-1, height)
+
+	// the dropped pending inbound is kept aside and survives the restart
+	queue, err = restarted.DropItem(pendingID)
+	require.NoError(t, err)
+	require.Equal(t, common2.PendingInboundQueue, queue)
+	_, err = restarted.DropItem(pendingID)
+	require.ErrorIs(t, err, common2.ErrItemNotFound)
+
+	restarted = newTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+	_, ok := restarted.pendingInbounds.Load(pendingID)
+	require.False(t, ok)
+	queue, err = restarted.RequeueItem(pendingID)
+	require.NoError(t, err)
+	require.Equal(t, common2.DroppedQueue, queue)
+	_, ok = restarted.pendingInbounds.Load(pendingID)
+	require.True(t, ok)
+
+	restarted = newTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+	_, ok = restarted.pendingInbounds.Load(pendingID)
+	require.True(t, ok)
+	_, err = restarted.RequeueItem(pendingID)
+	require.ErrorIs(t, err, common2.ErrItemNotFound)
+}
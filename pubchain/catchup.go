@@ -0,0 +1,117 @@
+package pubchain
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// headerRetryTimeout is how long we retry to get a missed block before we leave the gap to the next new head
+var headerRetryTimeout = time.Second * 30
+
+// SaveProcessedHeight records the last public chain block that has been fully processed
+func (pi *PubChainInstance) SaveProcessedHeight(height int64) {
+	pi.persist(storage.ChainHeightBucket, pi.bucket(storage.PubChainHeightKey), strconv.FormatInt(height, 10))
+}
+
+// GetProcessedHeight returns the last processed public chain block, 0 if we have no record
+func (pi *PubChainInstance) GetProcessedHeight() int64 {
+	if pi.stateStore == nil {
+		return 0
+	}
+	var heightStr string
+	found, err := pi.stateStore.Get(storage.ChainHeightBucket, pi.bucket(storage.PubChainHeightKey), &heightStr)
+	if err != nil || !found {
+		return 0
+	}
+	height, err := strconv.ParseInt(heightStr, 10, 64)
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("invalid processed height %v", heightStr)
+		return 0
+	}
+	return height
+}
+
+// catchUpRange returns the blocks we need to replay given the last processed height and the chain tip
+func (pi *PubChainInstance) catchUpRange(processed, tip int64) (int64, int64) {
+	start := processed + 1
+	if pi.maxLookBack > 0 && tip-start+1 > pi.maxLookBack {
+		start = tip - pi.maxLookBack + 1
+		pi.logger.Warn().Msgf("we have missed %v blocks, only the last %v blocks (from %v) are replayed", tip-processed, pi.maxLookBack, start)
+	}
+	return start, tip
+}
+
+// headerByNumber gets the header of the block, it retries with backoff as the block cannot be skipped
+func (pi *PubChainInstance) headerByNumber(ctx context.Context, height int64) (*types.Header, error) {
+	bf := backoff.NewExponentialBackOff()
+	bf.InitialInterval = time.Millisecond * 500
+	bf.MaxInterval = time.Second * 5
+	bf.MaxElapsedTime = headerRetryTimeout
+
+	var head *types.Header
+	op := func() error {
+		ctxHeader, cancel := context.WithTimeout(ctx, chainQueryTimeout)
+		defer cancel()
+		var err error
+		head, err = pi.getEthClient().HeaderByNumber(ctxHeader, big.NewInt(height))
+		pi.rpcError("eth_getBlockByNumber", err)
+		return err
+	}
+	err := backoff.Retry(op, backoff.WithContext(bf, ctx))
+	return head, err
+}
+
+// BlockTime returns the time of the block at the height
+func (pi *PubChainInstance) BlockTime(height int64) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(pi.ctx, chainQueryTimeout)
+	defer cancel()
+	head, err := pi.headerByNumber(ctx, height)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(head.Time), 0), nil
+}
+
+// replayMissedBlocks sends the headers of the blocks after the given height to the out channel and returns the
+// height of the last block sent without a gap, it returns the given height if nothing is replayed, so the caller
+// resumes the replay from there
+func (pi *PubChainInstance) replayMissedBlocks(ctx context.Context, out chan<- *types.Header, processed int64) int64 {
+	if processed == 0 {
+		return 0
+	}
+
+	ctxQuery, cancel := context.WithTimeout(ctx, chainQueryTimeout)
+	defer cancel()
+	tipHeader, err := pi.getEthClient().HeaderByNumber(ctxQuery, nil)
+	pi.rpcError("eth_getBlockByNumber", err)
+	if err != nil {
+		pi.logger.Error().Err(err).Msg("fail to get the latest block, we resume the catch up on the next block")
+		return processed
+	}
+	tip := tipHeader.Number.Int64()
+	if tip <= processed {
+		return processed
+	}
+
+	start, end := pi.catchUpRange(processed, tip)
+	pi.logger.Info().Msgf("we replay the public chain blocks from %v to %v", start, end)
+	for h := start; h <= end; h++ {
+		head, err := pi.headerByNumber(ctx, h)
+		if err != nil {
+			pi.logger.Error().Err(err).Msgf("fail to get the block %v, we resume the catch up on the next block", h)
+			return h - 1
+		}
+		select {
+		case out <- head:
+		case <-ctx.Done():
+			return h - 1
+		}
+	}
+	return end
+}
@@ -0,0 +1,354 @@
+package pubchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// fakeEthService is a minimal in-process eth rpc backend for the tests
+type fakeEthService struct {
+	lock        sync.Mutex
+	tip         int64
+	subscribers map[rpc.ID]chan *types.Header
+	headers     map[common.Hash]*types.Header
+	logs        []types.Log
+	baseFee     *big.Int
+	sent        []*types.Transaction
+	receipts    map[common.Hash]*types.Receipt
+	nonce       uint64
+	// missing are the blocks the node fails to return
+	missing map[int64]bool
+}
+
+// fakeFilter is the filter query sent by the eth client
+type fakeFilter struct {
+	Addresses []common.Address `json:"address"`
+	FromBlock string           `json:"fromBlock"`
+	ToBlock   string           `json:"toBlock"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+func (f *fakeEthService) GetLogs(filter fakeFilter) ([]types.Log, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	from, err := hexutil.DecodeUint64(filter.FromBlock)
+	if err != nil {
+		return nil, err
+	}
+	to, err := hexutil.DecodeUint64(filter.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+	logs := []types.Log{}
+	for _, el := range f.logs {
+		if el.BlockNumber < from || el.BlockNumber > to || !matchTopics(el.Topics, filter.Topics) || !matchAddress(el.Address, filter.Addresses) {
+			continue
+		}
+		logs = append(logs, el)
+	}
+	return logs, nil
+}
+
+func matchAddress(addr common.Address, filter []common.Address) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, el := range filter {
+		if el == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTopics(topics []common.Hash, filter [][]common.Hash) bool {
+	for i, wanted := range filter {
+		if len(wanted) == 0 {
+			continue
+		}
+		if i >= len(topics) {
+			return false
+		}
+		found := false
+		for _, el := range wanted {
+			if el == topics[i] {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func newFakeEthClient(t *testing.T, tip int64) (*fakeEthService, *ethclient.Client) {
+	fake, srv := newFakeEthServer(t, tip)
+	client := ethclient.NewClient(rpc.DialInProc(srv))
+	t.Cleanup(func() {
+		client.Close()
+		srv.Stop()
+	})
+	return fake, client
+}
+
+func newFakeEthServer(t *testing.T, tip int64) (*fakeEthService, *rpc.Server) {
+	fake := &fakeEthService{
+		tip:         tip,
+		subscribers: make(map[rpc.ID]chan *types.Header),
+		headers:     make(map[common.Hash]*types.Header),
+	}
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", fake))
+	return fake, srv
+}
+
+func (f *fakeEthService) setTip(tip int64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.tip = tip
+}
+
+// pushHead sends the new head to all the subscribers
+func (f *fakeEthService) pushHead(head *types.Header) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, el := range f.subscribers {
+		el <- head
+	}
+}
+
+func fakeHeader(height int64) *types.Header {
+	return &types.Header{
+		Number:     big.NewInt(height),
+		Difficulty: big.NewInt(1),
+		Time:       uint64(height),
+	}
+}
+
+func (f *fakeEthService) GetBlockByNumber(number rpc.BlockNumber, full bool) (*types.Header, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if number == rpc.LatestBlockNumber {
+		head := fakeHeader(f.tip)
+		head.BaseFee = f.baseFee
+		return head, nil
+	}
+	if number.Int64() > f.tip || number < 0 || f.missing[number.Int64()] {
+		return nil, errors.New("block not found")
+	}
+	head := fakeHeader(number.Int64())
+	head.BaseFee = f.baseFee
+	return head, nil
+}
+
+func (f *fakeEthService) GetBlockByHash(hash common.Hash, full bool) (*types.Header, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	head, ok := f.headers[hash]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return head, nil
+}
+
+func (f *fakeEthService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	newHead := make(chan *types.Header, 10)
+	f.lock.Lock()
+	f.subscribers[sub.ID] = newHead
+	f.lock.Unlock()
+	go func() {
+		defer func() {
+			f.lock.Lock()
+			delete(f.subscribers, sub.ID)
+			f.lock.Unlock()
+		}()
+		for {
+			select {
+			case head := <-newHead:
+				if err := notifier.Notify(sub.ID, head); err != nil {
+					return
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func collectHeights(t *testing.T, ch chan *types.Header, n int) []int64 {
+	t.Helper()
+	var heights []int64
+	for i := 0; i < n; i++ {
+		select {
+		case head := <-ch:
+			heights = append(heights, head.Number.Int64())
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timeout in waiting for the block, got %v", heights)
+		}
+	}
+	return heights
+}
+
+func TestProcessedHeight(t *testing.T) {
+	pi := newTestInstance(nil)
+	pi.SaveProcessedHeight(10)
+	require.Equal(t, int64(0), pi.GetProcessedHeight())
+
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	pi = newTestInstance(store)
+	require.Equal(t, int64(0), pi.GetProcessedHeight())
+	pi.SaveProcessedHeight(10)
+	require.NoError(t, store.Close())
+
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	pi = newTestInstance(store)
+	require.Equal(t, int64(10), pi.GetProcessedHeight())
+}
+
+func TestCatchUpRange(t *testing.T) {
+	pi := newTestInstance(nil)
+	start, end := pi.catchUpRange(10, 20)
+	require.Equal(t, int64(11), start)
+	require.Equal(t, int64(20), end)
+
+	pi.maxLookBack = 5
+	start, end = pi.catchUpRange(10, 20)
+	require.Equal(t, int64(16), start)
+	require.Equal(t, int64(20), end)
+
+	start, end = pi.catchUpRange(17, 20)
+	require.Equal(t, int64(18), start)
+	require.Equal(t, int64(20), end)
+}
+
+func TestSubscriptionCatchUp(t *testing.T) {
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	fake, client := newFakeEthClient(t, 20)
+	pi := newTestInstance(store)
+	pi.EthClient = client
+	pi.SaveProcessedHeight(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	blockChan, err := pi.StartSubscription(ctx, &wg)
+	require.NoError(t, err)
+
+	// the live block 20 arrives during the catch up and should not be delivered twice
+	fake.pushHead(fakeHeader(20))
+	fake.pushHead(fakeHeader(21))
+
+	heights := collectHeights(t, blockChan, 11)
+	require.Equal(t, []int64{11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21}, heights)
+	select {
+	case head := <-blockChan:
+		t.Fatalf("unexpected block %v", head.Number)
+	case <-time.After(time.Millisecond * 200):
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestSubscriptionMaxLookBack(t *testing.T) {
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	fake, client := newFakeEthClient(t, 100)
+	pi := newTestInstance(store)
+	pi.EthClient = client
+	pi.maxLookBack = 3
+	pi.SaveProcessedHeight(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	blockChan, err := pi.StartSubscription(ctx, &wg)
+	require.NoError(t, err)
+	fake.pushHead(fakeHeader(101))
+
+	heights := collectHeights(t, blockChan, 4)
+	require.Equal(t, []int64{98, 99, 100, 101}, heights)
+	cancel()
+	wg.Wait()
+}
+
+func TestSubscriptionCatchUpGap(t *testing.T) {
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	defer func(timeout time.Duration) {
+		headerRetryTimeout = timeout
+	}(headerRetryTimeout)
+	headerRetryTimeout = time.Millisecond * 100
+
+	fake, client := newFakeEthClient(t, 20)
+	fake.missing = map[int64]bool{15: true}
+	pi := newTestInstance(store)
+	pi.EthClient = client
+	pi.SaveProcessedHeight(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	blockChan, err := pi.StartSubscription(ctx, &wg)
+	require.NoError(t, err)
+
+	// the catch up stops at the missing block
+	heights := collectHeights(t, blockChan, 4)
+	require.Equal(t, []int64{11, 12, 13, 14}, heights)
+
+	// the new head is held while the gap is still there
+	fake.setTip(21)
+	fake.pushHead(fakeHeader(21))
+	select {
+	case head := <-blockChan:
+		t.Fatalf("unexpected block %v", head.Number)
+	case <-time.After(time.Millisecond * 500):
+	}
+
+	// the gap is replayed before the next new head once the node returns the block
+	fake.lock.Lock()
+	fake.missing = nil
+	fake.lock.Unlock()
+	fake.setTip(22)
+	fake.pushHead(fakeHeader(22))
+	heights = collectHeights(t, blockChan, 8)
+	require.Equal(t, []int64{15, 16, 17, 18, 19, 20, 21, 22}, heights)
+	cancel()
+	wg.Wait()
+}
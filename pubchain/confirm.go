@@ -0,0 +1,192 @@
+package pubchain
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// reorgKeepBlocks is the number of processed blocks we keep after confirmation to detect the deep reorg
+const reorgKeepBlocks = 64
+
+// bufferedBlock is the public chain block header we keep in the block buffer
+type bufferedBlock struct {
+	header    *ethTypes.Header
+	processed bool
+}
+
+// AddNewHeader puts the new header into the block buffer, it returns the blocks that have
+// enough confirmations and are ready to be processed in the order of the block height.
+// If the new header reorgs out the blocks we have processed, the inbound requests derived from
+// them are cancelled.
+func (pi *PubChainInstance) AddNewHeader(head *ethTypes.Header) []*ethTypes.Header {
+	atomic.StoreInt64(&pi.lastHeadTime, time.Now().UnixNano())
+	pi.blockLocker.Lock()
+	defer pi.blockLocker.Unlock()
+
+	if _, ok := pi.blockBuffer[head.Hash()]; ok {
+		return nil
+	}
+	pi.blockBuffer[head.Hash()] = &bufferedBlock{header: head}
+
+	if pi.chainTip != nil && head.ParentHash != pi.chainTip.Hash() {
+		reorged := pi.handleReorg(head)
+		if len(reorged) != 0 {
+			pi.cancelReorgedInbounds(reorged)
+		}
+	}
+	pi.chainTip = head
+
+	// now we walk through the canonical chain to find the confirmed blocks
+	confirmedHeight := head.Number.Int64() - pi.confirmDepth
+	var confirmed []*ethTypes.Header
+	for b, ok := pi.blockBuffer[head.Hash()]; ok; b, ok = pi.blockBuffer[b.header.ParentHash] {
+		if b.header.Number.Int64() <= confirmedHeight && !b.processed {
+			b.processed = true
+			confirmed = append(confirmed, b.header)
+		}
+	}
+	sort.Slice(confirmed, func(i, j int) bool {
+		return confirmed[i].Number.Cmp(confirmed[j].Number) == -1
+	})
+
+	pruneHeight := confirmedHeight - reorgKeepBlocks
+	for hash, b := range pi.blockBuffer {
+		if b.header.Number.Int64() < pruneHeight {
+			delete(pi.blockBuffer, hash)
+		}
+	}
+	return confirmed
+}
+
+// handleReorg links the new header to the blocks in the buffer, the blocks on the old chain that are
+// not the ancestors of the new header are removed, and the heights of the processed ones are returned
+func (pi *PubChainInstance) handleReorg(head *ethTypes.Header) map[int64]bool {
+	oldChain := make(map[common.Hash]bool)
+	lowest := pi.chainTip.Number.Int64()
+	for b, ok := pi.blockBuffer[pi.chainTip.Hash()]; ok; b, ok = pi.blockBuffer[b.header.ParentHash] {
+		oldChain[b.header.Hash()] = true
+		lowest = b.header.Number.Int64()
+	}
+
+	// we walk back the new chain until we reach the old chain, the missing headers are fetched from the chain
+	var ancestor *ethTypes.Header
+	cur := head
+	for cur.Number.Int64() > lowest {
+		if oldChain[cur.ParentHash] {
+			ancestor = pi.blockBuffer[cur.ParentHash].header
+			break
+		}
+		parent, ok := pi.blockBuffer[cur.ParentHash]
+		if !ok {
+			ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+			header, err := pi.getEthClient().HeaderByHash(ctx, cur.ParentHash)
+			cancel()
+			pi.rpcError("eth_getBlockByHash", err)
+			if err != nil {
+				pi.logger.Error().Err(err).Msgf("fail to get the parent block of %v", cur.Number)
+				break
+			}
+			parent = &bufferedBlock{header: header}
+			pi.blockBuffer[header.Hash()] = parent
+		}
+		cur = parent.header
+	}
+
+	if ancestor == nil {
+		// we cannot link the new header to the blocks we have, so we keep them as they are
+		pi.logger.Error().Msgf("fail to find the common ancestor of block %v", head.Number)
+		return nil
+	}
+	if ancestor.Hash() == pi.chainTip.Hash() {
+		// this is not a reorg, we just missed some blocks
+		return nil
+	}
+
+	reorged := make(map[int64]bool)
+	for hash := range oldChain {
+		b := pi.blockBuffer[hash]
+		if b.header.Number.Cmp(ancestor.Number) != 1 {
+			continue
+		}
+		delete(pi.blockBuffer, hash)
+		if b.processed {
+			reorged[b.header.Number.Int64()] = true
+		}
+	}
+	pi.logger.Warn().Msgf("chain reorg detected at block %v, the common ancestor is %v", head.Number, ancestor.Number)
+	return reorged
+}
+
+// cancelReorgedInbounds removes the pending inbounds, the refunds and the inbound requests that come from the reorged
+// blocks
+func (pi *PubChainInstance) cancelReorgedInbounds(heights map[int64]bool) {
+	pi.logger.Error().Msgf("the processed blocks %v have been reorged out, we cancel the inbound requests from them", heights)
+	pi.pendingInbounds.Range(func(key, value interface{}) bool {
+		if heights[int64(value.(*inboundTx).pubBlockHeight)] {
+			pi.pendingInbounds.Delete(key)
+			pi.unpersist(pi.bucket(storage.PendingInboundBucket), key.(string))
+		}
+		return true
+	})
+	pi.pendingInboundsBnB.Range(func(key, value interface{}) bool {
+		if heights[int64(value.(*inboundTxBnb).blockHeight)] {
+			pi.pendingInboundsBnB.Delete(key)
+			pi.unpersist(pi.bucket(storage.PendingInboundBnBBucket), key.(string))
+		}
+		return true
+	})
+	pi.refunds.Range(func(key, value interface{}) bool {
+		if heights[value.(*Refund).Height] {
+			pi.refunds.Delete(key)
+			pi.unpersist(pi.bucket(storage.RefundBucket), key.(string))
+		}
+		return true
+	})
+	pi.RetryInboundReq.Range(func(key, value interface{}) bool {
+		req := value.(*InboundReq)
+		if req.FromHeights(heights) {
+			pi.RetryInboundReq.Delete(key)
+			pi.unpersist(pi.bucket(storage.RetryInboundBucket), req.Hash().Hex())
+			pi.ForgetInbound(req)
+		}
+		return true
+	})
+
+	// the requests in the channel have not been processed yet, we put back the valid ones
+	var remains []*InboundReq
+drain:
+	for {
+		select {
+		case req := <-pi.InboundReqChan:
+			if !req.FromHeights(heights) {
+				remains = append(remains, req)
+				continue
+			}
+			pi.ForgetInbound(req)
+		default:
+			break drain
+		}
+	}
+	for _, el := range remains {
+		pi.InboundReqChan <- el
+	}
+	if pi.OnReorg != nil {
+		pi.OnReorg(heights)
+	}
+}
+
+// FromHeights returns true if the request is derived from any of the given public chain blocks
+func (acq *InboundReq) FromHeights(heights map[int64]bool) bool {
+	for _, el := range acq.sourceHeights {
+		if heights[el] {
+			return true
+		}
+	}
+	return false
+}
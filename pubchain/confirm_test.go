@@ -0,0 +1,149 @@
+package pubchain
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+)
+
+// makeChain creates n headers on top of the parent, the fork tag makes the headers differ from other forks
+func makeChain(parent *types.Header, n int, fork byte) []*types.Header {
+	var chain []*types.Header
+	for i := 0; i < n; i++ {
+		head := fakeHeader(parent.Number.Int64() + 1)
+		head.ParentHash = parent.Hash()
+		head.Extra = []byte{fork}
+		chain = append(chain, head)
+		parent = head
+	}
+	return chain
+}
+
+func heightsOf(headers []*types.Header) []int64 {
+	var heights []int64
+	for _, el := range headers {
+		heights = append(heights, el.Number.Int64())
+	}
+	return heights
+}
+
+func TestConfirmationDepth(t *testing.T) {
+	pi := newTestInstance(nil)
+	pi.confirmDepth = 2
+	genesis := fakeHeader(10)
+	chain := makeChain(genesis, 5, 0)
+
+	require.True(t, pi.LastHeadTime().IsZero())
+	require.Empty(t, pi.AddNewHeader(genesis))
+	require.WithinDuration(t, time.Now(), pi.LastHeadTime(), time.Second)
+	require.Empty(t, pi.AddNewHeader(chain[0]))
+	require.Equal(t, []int64{10}, heightsOf(pi.AddNewHeader(chain[1])))
+	// the same header delivered twice is ignored
+	require.Empty(t, pi.AddNewHeader(chain[1]))
+	require.Equal(t, []int64{11}, heightsOf(pi.AddNewHeader(chain[2])))
+	require.Equal(t, []int64{12}, heightsOf(pi.AddNewHeader(chain[3])))
+}
+
+func TestReorgBeforeConfirmation(t *testing.T) {
+	pi := newTestInstance(nil)
+	pi.confirmDepth = 2
+	genesis := fakeHeader(10)
+	chainA := makeChain(genesis, 3, 'a')
+	chainB := makeChain(chainA[0], 3, 'b')
+
+	pi.AddNewHeader(genesis)
+	for _, el := range chainA {
+		pi.AddNewHeader(el)
+	}
+	// 11a is confirmed, 12a and 13a are reorged out before they are confirmed
+	confirmed := pi.AddNewHeader(chainB[0])
+	require.Empty(t, confirmed)
+	confirmed = pi.AddNewHeader(chainB[1])
+	require.Empty(t, confirmed)
+	confirmed = pi.AddNewHeader(chainB[2])
+	require.Len(t, confirmed, 1)
+	require.Equal(t, chainB[0].Hash(), confirmed[0].Hash())
+	for _, el := range chainA[1:] {
+		_, ok := pi.blockBuffer[el.Hash()]
+		require.False(t, ok)
+	}
+}
+
+func TestMissedBlocksAreFetched(t *testing.T) {
+	fake, client := newFakeEthClient(t, 20)
+	pi := newTestInstance(nil)
+	pi.EthClient = client
+	pi.confirmDepth = 0
+
+	genesis := fakeHeader(10)
+	chain := makeChain(genesis, 4, 0)
+	for _, el := range chain {
+		fake.headers[el.Hash()] = el
+	}
+	require.Equal(t, []int64{10}, heightsOf(pi.AddNewHeader(genesis)))
+	require.Equal(t, []int64{11}, heightsOf(pi.AddNewHeader(chain[0])))
+	// we miss 12 and 13 from the subscription
+	require.Equal(t, []int64{12, 13, 14}, heightsOf(pi.AddNewHeader(chain[3])))
+}
+
+func TestDeepReorgCancelInbound(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	pi := newTestInstance(nil)
+	pi.InboundReqChan = make(chan *InboundReq, 10)
+	pi.tokens = newTestTokens(accs[0].commAddr)
+	pi.confirmDepth = 0
+	genesis := fakeHeader(10)
+	chainA := makeChain(genesis, 2, 'a')
+	chainB := makeChain(genesis, 3, 'b')
+
+	pi.AddNewHeader(genesis)
+	for _, el := range chainA {
+		require.Len(t, pi.AddNewHeader(el), 1)
+	}
+
+	// the half-matched deposit at 11a, the fee only at 12a and a full deposit across 10 and 12a
+	err = pi.processInboundTx(hex.EncodeToString([]byte("test1")), 11, accs[1].joltAddr, accs[2].commAddr, big.NewInt(11), accs[0].commAddr)
+	require.NoError(t, err)
+	require.Nil(t, pi.updateInboundTx(hex.EncodeToString([]byte("test2")), big.NewInt(10), 12))
+	pi.updateInboundTx(hex.EncodeToString([]byte("test3")), big.NewInt(10), 10)
+	err = pi.processInboundTx(hex.EncodeToString([]byte("test3")), 12, accs[1].joltAddr, accs[2].commAddr, big.NewInt(11), accs[0].commAddr)
+	require.NoError(t, err)
+	require.Len(t, pi.InboundReqChan, 1)
+
+	// the requests from the untouched block survive
+	keep := NewAccountInboundReq(accs[1].joltAddr, accs[2].commAddr, sdk.NewCoin(config.InBoundDenom, sdk.NewInt(1)), []byte("keep"), 0)
+	keep.sourceHeights = []int64{9, 10}
+	pi.AddItem(&keep)
+	cancelled := NewAccountInboundReq(accs[1].joltAddr, accs[2].commAddr, sdk.NewCoin(config.InBoundDenom, sdk.NewInt(1)), []byte("cancelled"), 0)
+	cancelled.sourceHeights = []int64{10, 11}
+	pi.AddItem(&cancelled)
+
+	// the bridge cancels the inbounds it has taken from the chain
+	var reorged map[int64]bool
+	pi.OnReorg = func(heights map[int64]bool) {
+		reorged = heights
+	}
+
+	// now the chain b reorgs out the processed blocks 11a and 12a
+	confirmed := pi.AddNewHeader(chainB[0])
+	require.Equal(t, map[int64]bool{11: true, 12: true}, reorged)
+	require.Equal(t, []int64{11}, heightsOf(confirmed))
+
+	_, ok := pi.pendingInbounds.Load(hex.EncodeToString([]byte("test1")))
+	require.False(t, ok)
+	_, ok = pi.pendingInboundsBnB.Load(hex.EncodeToString([]byte("test2")))
+	require.False(t, ok)
+	require.Len(t, pi.InboundReqChan, 0)
+	require.Equal(t, 1, pi.Size())
+	require.Equal(t, keep.Hash(), pi.PopItem().Hash())
+}
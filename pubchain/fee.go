@@ -0,0 +1,115 @@
+package pubchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// txFee is the gas price of a legacy tx or the fee caps of a dynamic fee (EIP-1559) tx
+type txFee struct {
+	gasPrice  *big.Int
+	gasTipCap *big.Int
+	gasFeeCap *big.Int
+}
+
+// dynamic returns true if the fee is for a dynamic fee tx
+func (f txFee) dynamic() bool {
+	return f.gasFeeCap != nil
+}
+
+// maxGasPrice returns the highest price per gas the tx may pay
+func (f txFee) maxGasPrice() *big.Int {
+	if f.dynamic() {
+		return f.gasFeeCap
+	}
+	return f.gasPrice
+}
+
+// apply sets the fee on the transact options of the contract call
+func (f txFee) apply(opts *bind.TransactOpts) {
+	if f.dynamic() {
+		opts.GasTipCap = f.gasTipCap
+		opts.GasFeeCap = f.gasFeeCap
+		return
+	}
+	opts.GasPrice = f.gasPrice
+}
+
+// newTx builds the dynamic fee tx or the legacy tx according to the fee
+func (f txFee) newTx(chainID *big.Int, nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte) *ethTypes.Transaction {
+	if f.dynamic() {
+		return ethTypes.NewTx(&ethTypes.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: f.gasTipCap,
+			GasFeeCap: f.gasFeeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+	return ethTypes.NewTx(&ethTypes.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: f.gasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	})
+}
+
+// getChainID returns the chain ID used to sign the txs, the dynamic fee txs must carry the chain ID rather
+// than the network ID
+func (pi *PubChainInstance) getChainID(ctx context.Context) (*big.Int, error) {
+	if pi.chainID != 0 {
+		return new(big.Int).SetUint64(pi.chainID), nil
+	}
+	return pi.getEthClient().ChainID(ctx)
+}
+
+// suggestFee returns the fee of the tx signed at the given height, we use the dynamic fee if the chain has activated
+// the London fork, otherwise we fall back to the legacy gas price. The dynamic fee is taken from the base fee at the
+// keysign height and the configured tip, so all the signers build the same tx
+func (pi *PubChainInstance) suggestFee(ctx context.Context, blockHeight int64) (txFee, error) {
+	client := pi.getEthClient()
+	var head *ethTypes.Header
+	if !pi.legacyTx {
+		var err error
+		head, err = client.HeaderByNumber(ctx, big.NewInt(blockHeight))
+		if err != nil {
+			return txFee{}, err
+		}
+	}
+	if head == nil || head.BaseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return txFee{}, err
+		}
+		return txFee{gasPrice: gasPrice}, nil
+	}
+
+	// the tip suggested by the node differs from node to node, so we only take the configured one
+	if pi.gasTipCap == 0 {
+		return txFee{}, errors.New("the gas tip cap is not set")
+	}
+	tip := new(big.Int).SetUint64(pi.gasTipCap)
+	// we leave room for the base fee to double before the tx gets stuck
+	feeCap := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip)
+	if pi.maxGasFeeCap != 0 {
+		maxFeeCap := new(big.Int).SetUint64(pi.maxGasFeeCap)
+		if feeCap.Cmp(maxFeeCap) == 1 {
+			pi.logger.Warn().Msgf("the fee cap %v exceeds the max fee cap, we use %v", feeCap, maxFeeCap)
+			feeCap = maxFeeCap
+		}
+	}
+	if tip.Cmp(feeCap) == 1 {
+		tip = new(big.Int).Set(feeCap)
+	}
+	return txFee{gasTipCap: tip, gasFeeCap: feeCap}, nil
+}
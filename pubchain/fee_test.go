@@ -0,0 +1,83 @@
+package pubchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeEthService) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(50))
+}
+
+func TestSuggestFee(t *testing.T) {
+	fake, client := newFakeEthClient(t, 10)
+	pi := newTestInstance(nil)
+	pi.EthClient = client
+
+	// the chain has not activated the london fork
+	fee, err := pi.suggestFee(context.Background(), 5)
+	require.NoError(t, err)
+	require.False(t, fee.dynamic())
+	require.Equal(t, int64(50), fee.maxGasPrice().Int64())
+
+	fake.lock.Lock()
+	fake.baseFee = big.NewInt(100)
+	fake.lock.Unlock()
+	// the tip suggested by the node is never used
+	_, err = pi.suggestFee(context.Background(), 5)
+	require.Error(t, err)
+	pi.gasTipCap = 7
+	fee, err = pi.suggestFee(context.Background(), 5)
+	require.NoError(t, err)
+	require.True(t, fee.dynamic())
+	require.Equal(t, int64(7), fee.gasTipCap.Int64())
+	require.Equal(t, int64(207), fee.gasFeeCap.Int64())
+	// the base fee is read at the keysign height
+	_, err = pi.suggestFee(context.Background(), 11)
+	require.Error(t, err)
+
+	// the fee cap is bounded and the tip never exceeds the fee cap
+	pi.gasTipCap = 200
+	pi.maxGasFeeCap = 150
+	fee, err = pi.suggestFee(context.Background(), 5)
+	require.NoError(t, err)
+	require.Equal(t, int64(150), fee.gasTipCap.Int64())
+	require.Equal(t, int64(150), fee.gasFeeCap.Int64())
+
+	pi.legacyTx = true
+	fee, err = pi.suggestFee(context.Background(), 5)
+	require.NoError(t, err)
+	require.False(t, fee.dynamic())
+	require.Equal(t, int64(50), fee.gasPrice.Int64())
+}
+
+func TestSignDynamicFeeTx(t *testing.T) {
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+	pi := newTestInstance(nil)
+	pi.tssServer = &TssMock{accs[1].sk}
+	chainID := big.NewInt(56)
+	txOption, err := pi.composeTx(accs[1].pk, accs[1].commAddr, chainID, 100)
+	require.NoError(t, err)
+
+	fees := []txFee{
+		{gasPrice: big.NewInt(10)},
+		{gasTipCap: big.NewInt(2), gasFeeCap: big.NewInt(20)},
+	}
+	for i, fee := range fees {
+		tx := fee.newTx(chainID, uint64(i), &accs[0].commAddr, big.NewInt(1), 21000, nil)
+		signed, err := txOption.Signer(accs[1].commAddr, tx)
+		require.NoError(t, err)
+		sender, err := ethTypes.Sender(ethTypes.LatestSignerForChainID(chainID), signed)
+		require.NoError(t, err)
+		require.Equal(t, accs[1].commAddr, sender)
+		require.Equal(t, fee.maxGasPrice(), signed.GasFeeCap())
+	}
+	require.Equal(t, uint8(ethTypes.LegacyTxType), fees[0].newTx(chainID, 0, nil, nil, 0, nil).Type())
+	require.Equal(t, uint8(ethTypes.DynamicFeeTxType), fees[1].newTx(chainID, 0, nil, nil, 0, nil).Type())
+}
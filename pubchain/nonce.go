@@ -0,0 +1,160 @@
+package pubchain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog"
+)
+
+// nonceSource is the part of the eth client the nonce manager relies on
+type nonceSource interface {
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// nonceWindow is how many public chain heights we remember the nonces handed out at
+const nonceWindow = 256
+
+// accountNonces is the nonce state of one pool account
+type accountNonces struct {
+	// bases are the nonces of the account in the blocks at the heights we sign at
+	bases map[int64]uint64
+	// inFlight are the broadcast txs that are not known to be mined
+	inFlight map[uint64]common.Hash
+	// reserved are the nonces handed out for the txs that are not broadcast yet, keyed to the height they are signed at
+	reserved map[uint64]int64
+}
+
+// taken returns true if the nonce is used by a pending tx or reserved for a tx being signed
+func (acc *accountNonces) taken(nonce uint64) bool {
+	if _, ok := acc.inFlight[nonce]; ok {
+		return true
+	}
+	_, ok := acc.reserved[nonce]
+	return ok
+}
+
+// NonceManager hands out the nonces of the pool accounts, the nonce only depends on the chain state at the keysign
+// height and the txs we have signed before, so all the signers of a tx pick the same nonce
+type NonceManager struct {
+	lock     sync.Mutex
+	source   func() nonceSource
+	accounts map[common.Address]*accountNonces
+	logger   zerolog.Logger
+}
+
+func newNonceManager(logger zerolog.Logger, source func() nonceSource) *NonceManager {
+	return &NonceManager{
+		source:   source,
+		accounts: make(map[common.Address]*accountNonces),
+		logger:   logger,
+	}
+}
+
+func (nm *NonceManager) account(addr common.Address) *accountNonces {
+	acc, ok := nm.accounts[addr]
+	if !ok {
+		acc = &accountNonces{
+			bases:    make(map[int64]uint64),
+			inFlight: make(map[uint64]common.Hash),
+			reserved: make(map[uint64]int64),
+		}
+		nm.accounts[addr] = acc
+	}
+	return acc
+}
+
+// Next reserves the nonce of the tx signed from the account at the public chain height, it is the lowest nonce from
+// the one of the account in the block at the height that is neither used by a broadcast tx still pending nor reserved
+// for a tx being signed. So the txs signed while the earlier ones are pending take the nonces above them, and the
+// nonces of the txs that are never broadcast are handed out again once they are released.
+func (nm *NonceManager) Next(ctx context.Context, addr common.Address, height int64) (uint64, error) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	acc := nm.account(addr)
+	base, ok := acc.bases[height]
+	if !ok {
+		var err error
+		base, err = nm.source().NonceAt(ctx, addr, big.NewInt(height))
+		if err != nil {
+			return 0, err
+		}
+		acc.bases[height] = base
+		for h := range acc.bases {
+			if h <= height-nonceWindow {
+				delete(acc.bases, h)
+			}
+		}
+		// the reservations that are neither broadcast nor released in the window are given up
+		for nonce, h := range acc.reserved {
+			if h <= height-nonceWindow {
+				delete(acc.reserved, nonce)
+			}
+		}
+	}
+	nonce := base
+	for acc.taken(nonce) {
+		nonce++
+	}
+	acc.reserved[nonce] = height
+	return nonce, nil
+}
+
+// Release hands the reserved nonce back if its tx is not broadcast, so the next tx fills the gap
+func (nm *NonceManager) Release(addr common.Address, nonce uint64) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	delete(nm.account(addr).reserved, nonce)
+}
+
+// Track records the tx broadcast with the nonce, the nonce is taken until the tx is mined
+func (nm *NonceManager) Track(addr common.Address, nonce uint64, txHash common.Hash) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	acc := nm.account(addr)
+	acc.inFlight[nonce] = txHash
+	delete(acc.reserved, nonce)
+}
+
+// Mined removes the tx from the in-flight txs once it is in the chain
+func (nm *NonceManager) Mined(txHash common.Hash) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	for _, acc := range nm.accounts {
+		for nonce, h := range acc.inFlight {
+			if h == txHash {
+				delete(acc.inFlight, nonce)
+				return
+			}
+		}
+	}
+}
+
+// InFlight returns the broadcast txs of the account that are not known to be mined
+func (nm *NonceManager) InFlight(addr common.Address) map[uint64]common.Hash {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	ret := make(map[uint64]common.Hash)
+	for nonce, h := range nm.account(addr).inFlight {
+		ret[nonce] = h
+	}
+	return ret
+}
+
+// finishSend records the result of broadcasting the tx, the accepted tx is tracked until it is mined, the nonce of
+// the rejected tx is released for the next tx. The standby tx is left to the leader to broadcast.
+func (pi *PubChainInstance) finishSend(signerPk string, sender common.Address, tx *ethTypes.Transaction, blockHeight int64, standby bool, err error) {
+	if err == nil || err.Error() == "already known" {
+		if err := pi.trackBroadcast(signerPk, sender, tx, blockHeight, standby); err != nil {
+			pi.logger.Error().Err(err).Msgf("fail to track the tx %v", tx.Hash().Hex())
+			return
+		}
+		pi.nonces.Track(sender, tx.Nonce(), tx.Hash())
+		return
+	}
+	pi.logger.Warn().Err(err).Msgf("the tx %v with nonce %v of %v is rejected", tx.Hash().Hex(), tx.Nonce(), sender.Hex())
+	pi.nonces.Release(sender, tx.Nonce())
+}
@@ -0,0 +1,109 @@
+package pubchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNonceSource struct {
+	// nonces are the nonces of the account keyed by the block height
+	nonces  map[int64]uint64
+	queries int
+}
+
+func (f *fakeNonceSource) NonceAt(_ context.Context, _ common.Address, blockNumber *big.Int) (uint64, error) {
+	f.queries++
+	return f.nonces[blockNumber.Int64()], nil
+}
+
+func TestNonceManager(t *testing.T) {
+	source := &fakeNonceSource{nonces: map[int64]uint64{100: 3, 101: 5}}
+	nm := newNonceManager(log.Logger, func() nonceSource {
+		return source
+	})
+	pool := common.HexToAddress("0x33875278f7757f6b43abC223EeC4a9D6204186a0")
+	ctx := context.Background()
+
+	// the txs signed at the same height take the nonces following the one in the block at the height
+	var nonces []uint64
+	for i := 0; i < 3; i++ {
+		nonce, err := nm.Next(ctx, pool, 100)
+		require.NoError(t, err)
+		nonces = append(nonces, nonce)
+	}
+	require.Equal(t, []uint64{3, 4, 5}, nonces)
+	require.Equal(t, 1, source.queries)
+
+	for i, el := range nonces {
+		nm.Track(pool, el, common.BigToHash(big.NewInt(int64(i))))
+	}
+
+	// another signer that has broadcast the same txs picks the same nonce, the mined txs are below the nonce of the
+	// account at the height
+	other := newNonceManager(log.Logger, func() nonceSource {
+		return source
+	})
+	other.Track(pool, 5, common.BigToHash(big.NewInt(2)))
+	nonce, err := other.Next(ctx, pool, 101)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), nonce)
+	nonce, err = nm.Next(ctx, pool, 101)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), nonce)
+
+	// the heights out of the window are forgotten
+	source.nonces[100+nonceWindow] = 9
+	nonce, err = nm.Next(ctx, pool, 100+nonceWindow)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), nonce)
+	_, ok := nm.account(pool).bases[100]
+	require.False(t, ok)
+}
+
+func TestNonceManagerPendingTxs(t *testing.T) {
+	// the first tx is signed at height 100 and still pending at height 101
+	source := &fakeNonceSource{nonces: map[int64]uint64{100: 3, 101: 3, 102: 3, 103: 4}}
+	nm := newNonceManager(log.Logger, func() nonceSource {
+		return source
+	})
+	pool := common.HexToAddress("0x33875278f7757f6b43abC223EeC4a9D6204186a0")
+	ctx := context.Background()
+
+	nonce, err := nm.Next(ctx, pool, 100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), nonce)
+	nm.Track(pool, 3, common.HexToHash("0x3"))
+
+	// the tx signed at a later height takes the nonce above the pending one
+	nonce, err = nm.Next(ctx, pool, 101)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), nonce)
+	// the tx being signed holds its nonce until it is broadcast or released
+	nonce, err = nm.Next(ctx, pool, 101)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), nonce)
+	nm.Track(pool, 5, common.HexToHash("0x5"))
+
+	// the tx with nonce 4 is never broadcast, the next tx fills the gap
+	nm.Release(pool, 4)
+	nonce, err = nm.Next(ctx, pool, 102)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), nonce)
+	nm.Track(pool, 4, common.HexToHash("0x4"))
+	nonce, err = nm.Next(ctx, pool, 102)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), nonce)
+	nm.Release(pool, 6)
+
+	// the mined txs are below the nonce of the account
+	nm.Mined(common.HexToHash("0x3"))
+	require.Equal(t, map[uint64]common.Hash{4: common.HexToHash("0x4"), 5: common.HexToHash("0x5")}, nm.InFlight(pool))
+	nonce, err = nm.Next(ctx, pool, 103)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), nonce)
+}
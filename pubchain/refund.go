@@ -0,0 +1,81 @@
+package pubchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// Refund is a deposit that cannot be credited on joltify, its token is paid back to the depositor from the pool that
+// received it. Height is the public chain block of the deposit.
+type Refund struct {
+	TxID   string         `json:"tx_id"`
+	To     common.Address `json:"to"`
+	Pool   common.Address `json:"pool"`
+	Coin   sdk.Coin       `json:"coin"`
+	Height int64          `json:"height"`
+}
+
+// refundDeposit keeps the refund of the deposit until the bridge queues its payout, the refund is in the joltify
+// denom of the token, so the payout is built like an outbound
+func (pi *PubChainInstance) refundDeposit(txID string, tokenAddr, depositor, pool common.Address, amount *big.Int, blockHeight uint64) error {
+	tokenInfo, ok := pi.tokens.ByAddress(tokenAddr)
+	if !ok {
+		return fmt.Errorf("unknown token %v", tokenAddr.Hex())
+	}
+	coin := sdk.NewCoin(tokenInfo.Denom, tokenInfo.ToJoltify(amount))
+	if !coin.IsPositive() {
+		return errors.New("the deposit is too small to be refunded")
+	}
+	refund := &Refund{
+		TxID:   txID,
+		To:     depositor,
+		Pool:   pool,
+		Coin:   coin,
+		Height: int64(blockHeight),
+	}
+	pi.refunds.Store(txID, refund)
+	pi.persist(pi.bucket(storage.RefundBucket), txID, refund)
+	pi.Transfers.Update(txID, monitor.TransferRefunding)
+	pi.logger.Warn().Msgf("the deposit %v cannot be credited on joltify, we refund %v to %v", txID, coin.String(), depositor.Hex())
+	return nil
+}
+
+// PopRefunds returns the refunds waiting for their payouts in the order of their blocks, they stay in the store until
+// FinishRefund is called once the payout is queued
+func (pi *PubChainInstance) PopRefunds() []*Refund {
+	var refunds []*Refund
+	pi.refunds.Range(func(key, value interface{}) bool {
+		refunds = append(refunds, value.(*Refund))
+		pi.refunds.Delete(key)
+		return true
+	})
+	sort.Slice(refunds, func(i, j int) bool {
+		if refunds[i].Height != refunds[j].Height {
+			return refunds[i].Height < refunds[j].Height
+		}
+		return refunds[i].TxID < refunds[j].TxID
+	})
+	return refunds
+}
+
+// FinishRefund removes the refund whose payout is queued from the store
+func (pi *PubChainInstance) FinishRefund(refund *Refund) {
+	pi.unpersist(pi.bucket(storage.RefundBucket), refund.TxID)
+}
+
+// RefundSize returns the number of the refunds waiting for their payouts
+func (pi *PubChainInstance) RefundSize() int {
+	i := 0
+	pi.refunds.Range(func(_, _ interface{}) bool {
+		i++
+		return true
+	})
+	return i
+}
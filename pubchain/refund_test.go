@@ -0,0 +1,52 @@
+package pubchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+func TestRefundDeposit(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+
+	pi := newTestInstance(store)
+	pi.tokens = newTestTokens(accs[0].commAddr)
+	wallet, pool := accs[1].commAddr, accs[2].commAddr
+	require.NoError(t, pi.refundDeposit("deposit1", accs[0].commAddr, wallet, pool, big.NewInt(10), 11))
+	require.NoError(t, pi.refundDeposit("deposit2", accs[0].commAddr, wallet, pool, big.NewInt(20), 12))
+	require.Error(t, pi.refundDeposit("deposit3", common.Address{}, wallet, pool, big.NewInt(20), 12))
+	require.Equal(t, 2, pi.RefundSize())
+
+	// the refunds are kept until their payouts are queued
+	refunds := pi.PopRefunds()
+	require.Len(t, refunds, 2)
+	require.Equal(t, "deposit1", refunds[0].TxID)
+	require.Equal(t, "10"+config.InBoundDenom, refunds[0].Coin.String())
+	pi.FinishRefund(refunds[0])
+
+	// now we kill the bridge and restart it with the same state store
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+	require.Equal(t, 1, restarted.RefundSize())
+
+	// the refund of the reorged block is cancelled
+	restarted.cancelReorgedInbounds(map[int64]bool{12: true})
+	require.Equal(t, 0, restarted.RefundSize())
+	require.Len(t, restarted.PopRefunds(), 0)
+}
@@ -0,0 +1,202 @@
+package pubchain
+
+import (
+	"encoding/json"
+	"strconv"
+
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+)
+
+// ChainID returns the ID of the public chain
+func (pi *PubChainInstance) ChainID() uint64 {
+	return pi.chainID
+}
+
+// Tokens returns the tokens bridged on this chain
+func (pi *PubChainInstance) Tokens() *bcommon.TokenRegistry {
+	return pi.tokens
+}
+
+// bucket returns the bucket of this chain in the state store
+func (pi *PubChainInstance) bucket(name string) string {
+	return storage.ChainBucket(name, pi.chainID)
+}
+
+// persist writes the item through to the state store if the store is enabled
+func (pi *PubChainInstance) persist(bucket, key string, value interface{}) {
+	if pi.stateStore == nil {
+		return
+	}
+	err := pi.stateStore.Put(bucket, key, value)
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to persist the item %v in %v", key, bucket)
+	}
+}
+
+// unpersist removes the item from the state store if the store is enabled
+func (pi *PubChainInstance) unpersist(bucket, key string) {
+	if pi.stateStore == nil {
+		return
+	}
+	err := pi.stateStore.Delete(bucket, key)
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to delete the item %v in %v", key, bucket)
+	}
+}
+
+// MigrateLegacyState moves the state written when the bridge had only one public chain into the buckets of
+// this chain
+func (pi *PubChainInstance) MigrateLegacyState() error {
+	if pi.stateStore == nil {
+		return nil
+	}
+	for _, el := range []string{storage.PendingInboundBucket, storage.PendingInboundBnBBucket, storage.RetryInboundBucket, storage.PubMoveFundBucket} {
+		if err := pi.stateStore.MoveBucket(el, pi.bucket(el)); err != nil {
+			return err
+		}
+	}
+	var height string
+	found, err := pi.stateStore.Get(storage.ChainHeightBucket, storage.PubChainHeightKey, &height)
+	if err != nil || !found {
+		return err
+	}
+	err = pi.stateStore.Put(storage.ChainHeightBucket, pi.bucket(storage.PubChainHeightKey), height)
+	if err != nil {
+		return err
+	}
+	return pi.stateStore.Delete(storage.ChainHeightBucket, storage.PubChainHeightKey)
+}
+
+// RestoreState reloads the pending inbounds, retry and move fund queues and the broadcast txs from the state store
+func (pi *PubChainInstance) RestoreState() error {
+	if pi.stateStore == nil {
+		return nil
+	}
+	err := pi.stateStore.Iterate(pi.bucket(storage.PendingInboundBucket), func(key string, value []byte) error {
+		var tx inboundTx
+		if err := json.Unmarshal(value, &tx); err != nil {
+			return err
+		}
+		pi.pendingInbounds.Store(key, &tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = pi.stateStore.Iterate(pi.bucket(storage.PendingInboundBnBBucket), func(key string, value []byte) error {
+		var tx inboundTxBnb
+		if err := json.Unmarshal(value, &tx); err != nil {
+			return err
+		}
+		pi.pendingInboundsBnB.Store(key, &tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = pi.stateStore.Iterate(pi.bucket(storage.RetryInboundBucket), func(key string, value []byte) error {
+		var req InboundReq
+		if err := json.Unmarshal(value, &req); err != nil {
+			return err
+		}
+		pi.RetryInboundReq.Store(req.Hash().Big(), &req)
+		pi.observeInbound(&req)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the requests handed to the processing when the bridge stopped go back to the retry queue
+	var inflight []*InboundReq
+	err = pi.stateStore.Iterate(pi.bucket(storage.InflightInboundBucket), func(key string, value []byte) error {
+		var req InboundReq
+		if err := json.Unmarshal(value, &req); err != nil {
+			return err
+		}
+		inflight = append(inflight, &req)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, el := range inflight {
+		pi.observeInbound(el)
+		pi.AddItem(el)
+	}
+
+	err = pi.stateStore.Iterate(pi.bucket(storage.RefundBucket), func(key string, value []byte) error {
+		var refund Refund
+		if err := json.Unmarshal(value, &refund); err != nil {
+			return err
+		}
+		pi.refunds.Store(key, &refund)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = pi.stateStore.Iterate(pi.bucket(storage.DroppedPendingBucket), func(key string, value []byte) error {
+		var tx inboundTx
+		if err := json.Unmarshal(value, &tx); err != nil {
+			return err
+		}
+		pi.droppedPending.Store(key, &tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = pi.stateStore.Iterate(pi.bucket(storage.DroppedPendingBnBBucket), func(key string, value []byte) error {
+		var tx inboundTxBnb
+		if err := json.Unmarshal(value, &tx); err != nil {
+			return err
+		}
+		pi.droppedPendingBnB.Store(key, &tx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the dropped inbounds are not observed, so they cannot be signed until they are requeued
+	err = pi.stateStore.Iterate(pi.bucket(storage.DroppedInboundBucket), func(key string, value []byte) error {
+		var req InboundReq
+		if err := json.Unmarshal(value, &req); err != nil {
+			return err
+		}
+		pi.droppedInbounds.Store(key, &req)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = pi.stateStore.Iterate(pi.bucket(storage.PubMoveFundBucket), func(key string, value []byte) error {
+		height, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return err
+		}
+		var pool bcommon.PoolInfo
+		if err := json.Unmarshal(value, &pool); err != nil {
+			return err
+		}
+		pi.moveFundReq.Store(height, &pool)
+		pi.SignPolicy.Observe(tssclient.RetiredPoolKey(pool.EthAddress.Hex()), pool.Pk)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := pi.restoreBroadcast(); err != nil {
+		return err
+	}
+	pi.logger.Info().Msgf("we have restored %v retry inbound items and %v broadcast txs from the state store", pi.Size(), pi.BroadcastSize())
+	return nil
+}
@@ -0,0 +1,242 @@
+package pubchain
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"sync"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	common2 "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/generated"
+	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
+)
+
+// newTestTokens creates the registry that bridges the given token as the inbound denom
+func newTestTokens(tokenAddr common.Address) *common2.TokenRegistry {
+	tokens, err := common2.NewTokenRegistry(0, []config.TokenConfig{{Address: tokenAddr.Hex(), Denom: config.InBoundDenom, Decimals: 18}})
+	if err != nil {
+		panic(err)
+	}
+	return tokens
+}
+
+func newTestInstance(store *storage.StateStore) *PubChainInstance {
+	parser, err := generated.NewTokenFilterer(common.Address{}, nil)
+	if err != nil {
+		panic(err)
+	}
+	pi := &PubChainInstance{
+		transferParser:     parser,
+		lastTwoPools:       make([]*common2.PoolInfo, 2),
+		poolLocker:         &sync.RWMutex{},
+		pendingInbounds:    &sync.Map{},
+		pendingInboundsBnB: &sync.Map{},
+		RetryInboundReq:    &sync.Map{},
+		moveFundReq:        &sync.Map{},
+		droppedInbounds:    &sync.Map{},
+		droppedPending:     &sync.Map{},
+		droppedPendingBnB:  &sync.Map{},
+		refunds:            &sync.Map{},
+		broadcastTxs:       &sync.Map{},
+		InboundReqChan:     make(chan *InboundReq, 1),
+		stateStore:         store,
+		blockBuffer:        make(map[common.Hash]*bufferedBlock),
+		blockLocker:        &sync.Mutex{},
+		ctx:                context.Background(),
+	}
+	pi.nonces = newNonceManager(pi.logger, func() nonceSource {
+		return pi.getEthClient()
+	})
+	return pi
+}
+
+func TestRestoreState(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	pi := newTestInstance(store)
+	pi.tokens = newTestTokens(accs[0].commAddr)
+
+	// we have a half-matched erc20 deposit and a half-matched fee payment
+	err = pi.processInboundTx(hex.EncodeToString([]byte("test1")), 10, accs[1].joltAddr, accs[2].commAddr, big.NewInt(11), accs[0].commAddr)
+	require.NoError(t, err)
+	ret := pi.updateInboundTx(hex.EncodeToString([]byte("test2")), big.NewInt(8), 12)
+	require.Nil(t, ret)
+
+	reqs, _, err := createNreq(3)
+	require.NoError(t, err)
+	for _, el := range reqs {
+		pi.AddItem(el)
+	}
+	// one retry item is waiting in a mint batch when the bridge is killed
+	popped := pi.PopItem()
+	require.NotNil(t, popped)
+
+	poolInfo := vaulttypes.PoolInfo{
+		BlockHeight: "100",
+		CreatePool: &vaulttypes.PoolProposal{
+			PoolPubKey: accs[0].pk,
+			PoolAddr:   accs[0].joltAddr,
+		},
+	}
+	err = pi.UpdatePool(&poolInfo)
+	require.NoError(t, err)
+	pi.AddMoveFundItem(pi.GetPool()[1], 20)
+
+	// now we kill the bridge and restart it with the same state store
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newTestInstance(store)
+	err = restarted.RestoreState()
+	require.NoError(t, err)
+
+	data, ok := restarted.pendingInbounds.Load(hex.EncodeToString([]byte("test1")))
+	require.True(t, ok)
+	tx := data.(*inboundTx)
+	require.True(t, tx.address.Equals(accs[1].joltAddr))
+	require.Equal(t, config.InBoundDenom, tx.token.Denom)
+	require.Equal(t, "11", tx.token.Amount.String())
+
+	data, ok = restarted.pendingInboundsBnB.Load(hex.EncodeToString([]byte("test2")))
+	require.True(t, ok)
+	require.Equal(t, "8", data.(*inboundTxBnb).fee.Amount.String())
+
+	// the popped item is back in the retry queue
+	require.Equal(t, 3, restarted.Size())
+	hashes := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		item := restarted.PopItem()
+		require.NotNil(t, item)
+		require.Equal(t, sdk.NewCoin("test", sdk.NewInt(1)), item.coin)
+		hashes[item.Hash().Hex()] = true
+	}
+	require.Nil(t, restarted.PopItem())
+	require.True(t, hashes[popped.Hash().Hex()])
+
+	pool, height := restarted.PopMoveFundItem()
+	require.Equal(t, int64(20), height)
+	require.Equal(t, accs[0].commAddr.String(), pool.EthAddress.String())
+	require.Equal(t, accs[0].pk, pool.PoolInfo.CreatePool.PoolPubKey)
+
+	// the fee arrives after the restart and the pending inbound is cleared from the store as well
+	bnbRet := restarted.updateInboundTx(hex.EncodeToString([]byte("test1")), big.NewInt(10), 13)
+	require.NotNil(t, bnbRet)
+	counter := 0
+	err = store.Iterate(pi.bucket(storage.PendingInboundBucket), func(key string, value []byte) error {
+		counter++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, counter)
+}
+
+func TestChainState(t *testing.T) {
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	// the state written before we support several chains belongs to the first chain after the migration
+	legacy := inboundTx{accs[1].joltAddr, 10, sdk.NewCoin(config.InBoundDenom, sdk.NewInt(11)), sdk.NewCoin(config.InBoundDenomFee, sdk.NewInt(0))}
+	require.NoError(t, store.Put(storage.PendingInboundBucket, "legacy", &legacy))
+	require.NoError(t, store.Put(storage.ChainHeightBucket, storage.PubChainHeightKey, "100"))
+
+	bsc := newTestInstance(store)
+	bsc.chainID = 56
+	bsc.tokens = newTestTokens(accs[0].commAddr)
+	require.NoError(t, bsc.MigrateLegacyState())
+	require.NoError(t, bsc.RestoreState())
+	_, ok := bsc.pendingInbounds.Load("legacy")
+	require.True(t, ok)
+	require.Equal(t, int64(100), bsc.GetProcessedHeight())
+
+	// the other chain keeps its own queues and processed height
+	eth := newTestInstance(store)
+	eth.chainID = 1
+	eth.tokens = newTestTokens(accs[0].commAddr)
+	require.NoError(t, eth.RestoreState())
+	_, ok = eth.pendingInbounds.Load("legacy")
+	require.False(t, ok)
+	require.Equal(t, int64(0), eth.GetProcessedHeight())
+
+	eth.SaveProcessedHeight(200)
+	reqs, _, err := createNreq(1)
+	require.NoError(t, err)
+	eth.AddItem(reqs[0])
+	restarted := newTestInstance(store)
+	restarted.chainID = 56
+	require.NoError(t, restarted.RestoreState())
+	require.Equal(t, 0, restarted.Size())
+	require.Equal(t, int64(100), restarted.GetProcessedHeight())
+}
+
+func TestInflightInbounds(t *testing.T) {
+	folder := t.TempDir()
+	store, err := storage.NewStateStore(folder)
+	require.NoError(t, err)
+	pi := newTestInstance(store)
+	pi.InboundReqChan = make(chan *InboundReq, 3)
+
+	reqs, _, err := createNreq(3)
+	require.NoError(t, err)
+	for _, el := range reqs {
+		pi.SendInbound(el)
+	}
+	// the first inbound is minted, the second one fails and waits for retry, the last one is still in the channel
+	pi.ForgetInbound(<-pi.InboundReqChan)
+	pi.AddItem(<-pi.InboundReqChan)
+
+	// now we kill the bridge and restart it with the same state store
+	require.NoError(t, store.Close())
+	store, err = storage.NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	restarted := newTestInstance(store)
+	require.NoError(t, restarted.RestoreState())
+
+	require.Equal(t, 2, restarted.Size())
+	hashes := map[string]bool{reqs[1].Hash().Hex(): true, reqs[2].Hash().Hex(): true}
+	var popped []*InboundReq
+	for i := 0; i < 2; i++ {
+		item := restarted.PopItem()
+		require.True(t, hashes[item.Hash().Hex()])
+		popped = append(popped, item)
+	}
+	countInflight := func() int {
+		counter := 0
+		err := store.Iterate(restarted.bucket(storage.InflightInboundBucket), func(key string, value []byte) error {
+			counter++
+			return nil
+		})
+		require.NoError(t, err)
+		return counter
+	}
+	// the popped items wait in the mint batch until they are minted
+	require.Equal(t, 2, countInflight())
+	for _, el := range popped {
+		restarted.ForgetInbound(el)
+	}
+	require.Equal(t, 0, countInflight())
+}
@@ -0,0 +1,223 @@
+package pubchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"gitlab.com/joltify/joltifychain-bridge/generated"
+)
+
+// getEthClient returns the client of the public chain, the client may be replaced after reconnection
+func (pi *PubChainInstance) getEthClient() *ethclient.Client {
+	pi.clientLocker.RLock()
+	defer pi.clientLocker.RUnlock()
+	return pi.EthClient
+}
+
+// Close closes the connection to the public chain node
+func (pi *PubChainInstance) Close() {
+	pi.cancel()
+	pi.getEthClient().Close()
+}
+
+// rpcError counts the failed call to the public chain node, the tx that is not found is an answer rather than a failure
+func (pi *PubChainInstance) rpcError(method string, err error) {
+	if err == nil || errors.Is(err, ethereum.NotFound) {
+		return
+	}
+	pi.Metric.AddRPCError(fmt.Sprintf("%v/%v", pi.chainID, method))
+}
+
+// getTokenInstance returns the binding of the given token on the current client
+func (pi *PubChainInstance) getTokenInstance(tokenAddr common.Address) (*generated.Token, error) {
+	if _, ok := pi.tokens.ByAddress(tokenAddr); !ok {
+		return nil, fmt.Errorf("token %v is not supported", tokenAddr.Hex())
+	}
+	return generated.NewToken(tokenAddr, pi.getEthClient())
+}
+
+// ReconnectCount returns how many times we have reconnected to the public chain
+func (pi *PubChainInstance) ReconnectCount() int64 {
+	return atomic.LoadInt64(&pi.reconnectCount)
+}
+
+// Subscribed returns true if the subscription of the new heads is working
+func (pi *PubChainInstance) Subscribed() bool {
+	return atomic.LoadInt32(&pi.subscribed) == 1
+}
+
+// StallTimeout returns how long we wait for a new head before we reconnect the public chain, zero disables the check
+func (pi *PubChainInstance) StallTimeout() time.Duration {
+	return pi.stallTimeout
+}
+
+// LastHeadTime returns when we received the last head of the public chain, it is zero if no head is received yet
+func (pi *PubChainInstance) LastHeadTime() time.Time {
+	last := atomic.LoadInt64(&pi.lastHeadTime)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+func (pi *PubChainInstance) setSubscribed(subscribed bool) {
+	var val int32
+	if subscribed {
+		val = 1
+	}
+	atomic.StoreInt32(&pi.subscribed, val)
+}
+
+// redial replaces the client with a new connection to the public chain
+func (pi *PubChainInstance) redial() error {
+	wsClient, err := ethclient.Dial(pi.wsAddress)
+	if err != nil {
+		return err
+	}
+	pi.clientLocker.Lock()
+	oldClient := pi.EthClient
+	pi.EthClient = wsClient
+	pi.clientLocker.Unlock()
+	if oldClient != nil {
+		oldClient.Close()
+	}
+	return nil
+}
+
+// resubscribe redials the public chain and subscribes the new heads again, it retries until it succeeds
+// or the context is cancelled
+func (pi *PubChainInstance) resubscribe(ctx context.Context, liveEvent chan *types.Header) (ethereum.Subscription, error) {
+	bf := backoff.NewExponentialBackOff()
+	bf.InitialInterval = time.Second
+	bf.MaxInterval = time.Second * 30
+	bf.MaxElapsedTime = 0
+
+	var blockSub ethereum.Subscription
+	op := func() error {
+		if err := pi.redial(); err != nil {
+			pi.logger.Error().Err(err).Msg("fail to redial the public chain")
+			return err
+		}
+		sub, err := pi.getEthClient().SubscribeNewHead(ctx, liveEvent)
+		if err != nil {
+			pi.logger.Error().Err(err).Msg("fail to resubscribe the block event")
+			return err
+		}
+		blockSub = sub
+		return nil
+	}
+	err := backoff.Retry(op, backoff.WithContext(bf, ctx))
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&pi.reconnectCount, 1)
+	pi.Metric.AddPubChainReconnect()
+	return blockSub, nil
+}
+
+// StartSubscription start the subscription of the token, the blocks missed since the last
+// processed height are replayed before the live blocks. If the subscription fails or no new
+// block arrives within the stall timeout, we reconnect and backfill the blocks in the gap.
+func (pi *PubChainInstance) StartSubscription(ctx context.Context, wg *sync.WaitGroup) (chan *types.Header, error) {
+	liveEvent := make(chan *types.Header, reqCacheSize)
+	blockSub, err := pi.getEthClient().SubscribeNewHead(ctx, liveEvent)
+	if err != nil {
+		fmt.Printf("fail to subscribe the block event with err %v\n", err)
+		return nil, err
+	}
+	pi.setSubscribed(true)
+
+	blockEvent := make(chan *types.Header)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			pi.setSubscribed(false)
+			blockSub.Unsubscribe()
+		}()
+
+		lastHeight := pi.replayMissedBlocks(ctx, blockEvent, pi.GetProcessedHeight())
+		replayed := lastHeight
+
+		var stallC <-chan time.Time
+		var stallTimer *time.Timer
+		if pi.stallTimeout > 0 {
+			stallTimer = time.NewTimer(pi.stallTimeout)
+			defer stallTimer.Stop()
+			stallC = stallTimer.C
+		}
+
+		for {
+			reconnect := false
+			select {
+			case <-ctx.Done():
+				pi.logger.Info().Msgf("shutdown the public pub_chain subscription channel")
+				return
+			case err := <-blockSub.Err():
+				pi.logger.Error().Err(err).Msg("the public chain subscription is broken")
+				reconnect = true
+			case <-stallC:
+				pi.logger.Warn().Msgf("no new block from the public chain in %v", pi.stallTimeout)
+				reconnect = true
+			case head := <-liveEvent:
+				// a failed catch up leaves a gap before the new head, we replay the gap first
+				if lastHeight > 0 && head.Number.Int64() > lastHeight+1 {
+					lastHeight = pi.replayMissedBlocks(ctx, blockEvent, lastHeight)
+					if lastHeight > replayed {
+						replayed = lastHeight
+					}
+					if head.Number.Int64() > lastHeight+1 {
+						pi.logger.Warn().Msgf("the blocks after %v are still missing, we hold the block %v", lastHeight, head.Number)
+						break
+					}
+				}
+				// the block has already been replayed in the catch up
+				if head.Number.Int64() <= replayed {
+					break
+				}
+				select {
+				case blockEvent <- head:
+					lastHeight = head.Number.Int64()
+				case <-ctx.Done():
+					pi.logger.Info().Msgf("shutdown the public pub_chain subscription channel")
+					return
+				}
+			}
+
+			if reconnect {
+				pi.setSubscribed(false)
+				blockSub.Unsubscribe()
+				sub, err := pi.resubscribe(ctx, liveEvent)
+				if err != nil {
+					pi.logger.Info().Msgf("shutdown the public pub_chain subscription channel")
+					return
+				}
+				blockSub = sub
+				pi.setSubscribed(true)
+				pi.logger.Info().Msgf("we have reconnected to the public chain, backfill the blocks after %v", lastHeight)
+				lastHeight = pi.replayMissedBlocks(ctx, blockEvent, lastHeight)
+				if lastHeight > replayed {
+					replayed = lastHeight
+				}
+			}
+			if stallTimer != nil {
+				if !stallTimer.Stop() {
+					select {
+					case <-stallTimer.C:
+					default:
+					}
+				}
+				stallTimer.Reset(pi.stallTimeout)
+			}
+		}
+	}()
+	return blockEvent, nil
+}
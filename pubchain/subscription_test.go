@@ -0,0 +1,111 @@
+package pubchain
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// wsConns keeps the websocket connections of the fake server, httptest does not close the hijacked ones
+type wsConns struct {
+	conns sync.Map
+}
+
+func (w *wsConns) track(conn net.Conn, state http.ConnState) {
+	if state == http.StateNew {
+		w.conns.Store(conn, true)
+	}
+}
+
+// dropAll closes all the connections to simulate the broken network
+func (w *wsConns) dropAll() {
+	w.conns.Range(func(key, value interface{}) bool {
+		key.(net.Conn).Close()
+		w.conns.Delete(key)
+		return true
+	})
+}
+
+func newFakeWsInstance(t *testing.T, tip int64) (*fakeEthService, *wsConns, *PubChainInstance) {
+	fake, srv := newFakeEthServer(t, tip)
+	conns := &wsConns{}
+	ts := httptest.NewUnstartedServer(srv.WebsocketHandler([]string{"*"}))
+	ts.Config.ConnState = conns.track
+	ts.Start()
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Stop()
+	})
+
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, store.Close())
+	})
+
+	pi := newTestInstance(store)
+	pi.wsAddress = "ws" + strings.TrimPrefix(ts.URL, "http")
+	pi.tokens = newTestTokens(common.HexToAddress("0x33875278f7757f6b43abC223EeC4a9D6204186a0"))
+	pi.EthClient, err = ethclient.Dial(pi.wsAddress)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		pi.getEthClient().Close()
+	})
+	return fake, conns, pi
+}
+
+func TestSubscriptionReconnect(t *testing.T) {
+	fake, conns, pi := newFakeWsInstance(t, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	blockChan, err := pi.StartSubscription(ctx, &wg)
+	require.NoError(t, err)
+
+	fake.pushHead(fakeHeader(11))
+	require.Equal(t, []int64{11}, collectHeights(t, blockChan, 1))
+
+	// now the websocket drops and we miss two blocks
+	fake.setTip(13)
+	conns.dropAll()
+	require.Equal(t, []int64{12, 13}, collectHeights(t, blockChan, 2))
+	require.Equal(t, int64(1), pi.ReconnectCount())
+
+	fake.pushHead(fakeHeader(13))
+	fake.pushHead(fakeHeader(14))
+	require.Equal(t, []int64{14}, collectHeights(t, blockChan, 1))
+	cancel()
+	wg.Wait()
+}
+
+func TestSubscriptionStall(t *testing.T) {
+	fake, _, pi := newFakeWsInstance(t, 10)
+	pi.stallTimeout = time.Millisecond * 500
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	blockChan, err := pi.StartSubscription(ctx, &wg)
+	require.NoError(t, err)
+
+	fake.pushHead(fakeHeader(11))
+	require.Equal(t, []int64{11}, collectHeights(t, blockChan, 1))
+
+	// the node stops pushing the new heads but the chain moves on
+	fake.setTip(12)
+	require.Equal(t, []int64{12}, collectHeights(t, blockChan, 1))
+	require.GreaterOrEqual(t, pi.ReconnectCount(), int64(1))
+	cancel()
+	wg.Wait()
+}
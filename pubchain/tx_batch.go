@@ -0,0 +1,194 @@
+package pubchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/generated"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+)
+
+// OutboundPayout is one outbound of a batch paid with the multisend contract
+type OutboundPayout struct {
+	To   common.Address
+	Coin sdk.Coin
+}
+
+// OutboundBatchSize returns the max number of outbounds paid in one multisend tx, 0 means the batch mode is off
+func (pi *PubChainInstance) OutboundBatchSize() int {
+	if pi.multisendAddr == (common.Address{}) {
+		return 0
+	}
+	return pi.outboundBatchSize
+}
+
+// batchToken returns the token of the payouts, all the payouts of a batch must have the same denom
+func (pi *PubChainInstance) batchToken(payouts []OutboundPayout) (*bcommon.TokenInfo, error) {
+	if len(payouts) == 0 {
+		return nil, errors.New("empty outbound batch")
+	}
+	denom := payouts[0].Coin.Denom
+	for _, el := range payouts {
+		if el.Coin.Denom != denom {
+			return nil, errors.New("the outbound batch has more than one denom")
+		}
+	}
+	tokenInfo, ok := pi.tokens.ByDenom(denom)
+	if !ok {
+		return nil, fmt.Errorf("unknown denom %v", denom)
+	}
+	return tokenInfo, nil
+}
+
+// ErrBatchNotSent is returned when the outbound batch fails before its tx is broadcast, so the outbounds can be paid
+// one by one without being paid twice
+var ErrBatchNotSent = errors.New("the outbound batch is not sent")
+
+// ProcessOutBoundBatch pays the outbounds of one token from the pool in one multisend tx signed once, the pool
+// approves the multisend contract to spend exactly the batch total in the tx with the nonce before, so the batch
+// spends the whole allowance and nothing is left approved once it is mined. Only the leader of the submitters
+// broadcasts the txs at once, the errors wrap ErrBatchNotSent as the batch tx is watched once it is sent. signed is
+// called with the hash of the batch tx once both txs are signed and before any of them is broadcast.
+func (pi *PubChainInstance) ProcessOutBoundBatch(fromAddr common.Address, payouts []OutboundPayout, blockHeight int64, submitter bcommon.Submitter, signed func(txHash string)) (string, error) {
+	if pi.OutboundBatchSize() == 0 {
+		return "", fmt.Errorf("%w: the outbound batch mode is off", ErrBatchNotSent)
+	}
+	tokenInfo, err := pi.batchToken(payouts)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+	recipients := make([]common.Address, len(payouts))
+	values := make([]*big.Int, len(payouts))
+	total := big.NewInt(0)
+	for i, el := range payouts {
+		recipients[i] = el.To
+		values[i], err = tokenInfo.ToPubChain(el.Coin.Amount)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+		}
+		total.Add(total, values[i])
+	}
+
+	tokenInstance, err := pi.getTokenInstance(tokenInfo.Address)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+	multisend, err := generated.NewMultisend(pi.multisendAddr, pi.getEthClient())
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+
+	// the approval is only signed for the total of the payouts we have observed
+	payoutKeys := make([]string, len(payouts))
+	for i, el := range payouts {
+		payoutKeys[i] = tssclient.PayoutKey(pi.chainID, fromAddr, el.To, tokenInfo.Address, values[i])
+	}
+	approvalKey := tssclient.ApprovalKey(pi.chainID, fromAddr, tokenInfo.Address, total)
+	batchID := strconv.FormatInt(blockHeight, 10)
+	if err := pi.SignPolicy.ObserveDerived(approvalKey, batchID, payoutKeys...); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+	defer pi.SignPolicy.Forget(approvalKey, batchID)
+
+	// both txs are signed before any of them is broadcast
+	signerPk := pi.GetPool()[1].Pk
+	approveTx, err := pi.signContractTx(signerPk, fromAddr, blockHeight, func(txo *bind.TransactOpts) (*types.Transaction, error) {
+		return tokenInstance.Approve(txo, pi.multisendAddr, total)
+	})
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to approve the multisend contract for %v", tokenInfo.Denom)
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+	batchTx, err := pi.signContractTx(signerPk, fromAddr, blockHeight, func(txo *bind.TransactOpts) (*types.Transaction, error) {
+		// the gas cannot be estimated before the approval is mined
+		txo.GasLimit = GasLimit
+		return multisend.DisperseTokenSimple(txo, tokenInfo.Address, recipients, values)
+	})
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to sign the outbound batch")
+		// the outbounds are paid one by one from the nonce of the approval
+		pi.nonces.Release(fromAddr, approveTx.Nonce())
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+	if signed != nil {
+		signed(batchTx.Hash().Hex())
+	}
+
+	err = pi.submitTx(submitter, signerPk, fromAddr, approveTx, blockHeight)
+	if err != nil && err.Error() != "already known" {
+		pi.logger.Error().Err(err).Msgf("fail to approve the multisend contract for %v", tokenInfo.Denom)
+		// the nonce of the rejected approval is released once it is sent, the batch tx is never sent
+		pi.nonces.Release(fromAddr, batchTx.Nonce())
+		return "", fmt.Errorf("%w: %v", ErrBatchNotSent, err)
+	}
+	pi.logger.Info().Msgf("the pool %v pays %v outbounds of %v %v in tx %v", fromAddr, len(payouts), sdk.NewDecFromBigIntWithPrec(total, int64(tokenInfo.Decimals)), tokenInfo.Denom, batchTx.Hash().Hex())
+	err = pi.submitTx(submitter, signerPk, fromAddr, batchTx, blockHeight)
+	if err != nil && err.Error() != "already known" {
+		// the node may have got the tx before the error, so we watch it and send it again if it is stuck instead of
+		// paying the outbounds one by one
+		pi.logger.Error().Err(err).Msgf("fail to send the outbound batch %v, we keep watching it", batchTx.Hash().Hex())
+		pi.finishSend(signerPk, fromAddr, batchTx, blockHeight, false, nil)
+	}
+	return batchTx.Hash().Hex(), nil
+}
+
+// PayoutResults checks the Transfer events of the mined multisend tx, it returns whether each payout is done, txHash
+// is the hash of the mined tx, which may be one of the replacements
+func (pi *PubChainInstance) PayoutResults(ctx context.Context, txHash string, fromAddr common.Address, payouts []OutboundPayout) ([]bool, error) {
+	tokenInfo, err := pi.batchToken(payouts)
+	if err != nil {
+		return nil, err
+	}
+	bf := backoff.NewExponentialBackOff()
+	bf.InitialInterval = time.Second
+	bf.MaxInterval = time.Second * 3
+	bf.MaxElapsedTime = time.Minute
+
+	var receipt *types.Receipt
+	op := func() error {
+		queryCtx, cancel := context.WithTimeout(ctx, chainQueryTimeout)
+		defer cancel()
+		receipt, err = pi.getEthClient().TransactionReceipt(queryCtx, common.HexToHash(txHash))
+		pi.rpcError("eth_getTransactionReceipt", err)
+		return err
+	}
+	if err := backoff.Retry(op, backoff.WithContext(bf, ctx)); err != nil {
+		return nil, err
+	}
+	return pi.matchPayouts(receipt.Logs, tokenInfo, fromAddr, payouts), nil
+}
+
+// matchPayouts matches each payout with a Transfer event of the token from the pool, one event matches one payout
+func (pi *PubChainInstance) matchPayouts(logs []*types.Log, tokenInfo *bcommon.TokenInfo, fromAddr common.Address, payouts []OutboundPayout) []bool {
+	done := make([]bool, len(payouts))
+	for _, l := range logs {
+		if l.Address != tokenInfo.Address || len(l.Topics) == 0 {
+			continue
+		}
+		transfer, err := pi.transferParser.ParseTransfer(*l)
+		if err != nil || transfer.From != fromAddr {
+			continue
+		}
+		for i, el := range payouts {
+			amount, err := tokenInfo.ToPubChain(el.Coin.Amount)
+			if err != nil {
+				continue
+			}
+			if !done[i] && transfer.To == el.To && transfer.Value.Cmp(amount) == 0 {
+				done[i] = true
+				break
+			}
+		}
+	}
+	return done
+}
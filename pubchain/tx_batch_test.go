@@ -0,0 +1,65 @@
+package pubchain
+
+import (
+	"math/big"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+)
+
+func transferLog(token, from, to common.Address, value *big.Int) *ethTypes.Log {
+	return &ethTypes.Log{
+		Address: token,
+		Topics: []common.Hash{
+			crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: common.LeftPadBytes(value.Bytes(), 32),
+	}
+}
+
+func TestMatchPayouts(t *testing.T) {
+	tokenAddr := common.HexToAddress("0xeB42ff4cA651c91EB248f8923358b6144c6B4b79")
+	pool := common.HexToAddress("0x1")
+	pi := newTestInstance(nil)
+	pi.tokens = newTestTokens(tokenAddr)
+	require.Equal(t, 0, pi.OutboundBatchSize())
+	pi.multisendAddr = common.HexToAddress("0x2")
+	pi.outboundBatchSize = 10
+	require.Equal(t, 10, pi.OutboundBatchSize())
+
+	coin := func(amount int64) sdk.Coin {
+		return sdk.NewCoin(config.InBoundDenom, sdk.NewInt(amount))
+	}
+	payouts := []OutboundPayout{
+		{To: common.HexToAddress("0xa"), Coin: coin(5)},
+		{To: common.HexToAddress("0xa"), Coin: coin(5)},
+		{To: common.HexToAddress("0xb"), Coin: coin(7)},
+		{To: common.HexToAddress("0xc"), Coin: coin(9)},
+	}
+	tokenInfo, err := pi.batchToken(payouts)
+	require.NoError(t, err)
+
+	logs := []*ethTypes.Log{
+		transferLog(tokenAddr, pool, common.HexToAddress("0xa"), big.NewInt(5)),
+		// the transfers of other tokens, from other accounts or with other amounts are not ours
+		transferLog(common.HexToAddress("0x3"), pool, common.HexToAddress("0xb"), big.NewInt(7)),
+		transferLog(tokenAddr, common.HexToAddress("0x4"), common.HexToAddress("0xb"), big.NewInt(7)),
+		transferLog(tokenAddr, pool, common.HexToAddress("0xc"), big.NewInt(8)),
+		{Address: tokenAddr},
+		transferLog(tokenAddr, pool, common.HexToAddress("0xb"), big.NewInt(7)),
+	}
+	// one event pays only one of the two identical payouts
+	require.Equal(t, []bool{true, false, true, false}, pi.matchPayouts(logs, tokenInfo, pool, payouts))
+
+	_, err = pi.batchToken(append(payouts, OutboundPayout{Coin: sdk.NewCoin("other", sdk.NewInt(1))}))
+	require.Error(t, err)
+	_, err = pi.batchToken(nil)
+	require.Error(t, err)
+}
@@ -2,6 +2,7 @@ package pubchain
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,10 +12,13 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	zlog "github.com/rs/zerolog/log"
 	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/generated"
 	vaulttypes "gitlab.com/joltify/joltifychain/x/vault/types"
 	"html"
 	"math"
 	"math/big"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/cosmos/cosmos-sdk/types"
@@ -23,10 +27,15 @@ import (
 	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"gitlab.com/joltify/joltifychain-bridge/config"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 )
 
-// ProcessInBoundERC20 process the inbound contract token top-up
-func (pi *PubChainInstance) ProcessInBoundERC20(tx *ethTypes.Transaction, tokenAddr, transferTo common.Address, amount *big.Int, blockHeight uint64) error {
+// ProcessInBoundERC20 process the inbound contract token top-up, the token is credited to the depositor, whose
+// joltify address is derived from the public key of the tx. The deposit of a contract such as a smart wallet, which
+// is not the sender of the tx, has no joltify address we know, so its token is refunded to it.
+func (pi *PubChainInstance) ProcessInBoundERC20(tx *ethTypes.Transaction, txID string, tokenAddr, transferFrom, transferTo common.Address, amount *big.Int, blockHeight uint64) error {
 	v, r, s := tx.RawSignatureValues()
 	signer := ethTypes.LatestSignerForChainID(tx.ChainId())
 	plainV := misc.RecoverRecID(tx.ChainId().Uint64(), v)
@@ -37,14 +46,30 @@ func (pi *PubChainInstance) ProcessInBoundERC20(tx *ethTypes.Transaction, tokenA
 		pi.logger.Error().Err(err).Msg("fail to recover the public key")
 		return err
 	}
+	pubKey, err := crypto.UnmarshalPubkey(sigPublicKey)
+	if err != nil {
+		pi.logger.Error().Err(err).Msg("fail to unmarshal the public key")
+		return err
+	}
+	if crypto.PubkeyToAddress(*pubKey) != transferFrom {
+		// we only know the public key of the tx sender, the depositor cannot be credited on joltify
+		pi.Transfers.Observe(txID, monitor.Inbound, strconv.FormatUint(pi.chainID, 10))
+		err := pi.refundDeposit(txID, tokenAddr, transferFrom, transferTo, amount, blockHeight)
+		if err != nil {
+			err = fmt.Errorf("fail to refund the deposit of %v in tx %v: %w", transferFrom.Hex(), tx.Hash().Hex(), err)
+			pi.logger.Error().Err(err).Msg("fail to find the joltify address of the depositor")
+			pi.Transfers.Fail(txID, err)
+		}
+		return err
+	}
 
-	transferFrom, err := misc.EthSignPubKeyToJoltAddr(sigPublicKey)
+	joltFrom, err := misc.EthSignPubKeyToJoltAddr(sigPublicKey)
 	if err != nil {
 		pi.logger.Error().Err(err).Msg("fail to recover the joltify Address")
 		return err
 	}
 
-	err = pi.processInboundTx(tx.Hash().Hex()[2:], blockHeight, transferFrom, transferTo, amount, tokenAddr)
+	err = pi.processInboundTx(txID, blockHeight, joltFrom, transferTo, amount, tokenAddr)
 	if err != nil {
 		pi.logger.Error().Err(err).Msg("fail to process the inbound tx")
 		return err
@@ -52,16 +77,35 @@ func (pi *PubChainInstance) ProcessInBoundERC20(tx *ethTypes.Transaction, tokenA
 	return nil
 }
 
-// ProcessNewBlock process the blocks received from the public pub_chain
-func (pi *PubChainInstance) ProcessNewBlock(number *big.Int) error {
+// depositID is the ID of the deposit, the fee payment of the deposit carries this ID. A tx with one deposit is
+// identified by its hash, while each deposit of a tx carrying several deposits is identified by the tx hash
+// followed by the 4 bytes log index of its Transfer event
+func depositID(txHash common.Hash, logIndex uint, several bool) string {
+	if !several {
+		return hex.EncodeToString(txHash.Bytes())
+	}
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, uint32(logIndex))
+	return hex.EncodeToString(append(txHash.Bytes(), index...))
+}
+
+// ProcessNewBlock process the confirmed blocks received from the public pub_chain
+func (pi *PubChainInstance) ProcessNewBlock(blockHash common.Hash, joltifyBlockHeight int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
 	defer cancel()
-	block, err := pi.EthClient.BlockByNumber(ctx, number)
+	block, err := pi.getEthClient().BlockByHash(ctx, blockHash)
+	pi.rpcError("eth_getBlockByHash", err)
 	if err != nil {
 		pi.logger.Error().Err(err).Msg("fail to retrieve the block")
 		return err
 	}
-	pi.processEachBlock(block)
+	err = pi.processTransferEvents(block)
+	if err != nil {
+		pi.logger.Error().Err(err).Msg("fail to process the token transfer events")
+		return err
+	}
+	pi.processEachBlock(block, joltifyBlockHeight)
+	pi.SaveProcessedHeight(block.Number().Int64())
 	return nil
 }
 
@@ -76,6 +120,7 @@ func (pi *PubChainInstance) updateInboundTx(txID string, amount *big.Int, blockN
 			fee:         sdk.NewCoin(config.InBoundDenomFee, sdk.NewIntFromBigInt(amount)),
 		}
 		pi.pendingInboundsBnB.Store(txID, &inBnB)
+		pi.persist(pi.bucket(storage.PendingInboundBnBBucket), txID, &inBnB)
 		return nil
 	}
 
@@ -84,11 +129,13 @@ func (pi *PubChainInstance) updateInboundTx(txID string, amount *big.Int, blockN
 	err := thisAccount.Verify()
 	if err != nil {
 		pi.pendingInbounds.Store(txID, thisAccount)
+		pi.persist(pi.bucket(storage.PendingInboundBucket), txID, thisAccount)
 		pi.logger.Warn().Msgf("the account cannot be processed on joltify pub_chain this round with err %v\n", err)
 		return nil
 	}
 	// since this tx is processed,we do not need to store it any longer
 	pi.pendingInbounds.Delete(txID)
+	pi.unpersist(pi.bucket(storage.PendingInboundBucket), txID)
 	return thisAccount
 }
 
@@ -99,15 +146,17 @@ func (pi *PubChainInstance) processInboundTx(txID string, blockHeight uint64, fr
 		return errors.New("tx existed")
 	}
 
-	if addr.String() != pi.tokenAddr {
+	tokenInfo, ok := pi.tokens.ByAddress(addr)
+	if !ok {
 		pi.logger.Error().Msgf("incorrect top up token")
 		return errors.New("incorrect top up token")
 	}
 
 	token := types.Coin{
-		Denom:  config.InBoundDenom,
-		Amount: types.NewIntFromBigInt(value),
+		Denom:  tokenInfo.Denom,
+		Amount: tokenInfo.ToJoltify(value),
 	}
+	pi.Transfers.Observe(txID, monitor.Inbound, strconv.FormatUint(pi.chainID, 10))
 
 	inTxBnB, ok := pi.pendingInboundsBnB.LoadAndDelete(txID)
 	if !ok {
@@ -124,8 +173,10 @@ func (pi *PubChainInstance) processInboundTx(txID string, blockHeight uint64, fr
 		}
 		pi.logger.Info().Msgf("we add the tokens tx(%v):%v", txID, tx.token.String())
 		pi.pendingInbounds.Store(txID, &tx)
+		pi.persist(pi.bucket(storage.PendingInboundBucket), txID, &tx)
 		return nil
 	}
+	pi.unpersist(pi.bucket(storage.PendingInboundBnBBucket), txID)
 	fee := inTxBnB.(*inboundTxBnb).fee
 	tx := inboundTx{
 		from,
@@ -136,6 +187,7 @@ func (pi *PubChainInstance) processInboundTx(txID string, blockHeight uint64, fr
 	err := tx.Verify()
 	if err != nil {
 		pi.pendingInbounds.Store(txID, &tx)
+		pi.persist(pi.bucket(storage.PendingInboundBucket), txID, &tx)
 		pi.logger.Warn().Msgf("the account cannot be processed on joltify pub_chain this round with err %v\n", err)
 		return nil
 	}
@@ -145,77 +197,103 @@ func (pi *PubChainInstance) processInboundTx(txID string, blockHeight uint64, fr
 		return nil
 	}
 	item := NewAccountInboundReq(tx.address, to, tx.token, txIDBytes, int64(blockHeight))
-	pi.InboundReqChan <- &item
+	item.sourceHeights = []int64{int64(blockHeight), int64(inTxBnB.(*inboundTxBnb).blockHeight)}
+	pi.observeInbound(&item)
+	pi.Transfers.Update(txID, monitor.TransferFeeMatched)
+	pi.Transfers.Update(txID, monitor.TransferQueued)
+	pi.SendInbound(&item)
 	return nil
 }
 
-func (pi *PubChainInstance) checkErc20(data []byte) (common.Address, *big.Int, error) {
-	if method, ok := pi.tokenAbi.Methods["transfer"]; ok {
-		if len(data) < 4 {
-			return common.Address{}, nil, errors.New("invalid data")
+// processTransferEvents picks up the token deposits to the pools from the Transfer events of the block,
+// so the deposits made through other contracts are handled as well
+func (pi *PubChainInstance) processTransferEvents(block *ethTypes.Block) error {
+	var pools []common.Address
+	for _, el := range pi.GetPool() {
+		if el != nil {
+			pools = append(pools, el.EthAddress)
 		}
-		params, err := method.Inputs.Unpack(data[4:])
-		if err != nil {
-			return common.Address{}, nil, err
+	}
+	if len(pools) == 0 {
+		return nil
+	}
+
+	// we query the Transfer events of all the bridged tokens to the pools in one go
+	var poolTopics []common.Hash
+	for _, el := range pools {
+		poolTopics = append(poolTopics, common.BytesToHash(el.Bytes()))
+	}
+	height := block.Number()
+	query := ethereum.FilterQuery{
+		FromBlock: height,
+		ToBlock:   height,
+		Addresses: pi.tokens.Addresses(),
+		Topics:    [][]common.Hash{{pi.tokenAbi.Events["Transfer"].ID}, nil, poolTopics},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+	defer cancel()
+	logs, err := pi.getEthClient().FilterLogs(ctx, query)
+	pi.rpcError("eth_getLogs", err)
+	if err != nil {
+		return err
+	}
+
+	// a tx may carry several transfers to the pools (e.g. multisend), each of them is a deposit of its sender
+	var deposits []*generated.TokenTransfer
+	depositNum := make(map[common.Hash]int)
+	for _, el := range logs {
+		if el.Removed || el.BlockHash != block.Hash() {
+			continue
 		}
-		if len(params) != 2 {
-			return common.Address{}, nil, errors.New("invalid transfer parameter")
+		event, err := pi.transferParser.ParseTransfer(el)
+		if err != nil {
+			pi.logger.Warn().Err(err).Msgf("fail to parse the transfer event in tx %v", el.TxHash.Hex())
+			continue
 		}
-		toAddr, ok := params[0].(common.Address)
-		if !ok {
-			return common.Address{}, nil, errors.New("not valid address")
+		deposits = append(deposits, event)
+		depositNum[el.TxHash]++
+	}
+	sort.SliceStable(deposits, func(i, j int) bool {
+		return deposits[i].Raw.Index < deposits[j].Raw.Index
+	})
+
+	for _, el := range deposits {
+		tx := block.Transaction(el.Raw.TxHash)
+		if tx == nil {
+			pi.logger.Warn().Msgf("fail to find the tx %v in block %v", el.Raw.TxHash.Hex(), height)
+			continue
 		}
-		amount, ok := params[1].(*big.Int)
-		if !ok {
-			return common.Address{}, nil, errors.New("not valid amount")
+		txID := depositID(el.Raw.TxHash, el.Raw.Index, depositNum[el.Raw.TxHash] > 1)
+		err := pi.ProcessInBoundERC20(tx, txID, el.Raw.Address, el.From, el.To, el.Value, height.Uint64())
+		if err != nil {
+			pi.logger.Error().Err(err).Msg("fail to process the inbound contract message")
 		}
-		return toAddr, amount, nil
 	}
-	return common.Address{}, nil, errors.New("invalid method for decode")
+	return nil
 }
 
-// fixme we need to check timeout to remove the pending transactions
-func (pi *PubChainInstance) processEachBlock(block *ethTypes.Block) {
+// processEachBlock handles the native coin transfers to the pools, which pay the fee of the token deposits.
+// As the pools are not contracts, these transfers cannot fail once they are in the block, so we do not
+// need to query the receipts.
+func (pi *PubChainInstance) processEachBlock(block *ethTypes.Block, joltifyBlockHeight int64) {
 	for _, tx := range block.Transactions() {
-		if tx.To() == nil {
+		if tx.To() == nil || !pi.checkToBridge(*tx.To()) {
 			continue
 		}
-		status, err := pi.checkEachTx(tx.Hash())
-		if err != nil || status != 1 {
+		if tx.Data() == nil {
+			pi.logger.Warn().Msgf("we have received unknown fund")
 			continue
 		}
 
-		toAddr, amount, err := pi.checkErc20(tx.Data())
-		if err == nil {
-			if tx.To().Hex() != pi.tokenAddr {
-				// this indicates it is not to our smart contract
-				continue
-			}
-			// process the public chain inbound message to the channel
-			if !pi.checkToBridge(toAddr) {
-				pi.logger.Warn().Msg("the top up message is not to the bridge, ignored")
-				continue
-			}
-			err := pi.ProcessInBoundERC20(tx, *tx.To(), toAddr, amount, block.NumberU64())
-			if err != nil {
-				zlog.Logger.Error().Err(err).Msg("fail to process the inbound contract message")
-				continue
-			}
-		}
-
-		if pi.checkToBridge(*tx.To()) {
-			if tx.Data() == nil {
-				pi.logger.Warn().Msgf("we have received unknown fund")
-				continue
-			}
-
-			payTxID := tx.Data()
-			account := pi.updateInboundTx(hex.EncodeToString(payTxID), tx.Value(), block.NumberU64())
-			if account != nil {
-				item := NewAccountInboundReq(account.address, *tx.To(), account.token, payTxID, 0)
-				// we add to the retry pool to  sort the tx
-				pi.AddItem(&item)
-			}
+		payTxID := tx.Data()
+		account := pi.updateInboundTx(hex.EncodeToString(payTxID), tx.Value(), block.NumberU64())
+		if account != nil {
+			item := NewAccountInboundReq(account.address, *tx.To(), account.token, payTxID, joltifyBlockHeight)
+			item.sourceHeights = []int64{int64(account.pubBlockHeight), block.Number().Int64()}
+			pi.observeInbound(&item)
+			pi.Transfers.Update(item.SourceTx(), monitor.TransferFeeMatched)
+			// we add to the retry pool to  sort the tx
+			pi.AddItem(&item)
 		}
 	}
 }
@@ -250,6 +328,8 @@ func (pi *PubChainInstance) UpdatePool(pool *vaulttypes.PoolInfo) error {
 
 	if pi.lastTwoPools[1] != nil {
 		pi.lastTwoPools[0] = pi.lastTwoPools[1]
+		// the fund of the retired pool moves to the new pool
+		pi.SignPolicy.Observe(tssclient.RetiredPoolKey(pi.lastTwoPools[0].EthAddress.Hex()), pi.lastTwoPools[0].Pk)
 	}
 	pi.lastTwoPools[1] = &p
 	return nil
@@ -290,6 +370,7 @@ func (pi *PubChainInstance) DeleteExpired(currentHeight uint64) {
 	for _, el := range expiredTx {
 		pi.logger.Warn().Msgf("we delete the expired tx %s", el)
 		pi.pendingInbounds.Delete(el)
+		pi.unpersist(pi.bucket(storage.PendingInboundBucket), el)
 	}
 
 	pi.pendingInboundsBnB.Range(func(key, value interface{}) bool {
@@ -303,6 +384,7 @@ func (pi *PubChainInstance) DeleteExpired(currentHeight uint64) {
 	for _, el := range expiredTxBnb {
 		pi.logger.Warn().Msgf("we delete the expired tx %s in inbound bnb", el)
 		pi.pendingInboundsBnB.Delete(el)
+		pi.unpersist(pi.bucket(storage.PendingInboundBnBBucket), el)
 	}
 }
 
@@ -323,6 +405,7 @@ func (a *inboundTx) Verify() error {
 
 func (pi *PubChainInstance) AddMoveFundItem(pool *bcommon.PoolInfo, height int64) {
 	pi.moveFundReq.Store(height, pool)
+	pi.persist(pi.bucket(storage.PubMoveFundBucket), strconv.FormatInt(height, 10), pool)
 }
 
 func (pi *PubChainInstance) PopMoveFundItem() (*bcommon.PoolInfo, int64) {
@@ -336,6 +419,7 @@ func (pi *PubChainInstance) PopMoveFundItem() (*bcommon.PoolInfo, int64) {
 	})
 	if min < math.MaxInt64 {
 		item, _ := pi.moveFundReq.LoadAndDelete(min)
+		pi.unpersist(pi.bucket(storage.PubMoveFundBucket), strconv.FormatInt(min, 10))
 		return item.(*bcommon.PoolInfo), min
 	}
 	return nil, 0
@@ -353,27 +437,28 @@ func (pi *PubChainInstance) PopMoveFundItemAfterBlock(currentBlockHeight int64)
 	})
 	if min < math.MaxInt64 && (currentBlockHeight-min > config.MINCHECKBLOCKGAP) {
 		item, _ := pi.moveFundReq.LoadAndDelete(min)
+		pi.unpersist(pi.bucket(storage.PubMoveFundBucket), strconv.FormatInt(min, 10))
 		return item.(*bcommon.PoolInfo), min
 	}
 	return nil, 0
 }
 
-func (pi *PubChainInstance) moveBnb(senderPk string, receiver common.Address, amount *big.Int, nonce uint64, blockHeight int64) (string, error) {
+func (pi *PubChainInstance) moveBnb(senderPk string, sender, receiver common.Address, amount *big.Int, blockHeight int64) (string, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), config.QueryTimeOut)
 	defer cancel()
-	chainID, err := pi.EthClient.NetworkID(ctx)
+	chainID, err := pi.getChainID(ctx)
 	if err != nil {
 		pi.logger.Error().Err(err).Msg("fail to get the chain ID")
 		return "", err
 	}
 
-	gasPrice, err := pi.EthClient.SuggestGasPrice(context.Background())
+	fee, err := pi.suggestFee(ctx, blockHeight)
 	if err != nil {
 		return "", err
 	}
 
-	gasLimit, err := pi.EthClient.EstimateGas(context.Background(), ethereum.CallMsg{
+	gasLimit, err := pi.getEthClient().EstimateGas(context.Background(), ethereum.CallMsg{
 		To:   &receiver,
 		Data: nil,
 	})
@@ -381,7 +466,8 @@ func (pi *PubChainInstance) moveBnb(senderPk string, receiver common.Address, am
 		return "", err
 	}
 
-	totalBnb := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+	// we keep enough bnb for the highest fee the tx may pay
+	totalBnb := new(big.Int).Mul(fee.maxGasPrice(), new(big.Int).SetUint64(gasLimit))
 
 	totalBnbDec := sdk.NewDecFromBigIntWithPrec(totalBnb, sdk.Precision)
 	totalBnbDec = totalBnbDec.Mul(sdk.MustNewDecFromStr(config.GASFEERATIO))
@@ -398,43 +484,35 @@ func (pi *PubChainInstance) moveBnb(senderPk string, receiver common.Address, am
 	if moveFund.Cmp(dustBnb.BigInt()) != 1 {
 		return "", nil
 	}
-	baseTx := ethTypes.LegacyTx{
-		Nonce:    nonce,
-		GasPrice: gasPrice,
-		Gas:      gasLimit,
-		To:       &receiver,
-		Data:     nil,
-		Value:    moveFund,
+	nonce, err := pi.nonces.Next(ctx, sender, blockHeight)
+	if err != nil {
+		return "", err
 	}
-
-	rawTx := ethTypes.NewTx(&baseTx)
+	rawTx := fee.newTx(chainID, nonce, &receiver, moveFund, gasLimit, nil)
 	signer := ethTypes.LatestSignerForChainID(chainID)
-	msg := signer.Hash(rawTx).Bytes()
-	signature, err := pi.tssSign(msg, senderPk, blockHeight)
-	if err != nil || len(signature) != 65 {
-		return "", errors.New("fail to get the valid signature")
-	}
-	bTx, err := rawTx.WithSignature(signer, signature)
+	bTx, err := pi.signTx(senderPk, signer, sender, rawTx, blockHeight)
 	if err != nil {
+		pi.nonces.Release(sender, nonce)
 		return "", err
 	}
 
-	err = pi.EthClient.SendTransaction(ctx, bTx)
+	// the same tx signed by the other nodes has the same hash, while a different tx with our nonce is a collision
+	// the retired pool is moved by every member as before
+	err = pi.submitTx(bcommon.Submitter{}, senderPk, sender, bTx, blockHeight)
 	if err != nil {
-		if err.Error() == "already known" || err.Error() == "replacement transaction underpriced" {
+		if err.Error() == "already known" {
 			pi.logger.Warn().Msgf("the tx has been submitted by others")
-			return rawTx.Hash().Hex(), nil
-		} else {
-			return "", err
+			return bTx.Hash().Hex(), nil
 		}
+		return "", err
 	}
 
-	return rawTx.Hash().Hex(), nil
+	return bTx.Hash().Hex(), nil
 }
 
-func (pi *PubChainInstance) moveERC20Token(senderPk string, sender, receiver common.Address, balance *big.Int, blockheight int64) (string, error) {
+func (pi *PubChainInstance) moveERC20Token(senderPk string, tokenAddr, sender, receiver common.Address, balance *big.Int, blockheight int64) (string, error) {
 
-	txHash, err := pi.SendToken(senderPk, sender, receiver, balance, blockheight)
+	txHash, err := pi.SendToken(senderPk, tokenAddr, sender, receiver, balance, blockheight)
 	if err != nil {
 		if err.Error() == "already known" {
 			pi.logger.Warn().Msgf("the tx has been submitted by others")
@@ -448,15 +526,29 @@ func (pi *PubChainInstance) moveERC20Token(senderPk string, sender, receiver com
 }
 
 func (pi *PubChainInstance) MoveFunds(previousPool *bcommon.PoolInfo, receiver common.Address, blockHeight int64) (bool, error) {
-	tokenInstance := pi.tokenInstance
-	balance, err := tokenInstance.BalanceOf(&bind.CallOpts{}, previousPool.EthAddress)
-	if err != nil {
-		return false, err
+	// we find the first token that still has the balance in the previous pool
+	var tokenToMove *bcommon.TokenInfo
+	balance := big.NewInt(0)
+	for _, el := range pi.tokens.Tokens() {
+		tokenInstance, err := pi.getTokenInstance(el.Address)
+		if err != nil {
+			return false, err
+		}
+		tokenBalance, err := tokenInstance.BalanceOf(&bind.CallOpts{}, previousPool.EthAddress)
+		if err != nil {
+			return false, err
+		}
+		if tokenBalance.Cmp(big.NewInt(0)) == 1 {
+			tokenToMove = el
+			balance = tokenBalance
+			break
+		}
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), config.QueryTimeOut)
 	defer cancel()
-	balanceBnB, err := pi.EthClient.BalanceAt(ctx, previousPool.EthAddress, nil)
+	balanceBnB, err := pi.getEthClient().BalanceAt(ctx, previousPool.EthAddress, nil)
+	pi.rpcError("eth_getBalance", err)
 	if err != nil {
 		return false, err
 	}
@@ -475,12 +567,13 @@ func (pi *PubChainInstance) MoveFunds(previousPool *bcommon.PoolInfo, receiver c
 
 	var erc20TxHash, bnbTxHash string
 	if balance.Cmp(big.NewInt(0)) == 1 {
-		erc20TxHash, err = pi.moveERC20Token(previousPool.Pk, previousPool.EthAddress, receiver, balance, blockHeight)
+		erc20TxHash, err = pi.moveERC20Token(previousPool.Pk, tokenToMove.Address, previousPool.EthAddress, receiver, balance, blockHeight)
 		//if we fail erc20 token transfer, we should not transfer the bnb otherwise,we do not have enough fee to pay retry
 		if err != nil {
 			return false, errors.New("fail to transfer erc20 token")
 		} else {
-			//next round, we will handle bnb transfer
+			//next round, we will handle the other tokens and the bnb transfer
+			pi.logger.Info().Msgf("we have moved %v %v with tx %v", balance.String(), tokenToMove.Denom, erc20TxHash)
 			return false, nil
 		}
 	} else {
@@ -491,11 +584,7 @@ func (pi *PubChainInstance) MoveFunds(previousPool *bcommon.PoolInfo, receiver c
 
 	if balanceBnB.Cmp(big.NewInt(0)) == 1 {
 		//we move the bnb
-		nonce, err := pi.EthClient.NonceAt(context.Background(), previousPool.EthAddress, nil)
-		if err != nil {
-			return false, err
-		}
-		bnbTxHash, err = pi.moveBnb(previousPool.Pk, receiver, balanceBnB, nonce, blockHeight)
+		bnbTxHash, err = pi.moveBnb(previousPool.Pk, previousPool.EthAddress, receiver, balanceBnB, blockHeight)
 		if err != nil {
 			return false, err
 		}
@@ -510,15 +599,33 @@ func (pi *PubChainInstance) MoveFunds(previousPool *bcommon.PoolInfo, receiver c
 func (pi *PubChainInstance) checkEachTx(h common.Hash) (uint64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), config.QueryTimeOut)
 	defer cancel()
-	receipt, err := pi.EthClient.TransactionReceipt(ctx, h)
+	receipt, err := pi.getEthClient().TransactionReceipt(ctx, h)
+	pi.rpcError("eth_getTransactionReceipt", err)
 	if err != nil {
 		return 0, err
 	}
 	return receipt.Status, nil
 }
 
-//CheckTxStatus check whether the tx is already in the chain
-func (pi *PubChainInstance) CheckTxStatus(hashStr string) error {
+// CheckTxStatus check whether the tx is already in the chain and returns the hash of the mined tx, a tracked tx may be
+// replaced with a higher fee, so we wait until any tx with its nonce is mined or the context is done
+func (pi *PubChainInstance) CheckTxStatus(ctx context.Context, hashStr string) (string, error) {
+	if item, ok := pi.findBroadcast(common.HexToHash(hashStr)); ok {
+		if item.Capped {
+			return "", ErrFeeCapReached
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-item.result.done:
+		}
+		if item.result.err != nil {
+			pi.logger.Warn().Err(item.result.err).Msgf("the tx %v is not successful", hashStr)
+			return "", item.result.err
+		}
+		pi.logger.Info().Msgf("we have successfully check the tx.")
+		return item.result.hash.Hex(), nil
+	}
 
 	bf := backoff.NewExponentialBackOff()
 	bf.InitialInterval = time.Second
@@ -532,19 +639,21 @@ func (pi *PubChainInstance) CheckTxStatus(hashStr string) error {
 		if err != nil {
 			return err
 		}
+		// the nonce is consumed no matter whether the tx succeeds
+		pi.nonces.Mined(txHash)
 		status = ret
 		return nil
 	}
 
-	err := backoff.Retry(op, bf)
+	err := backoff.Retry(op, backoff.WithContext(bf, ctx))
 	if err != nil {
 		pi.logger.Error().Err(err).Msgf("fail to find the tx %v", hashStr)
-		return err
+		return "", err
 	}
 	if status != 1 {
 		pi.logger.Warn().Msgf("the tx is failed, we need to redo the tx")
-		return errors.New("tx failed")
+		return "", errors.New("tx failed")
 	}
 	pi.logger.Info().Msgf("we have successfully check the tx.")
-	return nil
+	return hashStr, nil
 }
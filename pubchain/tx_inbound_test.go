@@ -24,6 +24,7 @@ import (
 	"gitlab.com/joltify/joltifychain-bridge/config"
 	"gitlab.com/joltify/joltifychain-bridge/generated"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -166,15 +167,21 @@ func TestProcessInBound(t *testing.T) {
 	tokenAddrStr := "0x33875278f7757f6b43abC223EeC4a9D6204186a0"
 	tokenAddr := common.HexToAddress(tokenAddrStr)
 
-	err = pi.ProcessInBoundERC20(tx, tokenAddr, transferTo, testAmount, uint64(10))
+	txID := tx.Hash().Hex()[2:]
+	pi.tokens = newTestTokens(transferTo)
+	err = pi.ProcessInBoundERC20(tx, txID, tokenAddr, addr, transferTo, testAmount, uint64(10))
 	require.NotNil(t, err)
 	require.EqualError(t, err, "incorrect top up token")
 
-	pi.tokenAddr = tokenAddrStr
-	err = pi.ProcessInBoundERC20(tx, tokenAddr, transferTo, testAmount, uint64(10))
+	// the token moved by the tx from another account cannot be credited as we do not know its joltify address
+	err = pi.ProcessInBoundERC20(tx, txID, tokenAddr, transferTo, transferTo, testAmount, uint64(10))
+	require.Error(t, err)
+
+	pi.tokens = newTestTokens(tokenAddr)
+	err = pi.ProcessInBoundERC20(tx, txID, tokenAddr, addr, transferTo, testAmount, uint64(10))
 	require.Nil(t, err)
 
-	err = pi.ProcessInBoundERC20(tx, tokenAddr, transferTo, testAmount, uint64(10))
+	err = pi.ProcessInBoundERC20(tx, txID, tokenAddr, addr, transferTo, testAmount, uint64(10))
 	fmt.Printf(">>>>>>%v\n", err)
 	require.EqualError(t, err, "tx existed")
 }
@@ -429,30 +436,23 @@ func TestProcessEachBlock(t *testing.T) {
 }
 
 func TestProcessEachBlockErc20(t *testing.T) {
-	accs, err := generateRandomPrivKey(3)
+	misc.SetupBech32Prefix()
+	accs, err := generateRandomPrivKey(4)
 	assert.Nil(t, err)
 	tAbi, err := abi.JSON(strings.NewReader(generated.TokenMetaData.ABI))
 	assert.Nil(t, err)
-	pi := PubChainInstance{
-		lastTwoPools:       make([]*common2.PoolInfo, 2),
-		poolLocker:         &sync.RWMutex{},
-		pendingInbounds:    &sync.Map{},
-		pendingInboundsBnB: &sync.Map{},
-		tokenAbi:           &tAbi,
-		InboundReqChan:     make(chan *InboundReq, 1),
-		tokenAddr:          accs[1].commAddr.String(),
-	}
-
-	poolInfo := vaulttypes.PoolInfo{
-		BlockHeight: "100",
-		CreatePool: &vaulttypes.PoolProposal{
-			PoolPubKey: accs[0].pk,
-			PoolAddr:   accs[0].joltAddr,
-		},
-	}
 
-	err = pi.UpdatePool(&poolInfo)
-	assert.Nil(t, err)
+	fake, client := newFakeEthClient(t, 1000)
+	pi := newTestInstance(nil)
+	pi.EthClient = client
+	pi.tokenAbi = &tAbi
+	// accs[1] is the token with 18 decimals and the 6 decimals token is at accs[2]
+	pi.tokens, err = common2.NewTokenRegistry(0, []config.TokenConfig{
+		{Address: accs[1].commAddr.Hex(), Denom: config.InBoundDenom, Decimals: 18},
+		{Address: accs[2].commAddr.Hex(), Denom: "JUSDT", Decimals: 6},
+	})
+	require.NoError(t, err)
+	unknownToken := common.BytesToAddress([]byte("unknown token"))
 
 	header := &ethTypes.Header{
 		Difficulty: math.BigPow(11, 11),
@@ -463,26 +463,57 @@ func TestProcessEachBlockErc20(t *testing.T) {
 		Extra:      []byte("coolest block on pub_chain"),
 	}
 
-	method, ok := tAbi.Methods["transfer"]
-	assert.True(t, ok)
-	dataRaw, err := method.Inputs.Pack(accs[2].commAddr, big.NewInt(10))
+	sk, err := crypto.ToECDSA(accs[3].sk.Bytes())
+	assert.Nil(t, err)
+	signer := ethTypes.NewEIP155Signer(big.NewInt(18))
+	// the user calls the token contract directly
+	depositTx, err := ethTypes.SignTx(ethTypes.NewTransaction(0, accs[1].commAddr, new(big.Int), 0, new(big.Int), []byte("transfer")), signer, sk)
+	assert.Nil(t, err)
+	// the user calls a multisend contract which transfers the token of the user to the pool twice
+	multiSendTx, err := ethTypes.SignTx(ethTypes.NewTransaction(1, accs[2].commAddr, new(big.Int), 0, new(big.Int), []byte("multisend")), signer, sk)
+	assert.Nil(t, err)
+	otherTx, err := ethTypes.SignTx(ethTypes.NewTransaction(2, accs[1].commAddr, new(big.Int), 0, new(big.Int), []byte("transfer")), signer, sk)
+	assert.Nil(t, err)
+	usdtTx, err := ethTypes.SignTx(ethTypes.NewTransaction(3, accs[2].commAddr, new(big.Int), 0, new(big.Int), []byte("transfer")), signer, sk)
+	assert.Nil(t, err)
+	unknownTx, err := ethTypes.SignTx(ethTypes.NewTransaction(4, unknownToken, new(big.Int), 0, new(big.Int), []byte("transfer")), signer, sk)
+	assert.Nil(t, err)
+	tBlock := ethTypes.NewBlock(header, []*ethTypes.Transaction{depositTx, multiSendTx, otherTx, usdtTx, unknownTx}, nil, nil, newHasher())
+
+	tokenLog := func(token common.Address, tx *ethTypes.Transaction, from, to common.Address, amount int64) ethTypes.Log {
+		return ethTypes.Log{
+			Address:     token,
+			Topics:      []common.Hash{tAbi.Events["Transfer"].ID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:        common.LeftPadBytes(big.NewInt(amount).Bytes(), 32),
+			BlockNumber: tBlock.NumberU64(),
+			TxHash:      tx.Hash(),
+			BlockHash:   tBlock.Hash(),
+		}
+	}
+	transferLog := func(tx *ethTypes.Transaction, from, to common.Address, amount int64) ethTypes.Log {
+		return tokenLog(accs[1].commAddr, tx, from, to, amount)
+	}
+	staleLog := transferLog(otherTx, accs[3].commAddr, accs[0].commAddr, 10)
+	staleLog.BlockHash = common.BytesToHash([]byte("stale block"))
+	fake.logs = []ethTypes.Log{
+		transferLog(depositTx, accs[3].commAddr, accs[0].commAddr, 10),
+		transferLog(multiSendTx, accs[3].commAddr, accs[0].commAddr, 5),
+		transferLog(multiSendTx, accs[3].commAddr, accs[2].commAddr, 100),
+		transferLog(multiSendTx, accs[3].commAddr, accs[0].commAddr, 7),
+		// the token of the contract itself is not credited to the user who calls it, it is refunded to the contract
+		transferLog(multiSendTx, accs[2].commAddr, accs[0].commAddr, 9),
+		transferLog(otherTx, accs[3].commAddr, accs[2].commAddr, 10),
+		staleLog,
+		tokenLog(accs[2].commAddr, usdtTx, accs[3].commAddr, accs[0].commAddr, 3),
+		tokenLog(unknownToken, unknownTx, accs[3].commAddr, accs[0].commAddr, 3),
+	}
+	for i := range fake.logs {
+		fake.logs[i].Index = uint(i)
+	}
+
+	// we have not got the pool address, so we ignore all the transfers
+	err = pi.processTransferEvents(tBlock)
 	assert.Nil(t, err)
-	// we need to put 4 leading 0 to match the format in test
-	data := append([]byte("0000"), dataRaw...)
-
-	Eip2718Tx := ethTypes.NewTx(&ethTypes.AccessListTx{
-		ChainID:  big.NewInt(1),
-		Nonce:    3,
-		To:       &accs[2].commAddr,
-		Value:    big.NewInt(10),
-		Gas:      25000,
-		GasPrice: big.NewInt(1),
-		Data:     data,
-	})
-
-	// since token addr is not set, so the system should not put this tx in top-up queue
-	tBlock := ethTypes.NewBlock(header, []*ethTypes.Transaction{Eip2718Tx}, nil, nil, newHasher())
-	pi.processEachBlock(tBlock, 10)
 	counter := 0
 	pi.pendingInbounds.Range(func(key, value interface{}) bool {
 		counter += 1
@@ -490,63 +521,56 @@ func TestProcessEachBlockErc20(t *testing.T) {
 	})
 	assert.Equal(t, counter, 0)
 
-	// though to the token addr but not to the pool, so we ignore this tx
-	Eip2718TxNotPool := ethTypes.NewTx(&ethTypes.AccessListTx{
-		ChainID:  big.NewInt(1),
-		Nonce:    3,
-		To:       &accs[1].commAddr,
-		Value:    big.NewInt(10),
-		Gas:      25000,
-		GasPrice: big.NewInt(1),
-		Data:     data,
-	})
-	tBlock = ethTypes.NewBlock(header, []*ethTypes.Transaction{Eip2718TxNotPool}, nil, nil, newHasher())
-	pi.processEachBlock(tBlock, 10)
-	counter = 0
-	pi.pendingInbounds.Range(func(key, value interface{}) bool {
-		counter += 1
-		return true
-	})
-	assert.Equal(t, counter, 0)
-
-	dataRaw, err = method.Inputs.Pack(accs[0].commAddr, big.NewInt(10))
+	poolInfo := vaulttypes.PoolInfo{
+		BlockHeight: "100",
+		CreatePool: &vaulttypes.PoolProposal{
+			PoolPubKey: accs[0].pk,
+			PoolAddr:   accs[0].joltAddr,
+		},
+	}
+	err = pi.UpdatePool(&poolInfo)
 	assert.Nil(t, err)
 
-	dataToSign := []byte("hello")
-	hash := crypto.Keccak256Hash(dataToSign)
-	sk, err := crypto.ToECDSA(accs[0].sk.Bytes())
+	err = pi.processTransferEvents(tBlock)
 	assert.Nil(t, err)
-	signature, err := crypto.Sign(hash.Bytes(), sk)
-	assert.Nil(t, err)
-	r := signature[:32]
-	s := signature[32:64]
-	v := signature[64:65]
-
-	// we need to put 4 leading 0 to match the format in test
-	data = append([]byte("0000"), dataRaw...)
-	// though to the token addr but not to the pool, so we ignore this tx
-	Eip2718TxGoodPass := ethTypes.NewTx(&ethTypes.AccessListTx{
-		ChainID:  big.NewInt(1),
-		Nonce:    3,
-		To:       &accs[1].commAddr,
-		Value:    big.NewInt(10),
-		Gas:      25000,
-		GasPrice: big.NewInt(1),
-		Data:     data,
-		R:        new(big.Int).SetBytes(r),
-		S:        new(big.Int).SetBytes(s),
-		V:        new(big.Int).SetBytes(v),
-	})
-
-	tBlock = ethTypes.NewBlock(header, []*ethTypes.Transaction{Eip2718TxGoodPass}, nil, nil, newHasher())
-	pi.processEachBlock(tBlock, 10)
-
 	counter = 0
 	pi.pendingInbounds.Range(func(key, value interface{}) bool {
 		counter += 1
 		return true
 	})
-	assert.Equal(t, counter, 1)
+	assert.Equal(t, counter, 4)
+
+	ret, ok := pi.pendingInbounds.Load(depositTx.Hash().Hex()[2:])
+	require.True(t, ok)
+	require.Equal(t, "10", ret.(*inboundTx).token.Amount.String())
+	require.True(t, ret.(*inboundTx).address.Equals(accs[3].joltAddr))
+	// each deposit of the multisend tx is identified by its log index
+	_, ok = pi.pendingInbounds.Load(multiSendTx.Hash().Hex()[2:])
+	require.False(t, ok)
+	ret, ok = pi.pendingInbounds.Load(depositID(multiSendTx.Hash(), 1, true))
+	require.True(t, ok)
+	require.Equal(t, "5", ret.(*inboundTx).token.Amount.String())
+	require.True(t, ret.(*inboundTx).address.Equals(accs[3].joltAddr))
+	ret, ok = pi.pendingInbounds.Load(depositID(multiSendTx.Hash(), 3, true))
+	require.True(t, ok)
+	require.Equal(t, "7", ret.(*inboundTx).token.Amount.String())
+	require.Equal(t, multiSendTx.Hash().Hex()[2:]+"00000003", depositID(multiSendTx.Hash(), 3, true))
+	// the 6 decimals token is scaled to 18 decimals on joltify
+	ret, ok = pi.pendingInbounds.Load(usdtTx.Hash().Hex()[2:])
+	require.True(t, ok)
+	require.Equal(t, "JUSDT", ret.(*inboundTx).token.Denom)
+	require.Equal(t, "3000000000000", ret.(*inboundTx).token.Amount.String())
+
+	// the deposit of the contract is paid back to it from the pool
+	require.Equal(t, 1, pi.RefundSize())
+	refunds := pi.PopRefunds()
+	require.Len(t, refunds, 1)
+	require.Equal(t, depositID(multiSendTx.Hash(), 4, true), refunds[0].TxID)
+	require.Equal(t, accs[2].commAddr, refunds[0].To)
+	require.Equal(t, accs[0].commAddr, refunds[0].Pool)
+	require.Equal(t, "9"+config.InBoundDenom, refunds[0].Coin.String())
+	require.Equal(t, tBlock.Number().Int64(), refunds[0].Height)
+	require.Equal(t, 0, pi.RefundSize())
 }
 
 func TestDeleteExpire(t *testing.T) {
@@ -737,7 +761,7 @@ func TestProcessBlockFeeAhead(t *testing.T) {
 		poolLocker:         &sync.RWMutex{},
 		pendingInbounds:    &sync.Map{},
 		pendingInboundsBnB: &sync.Map{},
-		tokenAddr:          accs[0].commAddr.String(),
+		tokens:             newTestTokens(accs[0].commAddr),
 		InboundReqChan:     make(chan *InboundReq, 1),
 	}
 
@@ -791,6 +815,37 @@ func TestProcessBlockFeeAhead(t *testing.T) {
 	assert.Equal(t, 0, counter2)
 }
 
+func TestInboundTransfers(t *testing.T) {
+	accs, err := generateRandomPrivKey(3)
+	require.NoError(t, err)
+
+	pi := newTestInstance(nil)
+	pi.tokens = newTestTokens(accs[0].commAddr)
+	pi.Transfers = monitor.NewTransferTracker(nil, 0)
+
+	// the deposit waits for its fee
+	err = pi.processInboundTx(hex.EncodeToString([]byte("test1")), 10, accs[1].joltAddr, accs[2].commAddr, big.NewInt(11), accs[0].commAddr)
+	require.NoError(t, err)
+	record, found, err := pi.Transfers.Get("0x" + hex.EncodeToString([]byte("test1")))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, monitor.Inbound, record.Direction)
+	require.Equal(t, monitor.TransferObserved, record.State)
+
+	// the fee arrives ahead of the deposit
+	bnbTx := inboundTxBnb{11, hex.EncodeToString([]byte("test2")), sdk.NewCoin(config.InBoundDenomFee, sdk.NewIntFromUint64(18))}
+	pi.pendingInboundsBnB.Store(hex.EncodeToString([]byte("test2")), &bnbTx)
+	err = pi.processInboundTx(hex.EncodeToString([]byte("test2")), 10, accs[1].joltAddr, accs[2].commAddr, big.NewInt(11), accs[0].commAddr)
+	require.NoError(t, err)
+	item := <-pi.InboundReqChan
+	record, found, err = pi.Transfers.Get(item.SourceTx())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, monitor.TransferQueued, record.State)
+	require.Len(t, record.History, 3)
+	require.Equal(t, monitor.TransferFeeMatched, record.History[1].State)
+}
+
 func TestAccountVerify(t *testing.T) {
 	misc.SetupBech32Prefix()
 	type fields struct {
@@ -0,0 +1,118 @@
+package pubchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+)
+
+// policyKey is the observation of the inbound checked before we sign its mint
+func (i *InboundReq) policyKey() string {
+	return tssclient.MintKey(i.Hash().Hex(), i.address, i.coin)
+}
+
+// observeInbound records the inbound we have seen on the public chain ourselves
+func (pi *PubChainInstance) observeInbound(item *InboundReq) {
+	pi.SignPolicy.Observe(item.policyKey(), item.Hash().Hex())
+}
+
+// ForgetInbound drops the observation of the minted inbound
+func (pi *PubChainInstance) ForgetInbound(item *InboundReq) {
+	pi.SignPolicy.Forget(item.policyKey(), item.Hash().Hex())
+	pi.FinishInflight(item)
+}
+
+// ForgetRetiredPool drops the observation of the retired pool once its fund has moved
+func (pi *PubChainInstance) ForgetRetiredPool(pool *bcommon.PoolInfo) {
+	pi.SignPolicy.Forget(tssclient.RetiredPoolKey(pool.EthAddress.Hex()), pool.Pk)
+}
+
+// unpackCall decodes the arguments of the contract call in the tx data
+func unpackCall(contractAbi *abi.ABI, data []byte) (string, []interface{}, error) {
+	if len(data) < 4 {
+		return "", nil, errors.New("invalid call data")
+	}
+	method, err := contractAbi.MethodById(data[:4])
+	if err != nil {
+		return "", nil, err
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return "", nil, err
+	}
+	return method.Name, args, nil
+}
+
+// txRequests rebuilds the requests the tx pays from the tx itself, the tx may only pay the observed outbounds, move
+// the fund of a retired pool to the current pool or approve the multisend contract to spend the total of a batch
+func (pi *PubChainInstance) txRequests(sender common.Address, tx *ethTypes.Transaction) ([]string, error) {
+	if tx.To() == nil {
+		return nil, errors.New("the tx creates a contract")
+	}
+	to := *tx.To()
+	current := pi.GetPool()[1]
+	moveFund := func(receiver common.Address) ([]string, error) {
+		if current == nil || receiver != current.EthAddress {
+			return nil, fmt.Errorf("%v is not the current pool", receiver.Hex())
+		}
+		return []string{tssclient.RetiredPoolKey(sender.Hex())}, nil
+	}
+
+	// the native coin only leaves the pool when the retired pool moves its fund
+	if len(tx.Data()) == 0 {
+		return moveFund(to)
+	}
+	if tx.Value().Sign() != 0 {
+		return nil, errors.New("the contract call carries the native coin")
+	}
+
+	if pi.multisendAddr != (common.Address{}) && to == pi.multisendAddr {
+		name, args, err := unpackCall(pi.multisendAbi, tx.Data())
+		if err != nil {
+			return nil, err
+		}
+		if name != "disperseTokenSimple" {
+			return nil, fmt.Errorf("unexpected multisend call %v", name)
+		}
+		token := args[0].(common.Address)
+		recipients := args[1].([]common.Address)
+		values := args[2].([]*big.Int)
+		if _, ok := pi.tokens.ByAddress(token); !ok || len(recipients) != len(values) {
+			return nil, errors.New("invalid multisend call")
+		}
+		keys := make([]string, len(recipients))
+		for i, el := range recipients {
+			keys[i] = tssclient.PayoutKey(pi.chainID, sender, el, token, values[i])
+		}
+		return keys, nil
+	}
+
+	if _, ok := pi.tokens.ByAddress(to); !ok {
+		return nil, fmt.Errorf("unknown contract %v", to.Hex())
+	}
+	name, args, err := unpackCall(pi.tokenAbi, tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "transfer":
+		receiver := args[0].(common.Address)
+		if current != nil && receiver == current.EthAddress && sender != current.EthAddress {
+			return moveFund(receiver)
+		}
+		return []string{tssclient.PayoutKey(pi.chainID, sender, receiver, to, args[1].(*big.Int))}, nil
+	case "approve":
+		if pi.multisendAddr == (common.Address{}) || args[0].(common.Address) != pi.multisendAddr {
+			return nil, fmt.Errorf("unexpected spender %v", args[0].(common.Address).Hex())
+		}
+		return []string{tssclient.ApprovalKey(pi.chainID, sender, to, args[1].(*big.Int))}, nil
+	default:
+		return nil, fmt.Errorf("unexpected token call %v", name)
+	}
+}
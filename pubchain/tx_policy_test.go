@@ -0,0 +1,85 @@
+package pubchain
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	common2 "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
+	"gitlab.com/joltify/joltifychain-bridge/generated"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
+)
+
+func TestTxRequests(t *testing.T) {
+	tokenAddr := common.HexToAddress("0xeB42ff4cA651c91EB248f8923358b6144c6B4b79")
+	retired, current := common.Address{1}, common.Address{2}
+	receiver := common.Address{3}
+	pi := newTestInstance(nil)
+	pi.tokens = newTestTokens(tokenAddr)
+	pi.multisendAddr = common.Address{4}
+	pi.lastTwoPools[1] = &common2.PoolInfo{EthAddress: current}
+	tAbi, err := abi.JSON(strings.NewReader(generated.TokenMetaData.ABI))
+	require.NoError(t, err)
+	mAbi, err := abi.JSON(strings.NewReader(generated.MultisendMetaData.ABI))
+	require.NoError(t, err)
+	pi.tokenAbi, pi.multisendAbi = &tAbi, &mAbi
+
+	fee := txFee{gasPrice: big.NewInt(1)}
+	call := func(to common.Address, value *big.Int, contractAbi abi.ABI, method string, args ...interface{}) *ethTypes.Transaction {
+		var data []byte
+		if method != "" {
+			data, err = contractAbi.Pack(method, args...)
+			require.NoError(t, err)
+		}
+		return fee.newTx(big.NewInt(56), 0, &to, value, 21000, data)
+	}
+	payout := tssclient.PayoutKey(0, current, receiver, tokenAddr, big.NewInt(5))
+
+	keys, err := pi.txRequests(current, call(tokenAddr, big.NewInt(0), tAbi, "transfer", receiver, big.NewInt(5)))
+	require.NoError(t, err)
+	require.Equal(t, []string{payout}, keys)
+
+	keys, err = pi.txRequests(current, call(pi.multisendAddr, big.NewInt(0), mAbi, "disperseTokenSimple", tokenAddr, []common.Address{receiver, receiver}, []*big.Int{big.NewInt(5), big.NewInt(5)}))
+	require.NoError(t, err)
+	require.Equal(t, []string{payout, payout}, keys)
+
+	// the fund of the retired pool only moves to the current pool
+	keys, err = pi.txRequests(retired, call(tokenAddr, big.NewInt(0), tAbi, "transfer", current, big.NewInt(5)))
+	require.NoError(t, err)
+	require.Equal(t, []string{tssclient.RetiredPoolKey(retired.Hex())}, keys)
+	keys, err = pi.txRequests(retired, call(current, big.NewInt(5), tAbi, ""))
+	require.NoError(t, err)
+	require.Equal(t, []string{tssclient.RetiredPoolKey(retired.Hex())}, keys)
+	_, err = pi.txRequests(retired, call(receiver, big.NewInt(5), tAbi, ""))
+	require.Error(t, err)
+
+	keys, err = pi.txRequests(current, call(tokenAddr, big.NewInt(0), tAbi, "approve", pi.multisendAddr, big.NewInt(5)))
+	require.NoError(t, err)
+	require.Equal(t, []string{tssclient.ApprovalKey(pi.chainID, current, tokenAddr, big.NewInt(5))}, keys)
+
+	// any other call is refused
+	_, err = pi.txRequests(current, call(tokenAddr, big.NewInt(0), tAbi, "approve", receiver, big.NewInt(5)))
+	require.Error(t, err)
+	_, err = pi.txRequests(current, call(tokenAddr, big.NewInt(0), tAbi, "transferFrom", retired, receiver, big.NewInt(5)))
+	require.Error(t, err)
+	_, err = pi.txRequests(current, call(receiver, big.NewInt(0), tAbi, "transfer", receiver, big.NewInt(5)))
+	require.Error(t, err)
+	_, err = pi.txRequests(current, call(tokenAddr, big.NewInt(1), tAbi, "transfer", receiver, big.NewInt(5)))
+	require.Error(t, err)
+
+	// the inbound is observed with the mint it asks for
+	pi.SignPolicy = tssclient.NewPolicy()
+	item := NewAccountInboundReq(sdk.AccAddress{1}, current, sdk.NewCoin(config.InBoundDenom, sdk.NewInt(1)), []byte{1}, 10)
+	mint := tssclient.MintKey(item.Hash().Hex(), sdk.AccAddress{1}, sdk.NewCoin(config.InBoundDenom, sdk.NewInt(1)))
+	require.Error(t, pi.SignPolicy.Expect("pk", []byte("msg"), mint))
+	pi.observeInbound(&item)
+	require.NoError(t, pi.SignPolicy.Expect("pk", []byte("msg"), mint))
+	pi.ForgetInbound(&item)
+	require.Error(t, pi.SignPolicy.Expect("pk", []byte("msg"), mint))
+}
@@ -7,34 +7,83 @@ import (
 	"fmt"
 	"html"
 	"math/big"
-	"sync"
+	"time"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	common3 "github.com/joltify-finance/tss/common"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 )
 
 // SendToken sends the token to the public chain
-func (pi *PubChainInstance) SendToken(signerPk string, sender, receiver common.Address, amount *big.Int, blockHeight int64) (common.Hash, error) {
-	tokenInstance := pi.tokenInstance
-	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
-	defer cancel()
-	chainID, err := pi.EthClient.NetworkID(ctx)
+func (pi *PubChainInstance) SendToken(signerPk string, tokenAddr, sender, receiver common.Address, amount *big.Int, blockHeight int64) (common.Hash, error) {
+	return pi.sendToken(signerPk, tokenAddr, sender, receiver, amount, blockHeight, bcommon.Submitter{}, nil)
+}
+
+func (pi *PubChainInstance) sendToken(signerPk string, tokenAddr, sender, receiver common.Address, amount *big.Int, blockHeight int64, submitter bcommon.Submitter, signed func(txHash string)) (common.Hash, error) {
+	tokenInstance, err := pi.getTokenInstance(tokenAddr)
 	if err != nil {
-		pi.logger.Error().Err(err).Msg("fail to get the chain ID")
 		return common.Hash{}, err
 	}
+	return pi.sendContractTx(signerPk, sender, blockHeight, submitter, signed, func(txo *bind.TransactOpts) (*types.Transaction, error) {
+		tx, err := tokenInstance.Transfer(txo, receiver, amount)
+		if err != nil {
+			pi.logger.Error().Err(err).Msgf("fail to send the token to the address %v with amount %v", receiver, amount.String())
+		}
+		return tx, err
+	})
+}
+
+// sendContractTx signs the contract call from the sender with tss and submits it, the pool of the last two is the
+// signer if signerPk is empty. signed is called with the hash of the tx before it is submitted if it is not nil.
+func (pi *PubChainInstance) sendContractTx(signerPk string, sender common.Address, blockHeight int64, submitter bcommon.Submitter, signed func(txHash string), call func(txo *bind.TransactOpts) (*types.Transaction, error)) (common.Hash, error) {
 	if signerPk == "" {
 		lastPool := pi.GetPool()[1]
 		signerPk = lastPool.Pk
 	}
-	txo, err := pi.composeTx(signerPk, sender, chainID, blockHeight)
+	readyTx, err := pi.signContractTx(signerPk, sender, blockHeight, call)
 	if err != nil {
 		return common.Hash{}, err
 	}
+	if signed != nil {
+		signed(readyTx.Hash().Hex())
+	}
+	err = pi.submitTx(submitter, signerPk, sender, readyTx, blockHeight)
+	return readyTx.Hash(), err
+}
+
+// signContractTx signs the contract call from the sender with tss without broadcasting it
+func (pi *PubChainInstance) signContractTx(signerPk string, sender common.Address, blockHeight int64, call func(txo *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+	defer cancel()
+	chainID, err := pi.getChainID(ctx)
+	if err != nil {
+		pi.logger.Error().Err(err).Msg("fail to get the chain ID")
+		return nil, err
+	}
+	txo, err := pi.composeTx(signerPk, sender, chainID, blockHeight)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := pi.suggestFee(ctx, blockHeight)
+	if err != nil {
+		pi.logger.Error().Err(err).Msg("fail to get the gas fee")
+		return nil, err
+	}
+	fee.apply(txo)
+	// we pick the nonce from the chain state at the keysign height, so all the signers build the same tx
+	nonce, err := pi.nonces.Next(ctx, sender, blockHeight)
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to get the nonce of %v", sender.Hex())
+		return nil, err
+	}
+	txo.Nonce = new(big.Int).SetUint64(nonce)
 
 	//data, err := pi.tokenAbi.Pack("transfer", receiver, amount)
 	//if err != nil {
@@ -59,24 +108,112 @@ func (pi *PubChainInstance) SendToken(signerPk string, sender, receiver common.A
 
 	//txo.GasLimit = gasLimitDec.BigInt().Uint64()
 	txo.NoSend = true
-	readyTx, err := tokenInstance.Transfer(txo, receiver, amount)
+	tx, err := call(txo)
 	if err != nil {
-		pi.logger.Error().Err(err).Msgf("fail to send the token to the address %v with amount %v", receiver, amount.String())
-		return common.Hash{}, err
+		// the tx is never broadcast, the next tx takes its nonce
+		pi.nonces.Release(sender, nonce)
+		return nil, err
 	}
+	return tx, nil
+}
 
-	ctxSend, cancelSend := context.WithTimeout(context.Background(), chainQueryTimeout)
-	defer cancelSend()
+// submitTx broadcasts the signed tx if we are the leader of the submitters, the other members track the tx as if
+// they had sent it and take over if the chain still does not know it after their delay
+func (pi *PubChainInstance) submitTx(submitter bcommon.Submitter, signerPk string, sender common.Address, tx *types.Transaction, blockHeight int64) error {
+	// we never send a tx that would replace another tx we are watching, its outbound would never be resolved
+	if _, err := pi.checkNonce(sender, tx); err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to send the tx %v", tx.Hash().Hex())
+		return err
+	}
+	if !submitter.IsLeader() {
+		pi.finishSend(signerPk, sender, tx, blockHeight, true, nil)
+		go pi.takeOver(pi.ctx, tx, submitter.Delay())
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+	defer cancel()
+	err := pi.getEthClient().SendTransaction(ctx, tx)
+	pi.rpcError("eth_sendRawTransaction", err)
+	pi.finishSend(signerPk, sender, tx, blockHeight, false, err)
+	if err == nil || err.Error() == "already known" {
+		pi.countBroadcast(sender, tx)
+	}
+	return err
+}
 
-	err = pi.EthClient.SendTransaction(ctxSend, readyTx)
+// countBroadcast counts the outbounds paid by the tx we have broadcast ourselves
+func (pi *PubChainInstance) countBroadcast(sender common.Address, tx *types.Transaction) {
+	if pi.Metric == nil {
+		return
+	}
+	keys, err := pi.txRequests(sender, tx)
+	if err != nil {
+		return
+	}
+	payouts := 0
+	for _, el := range keys {
+		if tssclient.IsPayoutKey(el) {
+			payouts++
+		}
+	}
+	if payouts > 0 {
+		pi.Metric.AddOutbounds(monitor.OutboundBroadcast, payouts)
+	}
+}
 
-	return readyTx.Hash(), err
+// takeOver broadcasts the tx if it is still pending in our tracker while the chain does not know it, we broadcast
+// its replacements from then on
+func (pi *PubChainInstance) takeOver(ctx context.Context, tx *types.Transaction, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	item, ok := pi.findBroadcast(tx.Hash())
+	if !ok {
+		return
+	}
+	// the tx may have been replaced in the meantime
+	latest := item.Tx
+	queryCtx, cancel := context.WithTimeout(ctx, chainQueryTimeout)
+	defer cancel()
+	_, _, err := pi.getEthClient().TransactionByHash(queryCtx, latest.Hash())
+	pi.rpcError("eth_getTransactionByHash", err)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		pi.logger.Error().Err(err).Msgf("fail to query the tx %v", latest.Hash().Hex())
+		return
+	}
+	pi.logger.Warn().Msgf("the tx %v is not broadcast in time, we take over", latest.Hash().Hex())
+	err = pi.getEthClient().SendTransaction(queryCtx, latest)
+	if err != nil && err.Error() != "already known" {
+		pi.logger.Error().Err(err).Msgf("fail to take over the tx %v", latest.Hash().Hex())
+		return
+	}
+	pi.takenOver(item)
+	pi.countBroadcast(item.Sender, latest)
 }
 
-// ProcessOutBound send the money to public chain
-func (pi *PubChainInstance) ProcessOutBound(toAddr, fromAddr common.Address, amount *big.Int, blockHeight int64) (string, error) {
-	pi.logger.Info().Msgf(">>>>from addr %v to addr %v with amount %v\n", fromAddr, toAddr, sdk.NewDecFromBigIntWithPrec(amount, 18))
-	txHash, err := pi.SendToken("", fromAddr, toAddr, amount, blockHeight)
+// ProcessOutBound send the money to public chain, the coin is converted to the token of its denom, only the leader of
+// the submitters broadcasts the tx at once. signed is called with the hash of the signed tx before it is broadcast,
+// so the caller can record it and check it after a crash instead of paying the outbound again.
+func (pi *PubChainInstance) ProcessOutBound(toAddr, fromAddr common.Address, coin sdk.Coin, blockHeight int64, submitter bcommon.Submitter, signed func(txHash string)) (string, error) {
+	tokenInfo, ok := pi.tokens.ByDenom(coin.Denom)
+	if !ok {
+		pi.logger.Error().Msgf("the denom %v is not bridged", coin.Denom)
+		return "", fmt.Errorf("unknown denom %v", coin.Denom)
+	}
+	amount, err := tokenInfo.ToPubChain(coin.Amount)
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to convert the outbound amount")
+		return "", err
+	}
+	pi.logger.Info().Msgf(">>>>from addr %v to addr %v with amount %v %v\n", fromAddr, toAddr, sdk.NewDecFromBigIntWithPrec(amount, int64(tokenInfo.Decimals)), tokenInfo.Denom)
+	txHash, err := pi.sendToken("", tokenInfo.Address, fromAddr, toAddr, amount, blockHeight, submitter, signed)
 	if err != nil {
 		if err.Error() == "already known" {
 			pi.logger.Warn().Msgf("the tx has been submitted by others")
@@ -91,24 +228,6 @@ func (pi *PubChainInstance) ProcessOutBound(toAddr, fromAddr common.Address, amo
 	return txHash.Hex(), nil
 }
 
-// StartSubscription start the subscription of the token
-func (pi *PubChainInstance) StartSubscription(ctx context.Context, wg *sync.WaitGroup) (chan *types.Header, error) {
-	blockEvent := make(chan *types.Header)
-	blockSub, err := pi.EthClient.SubscribeNewHead(ctx, blockEvent)
-	if err != nil {
-		fmt.Printf("fail to subscribe the block event with err %v\n", err)
-		return nil, err
-	}
-
-	go func() {
-		<-ctx.Done()
-		blockSub.Unsubscribe()
-		pi.logger.Info().Msgf("shutdown the public pub_chain subscription channel")
-		wg.Done()
-	}()
-	return blockEvent, nil
-}
-
 func (pi *PubChainInstance) tssSign(msg []byte, pk string, blockHeight int64) ([]byte, error) {
 
 	encodedMsg := base64.StdEncoding.EncodeToString(msg)
@@ -119,9 +238,9 @@ func (pi *PubChainInstance) tssSign(msg []byte, pk string, blockHeight int64) ([
 	}
 
 	if resp.Status != common3.Success {
-		pi.logger.Error().Err(err).Msg("fail to generate the signature")
-		// todo we need to handle the blame
-		return nil, err
+		// the tss server records the blame, so the peers blamed repeatedly by the pool are left out when we retry
+		pi.logger.Error().Msgf("fail to generate the signature, blame: %v", resp.Blame.String())
+		return nil, errors.New("fail to generate the signature")
 	}
 	if len(resp.Signatures) != 1 {
 		pi.logger.Error().Msgf("we should only have 1 signature")
@@ -135,6 +254,27 @@ func (pi *PubChainInstance) tssSign(msg []byte, pk string, blockHeight int64) ([
 	return signature, nil
 }
 
+// signTx signs the tx with tss once the signing policy accepts the requests rebuilt from the tx
+func (pi *PubChainInstance) signTx(signerPk string, signer types.Signer, sender common.Address, tx *types.Transaction, blockHeight int64) (*types.Transaction, error) {
+	msg := signer.Hash(tx).Bytes()
+	if pi.SignPolicy != nil {
+		keys, err := pi.txRequests(sender, tx)
+		if err != nil {
+			pi.logger.Error().Err(err).Msgf("we refuse to sign the tx from %v", sender.Hex())
+			return nil, err
+		}
+		if err := pi.SignPolicy.Expect(signerPk, msg, keys...); err != nil {
+			return nil, err
+		}
+		defer pi.SignPolicy.Discard(msg)
+	}
+	signature, err := pi.tssSign(msg, signerPk, blockHeight)
+	if err != nil || len(signature) != 65 {
+		return nil, errors.New("fail to sign the tx")
+	}
+	return tx.WithSignature(signer, signature)
+}
+
 func (pi *PubChainInstance) composeTx(signerPk string, sender common.Address, chainID *big.Int, blockHeight int64) (*bind.TransactOpts, error) {
 	if chainID == nil {
 		return nil, bind.ErrNoChainID
@@ -146,12 +286,11 @@ func (pi *PubChainInstance) composeTx(signerPk string, sender common.Address, ch
 			if address != sender {
 				return nil, errors.New("the address is different from the sender")
 			}
-			msg := signer.Hash(tx).Bytes()
-			signature, err := pi.tssSign(msg, signerPk, blockHeight)
-			if err != nil || len(signature) != 65 {
-				return nil, errors.New("fail to sign the tx")
+			// the london signer hashes both types, and the tss signature is converted to the v value of each type
+			if tx.Type() != types.LegacyTxType && tx.Type() != types.DynamicFeeTxType {
+				return nil, fmt.Errorf("unsupported tx type %v", tx.Type())
 			}
-			return tx.WithSignature(signer, signature)
+			return pi.signTx(signerPk, signer, sender, tx, blockHeight)
 		},
 		Context: context.Background(),
 	}, nil
@@ -14,6 +14,7 @@ import (
 	types2 "github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/assert"
 	"gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/config"
 	"gitlab.com/joltify/joltifychain-bridge/misc"
 )
 
@@ -48,7 +49,7 @@ func TestPubChainInstance_composeTx(t *testing.T) {
 		poolLocker:         &sync.RWMutex{},
 		pendingInbounds:    &sync.Map{},
 		pendingInboundsBnB: &sync.Map{},
-		tokenAddr:          accs[0].commAddr.String(),
+		tokens:             newTestTokens(accs[0].commAddr),
 		InboundReqChan:     make(chan *InboundReq, 1),
 		tssServer:          &tssServer,
 	}
@@ -101,7 +102,13 @@ func TestSendToken(t *testing.T) {
 
 	websocketTest := "wss://apis-sj.ankr.com/wss/783303b49f7b4f988a67631cc709c8ce/a08ea9fddcad7113ac6454229b82c598/binance/full/test"
 	tokenAddrTest := "0x0cD80A18df1C5eAd4B5Fb549391d58B06EFfDBC4"
-	pubChain, err := NewChainInstance(websocketTest, tokenAddrTest, &tss)
+	cfg := config.PubChainConfig{
+		WsAddress:        websocketTest,
+		TokenAddress:     tokenAddrTest,
+		OutboundFeeDenom: "JOLT",
+		OutboundFeeMin:   "0.00000000000000001",
+	}
+	pubChain, err := NewChainInstance(cfg, cfg.TokenList(config.InBoundDenom), &tss, nil)
 	assert.Nil(t, err)
 
 	poolInfo := vaulttypes.PoolInfo{
@@ -136,7 +143,7 @@ func TestSendToken(t *testing.T) {
 	wg.Wait()
 
 	// now we test send the token
-	_, err = pubChain.ProcessOutBound(accs[0].commAddr, accs[1].commAddr, big.NewInt(100), int64(10))
+	_, err = pubChain.ProcessOutBound(accs[0].commAddr, accs[1].commAddr, types.NewCoin(config.InBoundDenom, types.NewInt(100)), int64(10), common.Submitter{}, nil)
 	pubChain.tssServer.Stop()
 	assert.EqualError(t, err, "insufficient funds for gas * price + value")
 }
@@ -0,0 +1,327 @@
+package pubchain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+// feeBumpPercent is how much we raise the fee of a stuck tx, the nodes only accept a replacement with at least 10%
+// higher fee
+const feeBumpPercent = 20
+
+// ErrFeeCapReached is reported to the waiters of a stuck tx whose fee cannot be bumped without exceeding the max fee
+// cap, the tx is still watched until a tx with its nonce is mined
+var ErrFeeCapReached = errors.New("the fee of the stuck tx reaches the max fee cap")
+
+// ErrNonceTaken is returned for a tx whose nonce is held by another tx we are watching, the tx is not sent, so the
+// waiters of the tracked tx keep waiting on it
+var ErrNonceTaken = errors.New("the nonce is taken by another tracked tx")
+
+// broadcastResult is the outcome of a broadcast tx, done is closed once the outcome is known, so any number of
+// waiters can read it
+type broadcastResult struct {
+	once sync.Once
+	done chan struct{}
+	// hash is the hash of the mined tx, it may be one of the replacements
+	hash common.Hash
+	err  error
+}
+
+func newBroadcastResult() *broadcastResult {
+	return &broadcastResult{done: make(chan struct{})}
+}
+
+// finish reports the outcome to the waiters, only the first outcome counts
+func (r *broadcastResult) finish(hash common.Hash, err error) {
+	r.once.Do(func() {
+		r.hash, r.err = hash, err
+		close(r.done)
+	})
+}
+
+// broadcastTx is a tx we have broadcast, we watch it until a tx with its nonce is mined
+type broadcastTx struct {
+	SignerPk string                `json:"signer_pk"`
+	Sender   common.Address        `json:"sender"`
+	Tx       *ethTypes.Transaction `json:"tx"`
+	// Hashes are the hashes of all the txs sent with this nonce, any of them may be mined
+	Hashes []common.Hash `json:"hashes"`
+	// BlockHeight is the public chain height the first tx is signed at, the replacements are due every stuckTxBlocks
+	// blocks after it, so all the signers replace the tx at the same height
+	BlockHeight int64 `json:"block_height"`
+	Attempts    int   `json:"attempts"`
+	// Capped is set once the fee cannot be bumped any more
+	Capped bool `json:"capped,omitempty"`
+	// Standby is set if another member broadcasts the tx, we only join the keysign of its replacements until we take
+	// it over
+	Standby bool `json:"standby,omitempty"`
+	result  *broadcastResult
+}
+
+// replaceHeight is the public chain height the next replacement of the tx is signed at
+func (b *broadcastTx) replaceHeight(stuckTxBlocks int64) int64 {
+	return b.BlockHeight + int64(b.Attempts+1)*stuckTxBlocks
+}
+
+func broadcastKey(sender common.Address, nonce uint64) string {
+	return fmt.Sprintf("%v/%020d", sender.Hex(), nonce)
+}
+
+// checkNonce fails if another tx than the given one or its replacements is tracked with its nonce
+func (pi *PubChainInstance) checkNonce(sender common.Address, tx *ethTypes.Transaction) (bool, error) {
+	existed, ok := pi.broadcastTxs.Load(broadcastKey(sender, tx.Nonce()))
+	if !ok {
+		return false, nil
+	}
+	for _, h := range existed.(*broadcastTx).Hashes {
+		if h == tx.Hash() {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("%w: the nonce %v of %v is held by %v", ErrNonceTaken, tx.Nonce(), sender.Hex(), existed.(*broadcastTx).Tx.Hash().Hex())
+}
+
+// trackBroadcast watches the broadcast tx until it is mined, the tx is replaced with a higher fee if it is stuck
+func (pi *PubChainInstance) trackBroadcast(signerPk string, sender common.Address, tx *ethTypes.Transaction, blockHeight int64, standby bool) error {
+	tracked, err := pi.checkNonce(sender, tx)
+	if tracked || err != nil {
+		return err
+	}
+	key := broadcastKey(sender, tx.Nonce())
+	item := &broadcastTx{
+		SignerPk:    signerPk,
+		Sender:      sender,
+		Tx:          tx,
+		Hashes:      []common.Hash{tx.Hash()},
+		BlockHeight: blockHeight,
+		Standby:     standby,
+		result:      newBroadcastResult(),
+	}
+	pi.broadcastTxs.Store(key, item)
+	pi.persist(pi.bucket(storage.PubBroadcastTxBucket), key, item)
+	return nil
+}
+
+// takenOver marks the tx we have broadcast in place of the leader, we broadcast its replacements from then on
+func (pi *PubChainInstance) takenOver(item *broadcastTx) {
+	key := broadcastKey(item.Sender, item.Tx.Nonce())
+	value, ok := pi.broadcastTxs.Load(key)
+	if !ok {
+		return
+	}
+	updated := *value.(*broadcastTx)
+	updated.Standby = false
+	pi.broadcastTxs.Store(key, &updated)
+	pi.persist(pi.bucket(storage.PubBroadcastTxBucket), key, &updated)
+}
+
+// findBroadcast returns the tracked tx that has the given hash
+func (pi *PubChainInstance) findBroadcast(txHash common.Hash) (*broadcastTx, bool) {
+	var found *broadcastTx
+	pi.broadcastTxs.Range(func(_, value interface{}) bool {
+		item := value.(*broadcastTx)
+		for _, h := range item.Hashes {
+			if h == txHash {
+				found = item
+				return false
+			}
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// KnownTx tells whether the tx is tracked by us or known to the chain, pending or mined
+func (pi *PubChainInstance) KnownTx(ctx context.Context, txHash string) (bool, error) {
+	if _, ok := pi.findBroadcast(common.HexToHash(txHash)); ok {
+		return true, nil
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, chainQueryTimeout)
+	defer cancel()
+	_, _, err := pi.getEthClient().TransactionByHash(queryCtx, common.HexToHash(txHash))
+	pi.rpcError("eth_getTransactionByHash", err)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// BroadcastSize returns the number of the broadcast txs that are not mined yet
+func (pi *PubChainInstance) BroadcastSize() int {
+	i := 0
+	pi.broadcastTxs.Range(func(_, _ interface{}) bool {
+		i++
+		return true
+	})
+	return i
+}
+
+// CheckBroadcastTxs checks the broadcast txs on the new block, the txs that are still pending stuckTxBlocks blocks
+// after their keysign height are signed again with the same nonce and a higher fee
+func (pi *PubChainInstance) CheckBroadcastTxs(height int64) {
+	// the keysign of the replacements may take longer than a block, we skip the blocks arriving in the meantime
+	if !atomic.CompareAndSwapInt32(&pi.checkingTxs, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&pi.checkingTxs, 0)
+
+	var keys []string
+	pi.broadcastTxs.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	// the lower nonces go first as they block the others
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := pi.broadcastTxs.Load(key)
+		if !ok {
+			continue
+		}
+		pi.checkBroadcast(key, value.(*broadcastTx), height)
+	}
+}
+
+func (pi *PubChainInstance) checkBroadcast(key string, item *broadcastTx, height int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+	defer cancel()
+	// we read the nonce before the receipts, so a nonce taken without any of our receipts is not a tx mined in between
+	confirmed, err := pi.getEthClient().NonceAt(ctx, item.Sender, nil)
+	pi.rpcError("eth_getTransactionCount", err)
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to get the nonce of %v", item.Sender.Hex())
+		return
+	}
+	for _, h := range item.Hashes {
+		status, err := pi.checkEachTx(h)
+		if err != nil {
+			continue
+		}
+		var result error
+		if status != 1 {
+			result = errors.New("tx failed")
+		}
+		pi.finishBroadcast(key, item, h, result)
+		return
+	}
+	if confirmed > item.Tx.Nonce() {
+		pi.logger.Error().Msgf("the nonce %v of %v is taken by a tx we do not know", item.Tx.Nonce(), item.Sender.Hex())
+		pi.finishBroadcast(key, item, common.Hash{}, errors.New("tx replaced"))
+		return
+	}
+
+	if item.Capped || height < item.replaceHeight(pi.stuckTxBlocks) {
+		return
+	}
+	err = pi.replaceBroadcast(key, item)
+	if errors.Is(err, ErrFeeCapReached) {
+		pi.logger.Error().Err(err).Msgf("the tx %v is stuck and we stop replacing it", item.Tx.Hash().Hex())
+		updated := *item
+		updated.Capped = true
+		pi.broadcastTxs.Store(key, &updated)
+		pi.persist(pi.bucket(storage.PubBroadcastTxBucket), key, &updated)
+		item.result.finish(common.Hash{}, err)
+		return
+	}
+	if err != nil {
+		pi.logger.Error().Err(err).Msgf("fail to replace the stuck tx %v", item.Tx.Hash().Hex())
+	}
+}
+
+// finishBroadcast stops tracking the tx once a tx with its nonce is mined and reports the result to the waiters
+func (pi *PubChainInstance) finishBroadcast(key string, item *broadcastTx, minedHash common.Hash, result error) {
+	for _, h := range item.Hashes {
+		pi.nonces.Mined(h)
+	}
+	pi.broadcastTxs.Delete(key)
+	pi.unpersist(pi.bucket(storage.PubBroadcastTxBucket), key)
+	item.result.finish(minedHash, result)
+}
+
+// replaceBroadcast signs the tx again with the same nonce and a higher fee, the standby member only joins the keysign
+// and leaves the broadcast to the leader
+func (pi *PubChainInstance) replaceBroadcast(key string, item *broadcastTx) error {
+	fee, err := pi.bumpFee(item.Tx)
+	if err != nil {
+		return err
+	}
+	chainID := item.Tx.ChainId()
+	rawTx := fee.newTx(chainID, item.Tx.Nonce(), item.Tx.To(), item.Tx.Value(), item.Tx.Gas(), item.Tx.Data())
+	signer := ethTypes.LatestSignerForChainID(chainID)
+	// the fee is bumped in the same way on all the nodes, so they sign the same replacement at the same height
+	bTx, err := pi.signTx(item.SignerPk, signer, item.Sender, rawTx, item.replaceHeight(pi.stuckTxBlocks))
+	if err != nil {
+		return err
+	}
+
+	if !item.Standby {
+		ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+		defer cancel()
+		err = pi.getEthClient().SendTransaction(ctx, bTx)
+		if err != nil && err.Error() != "already known" {
+			pi.rpcError("eth_sendRawTransaction", err)
+			return err
+		}
+		pi.logger.Warn().Msgf("the tx %v is stuck, we replace it with %v", item.Tx.Hash().Hex(), bTx.Hash().Hex())
+		pi.Metric.AddOutbounds(monitor.OutboundResent, 1)
+	}
+
+	updated := *item
+	updated.Tx = bTx
+	updated.Hashes = append(append([]common.Hash{}, item.Hashes...), bTx.Hash())
+	updated.Attempts++
+	pi.broadcastTxs.Store(key, &updated)
+	pi.persist(pi.bucket(storage.PubBroadcastTxBucket), key, &updated)
+	pi.nonces.Track(item.Sender, bTx.Nonce(), bTx.Hash())
+	return nil
+}
+
+// bumpFee returns the fee of the replacement, it fails if the fee cap would exceed the max fee cap
+func (pi *PubChainInstance) bumpFee(tx *ethTypes.Transaction) (txFee, error) {
+	if tx.Type() != ethTypes.DynamicFeeTxType {
+		return txFee{gasPrice: bumpPrice(tx.GasPrice())}, nil
+	}
+	feeCap := bumpPrice(tx.GasFeeCap())
+	if pi.maxGasFeeCap != 0 && feeCap.Cmp(new(big.Int).SetUint64(pi.maxGasFeeCap)) == 1 {
+		return txFee{}, fmt.Errorf("%w: the bumped fee cap %v exceeds %v", ErrFeeCapReached, feeCap, pi.maxGasFeeCap)
+	}
+	return txFee{gasTipCap: bumpPrice(tx.GasTipCap()), gasFeeCap: feeCap}, nil
+}
+
+func bumpPrice(price *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(100+feeBumpPercent))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(price) != 1 {
+		bumped.Add(price, big.NewInt(1))
+	}
+	return bumped
+}
+
+// restoreBroadcast reloads the broadcast txs from the state store
+func (pi *PubChainInstance) restoreBroadcast() error {
+	return pi.stateStore.Iterate(pi.bucket(storage.PubBroadcastTxBucket), func(key string, value []byte) error {
+		var item broadcastTx
+		if err := json.Unmarshal(value, &item); err != nil {
+			return err
+		}
+		// the tx may be tracked already, its waiters keep waiting on the tracked one
+		if _, ok := pi.broadcastTxs.Load(key); ok {
+			return nil
+		}
+		item.result = newBroadcastResult()
+		pi.broadcastTxs.Store(key, &item)
+		pi.nonces.Track(item.Sender, item.Tx.Nonce(), item.Tx.Hash())
+		return nil
+	})
+}
@@ -0,0 +1,326 @@
+package pubchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	bcommon "gitlab.com/joltify/joltifychain-bridge/common"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+func (f *fakeEthService) SendRawTransaction(input hexutil.Bytes) (common.Hash, error) {
+	tx := new(ethTypes.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sent = append(f.sent, tx)
+	return tx.Hash(), nil
+}
+
+func (f *fakeEthService) GetTransactionReceipt(hash common.Hash) (*ethTypes.Receipt, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.receipts[hash], nil
+}
+
+func (f *fakeEthService) GetTransactionCount(addr common.Address, number rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return hexutil.Uint64(f.nonce), nil
+}
+
+func (f *fakeEthService) GetTransactionByHash(hash common.Hash) (*ethTypes.Transaction, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, el := range f.sent {
+		if el.Hash() == hash {
+			return el, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeEthService) mine(txHash common.Hash, status uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.receipts[txHash] = &ethTypes.Receipt{Status: status, TxHash: txHash, Logs: []*ethTypes.Log{}}
+	f.nonce++
+}
+
+func (f *fakeEthService) sentTxs() []*ethTypes.Transaction {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]*ethTypes.Transaction{}, f.sent...)
+}
+
+// txStatus is the outcome of CheckTxStatus
+type txStatus struct {
+	hash string
+	err  error
+}
+
+func waitStatus(t *testing.T, pi *PubChainInstance, txHash common.Hash) chan txStatus {
+	ret := make(chan txStatus, 1)
+	go func() {
+		hash, err := pi.CheckTxStatus(context.Background(), txHash.Hex())
+		ret <- txStatus{hash, err}
+	}()
+	// the waiter must not return before the tx is mined
+	select {
+	case status := <-ret:
+		t.Fatalf("the status returned early with %v", status.err)
+	case <-time.After(time.Millisecond * 100):
+	}
+	return ret
+}
+
+func TestReplaceStuckTx(t *testing.T) {
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	fake, client := newFakeEthClient(t, 10)
+	fake.receipts = make(map[common.Hash]*ethTypes.Receipt)
+	fake.nonce = 3
+	pi := newTestInstance(store)
+	pi.EthClient = client
+	pi.tssServer = &TssMock{accs[1].sk}
+	pi.stuckTxBlocks = 5
+
+	chainID := big.NewInt(56)
+	sender := accs[1].commAddr
+	txOption, err := pi.composeTx(accs[1].pk, sender, chainID, 100)
+	require.NoError(t, err)
+	fee := txFee{gasPrice: big.NewInt(50)}
+	tx, err := txOption.Signer(sender, fee.newTx(chainID, 3, &accs[0].commAddr, big.NewInt(1), 21000, nil))
+	require.NoError(t, err)
+	pi.finishSend(accs[1].pk, sender, tx, 100, false, nil)
+	ret := waitStatus(t, pi, tx.Hash())
+	// a second waiter learns the same result
+	ret2 := waitStatus(t, pi, tx.Hash())
+
+	// the tx is replaced once it is still pending 5 blocks after its keysign height
+	pi.CheckBroadcastTxs(101)
+	pi.CheckBroadcastTxs(104)
+	require.Len(t, fake.sentTxs(), 0)
+	pi.CheckBroadcastTxs(105)
+	sent := fake.sentTxs()
+	require.Len(t, sent, 1)
+	require.Equal(t, uint64(3), sent[0].Nonce())
+	require.Equal(t, int64(60), sent[0].GasPrice().Int64())
+	signed, err := ethTypes.Sender(ethTypes.LatestSignerForChainID(chainID), sent[0])
+	require.NoError(t, err)
+	require.Equal(t, sender, signed)
+	require.Equal(t, map[uint64]common.Hash{3: sent[0].Hash()}, pi.nonces.InFlight(sender))
+
+	// the replacement is tracked after the restart
+	restored := newTestInstance(store)
+	require.NoError(t, restored.RestoreState())
+	require.Equal(t, 1, restored.BroadcastSize())
+	item, ok := restored.findBroadcast(tx.Hash())
+	require.True(t, ok)
+	require.Equal(t, []common.Hash{tx.Hash(), sent[0].Hash()}, item.Hashes)
+	require.Equal(t, 1, item.Attempts)
+	// the next replacement is due 5 blocks later
+	require.Equal(t, int64(110), item.replaceHeight(pi.stuckTxBlocks))
+	// restoring the state again keeps the tracked tx and its waiters
+	require.NoError(t, pi.restoreBroadcast())
+
+	// the waiters of the first tx learn the hash of the mined replacement
+	fake.mine(sent[0].Hash(), 1)
+	pi.CheckBroadcastTxs(106)
+	for _, el := range []chan txStatus{ret, ret2} {
+		status := <-el
+		require.NoError(t, status.err)
+		require.Equal(t, sent[0].Hash().Hex(), status.hash)
+	}
+	require.Equal(t, 0, pi.BroadcastSize())
+	require.Len(t, pi.nonces.InFlight(sender), 0)
+
+	// the failed tx is reported so the outbound can be resent
+	tx, err = txOption.Signer(sender, fee.newTx(chainID, 4, &accs[0].commAddr, big.NewInt(1), 21000, nil))
+	require.NoError(t, err)
+	pi.finishSend(accs[1].pk, sender, tx, 101, false, nil)
+	ret = waitStatus(t, pi, tx.Hash())
+	fake.mine(tx.Hash(), 0)
+	pi.CheckBroadcastTxs(107)
+	require.EqualError(t, (<-ret).err, "tx failed")
+
+	// the nonce is taken by a tx we do not know
+	tx, err = txOption.Signer(sender, fee.newTx(chainID, 5, &accs[0].commAddr, big.NewInt(1), 21000, nil))
+	require.NoError(t, err)
+	pi.finishSend(accs[1].pk, sender, tx, 102, false, nil)
+	ret = waitStatus(t, pi, tx.Hash())
+	fake.mine(common.Hash{}, 1)
+	pi.CheckBroadcastTxs(108)
+	require.EqualError(t, (<-ret).err, "tx replaced")
+
+	// we stop replacing the tx once its fee reaches the cap and tell the waiters
+	dynamic := txFee{gasTipCap: big.NewInt(2), gasFeeCap: big.NewInt(100)}
+	tx, err = txOption.Signer(sender, dynamic.newTx(chainID, 6, &accs[0].commAddr, big.NewInt(1), 21000, nil))
+	require.NoError(t, err)
+	pi.maxGasFeeCap = 110
+	pi.finishSend(accs[1].pk, sender, tx, 110, false, nil)
+	ret = waitStatus(t, pi, tx.Hash())
+	sentBefore := len(fake.sentTxs())
+	pi.CheckBroadcastTxs(115)
+	require.ErrorIs(t, (<-ret).err, ErrFeeCapReached)
+	pi.CheckBroadcastTxs(120)
+	require.Len(t, fake.sentTxs(), sentBefore)
+	_, err = pi.CheckTxStatus(context.Background(), tx.Hash().Hex())
+	require.ErrorIs(t, err, ErrFeeCapReached)
+	// the capped tx is still watched until it is mined
+	fake.mine(tx.Hash(), 1)
+	pi.CheckBroadcastTxs(121)
+	require.Equal(t, 0, pi.BroadcastSize())
+}
+
+func TestKnownTx(t *testing.T) {
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+	_, client := newFakeEthClient(t, 10)
+	pi := newTestInstance(nil)
+	pi.EthClient = client
+	pi.tssServer = &TssMock{accs[1].sk}
+
+	chainID := big.NewInt(56)
+	sender := accs[1].commAddr
+	txOption, err := pi.composeTx(accs[1].pk, sender, chainID, 100)
+	require.NoError(t, err)
+	fee := txFee{gasPrice: big.NewInt(50)}
+	signTx := func(nonce uint64) *ethTypes.Transaction {
+		tx, err := txOption.Signer(sender, fee.newTx(chainID, nonce, &accs[0].commAddr, big.NewInt(1), 21000, nil))
+		require.NoError(t, err)
+		return tx
+	}
+
+	// the tx signed before a crash is unknown to us and to the chain
+	tx := signTx(0)
+	known, err := pi.KnownTx(context.Background(), tx.Hash().Hex())
+	require.NoError(t, err)
+	require.False(t, known)
+
+	// the chain knows the broadcast tx
+	require.NoError(t, pi.submitTx(bcommon.Submitter{}, accs[1].pk, sender, tx, 100))
+	known, err = pi.KnownTx(context.Background(), tx.Hash().Hex())
+	require.NoError(t, err)
+	require.True(t, known)
+
+	// the member waiting for the leader tracks the tx the chain does not know yet
+	tx = signTx(1)
+	require.NoError(t, pi.submitTx(bcommon.Submitter{Rank: 1, Timeout: time.Hour}, accs[1].pk, sender, tx, 101))
+	known, err = pi.KnownTx(context.Background(), tx.Hash().Hex())
+	require.NoError(t, err)
+	require.True(t, known)
+}
+
+func TestBumpFee(t *testing.T) {
+	pi := newTestInstance(nil)
+	chainID := big.NewInt(56)
+
+	fee, err := pi.bumpFee(txFee{gasPrice: big.NewInt(3)}.newTx(chainID, 0, nil, nil, 0, nil))
+	require.NoError(t, err)
+	require.Equal(t, int64(4), fee.gasPrice.Int64())
+
+	dynamic := txFee{gasTipCap: big.NewInt(2), gasFeeCap: big.NewInt(100)}.newTx(chainID, 0, nil, nil, 0, nil)
+	fee, err = pi.bumpFee(dynamic)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), fee.gasTipCap.Int64())
+	require.Equal(t, int64(120), fee.gasFeeCap.Int64())
+
+	// we do not pay more than the max fee cap
+	pi.maxGasFeeCap = 110
+	_, err = pi.bumpFee(dynamic)
+	require.ErrorIs(t, err, ErrFeeCapReached)
+}
+
+func TestSubmitTx(t *testing.T) {
+	accs, err := generateRandomPrivKey(2)
+	require.NoError(t, err)
+	fake, client := newFakeEthClient(t, 10)
+	pi := newTestInstance(nil)
+	pi.EthClient = client
+	pi.tssServer = &TssMock{accs[1].sk}
+
+	chainID := big.NewInt(56)
+	sender := accs[1].commAddr
+	txOption, err := pi.composeTx(accs[1].pk, sender, chainID, 100)
+	require.NoError(t, err)
+	fee := txFee{gasPrice: big.NewInt(50)}
+	signTx := func(nonce uint64) *ethTypes.Transaction {
+		tx, err := txOption.Signer(sender, fee.newTx(chainID, nonce, &accs[0].commAddr, big.NewInt(1), 21000, nil))
+		require.NoError(t, err)
+		return tx
+	}
+
+	// the leader broadcasts at once
+	leaderTx := signTx(0)
+	require.NoError(t, pi.submitTx(bcommon.Submitter{}, accs[1].pk, sender, leaderTx, 100))
+	require.Len(t, fake.sentTxs(), 1)
+
+	// the others wait and find the tx of the leader
+	follower := bcommon.Submitter{Rank: 1, Timeout: time.Millisecond * 50}
+	require.NoError(t, pi.submitTx(follower, accs[1].pk, sender, leaderTx, 100))
+	time.Sleep(time.Millisecond * 200)
+	require.Len(t, fake.sentTxs(), 1)
+
+	// the tx the leader failed to broadcast is taken over after the delay, while it is tracked at once
+	tx := signTx(1)
+	require.NoError(t, pi.submitTx(follower, accs[1].pk, sender, tx, 101))
+	item, ok := pi.findBroadcast(tx.Hash())
+	require.True(t, ok)
+	require.True(t, item.Standby)
+	require.Len(t, fake.sentTxs(), 1)
+	require.Eventually(t, func() bool {
+		return len(fake.sentTxs()) == 2
+	}, time.Second, time.Millisecond*10)
+	require.Equal(t, tx.Hash(), fake.sentTxs()[1].Hash())
+	require.Eventually(t, func() bool {
+		item, _ := pi.findBroadcast(tx.Hash())
+		return !item.Standby
+	}, time.Second, time.Millisecond*10)
+
+	// the member waiting for the leader only signs the replacement, we do not take over once we are stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	pi.ctx = ctx
+	pi.stuckTxBlocks = 5
+	fake.receipts = make(map[common.Hash]*ethTypes.Receipt)
+	fake.mine(leaderTx.Hash(), 1)
+	fake.mine(tx.Hash(), 1)
+	tx = signTx(2)
+	require.NoError(t, pi.submitTx(bcommon.Submitter{Rank: 1, Timeout: time.Hour}, accs[1].pk, sender, tx, 102))
+	pi.CheckBroadcastTxs(107)
+	item, ok = pi.findBroadcast(tx.Hash())
+	require.True(t, ok)
+	require.Equal(t, 1, item.Attempts)
+	require.Len(t, fake.sentTxs(), 2)
+	cancel()
+	time.Sleep(time.Millisecond * 100)
+	require.Len(t, fake.sentTxs(), 2)
+
+	// another tx with the nonce of a tracked tx is not sent, the tracked tx keeps its waiters
+	ret := waitStatus(t, pi, tx.Hash())
+	colliding, err := txOption.Signer(sender, fee.newTx(chainID, 2, &accs[0].commAddr, big.NewInt(2), 21000, nil))
+	require.NoError(t, err)
+	require.ErrorIs(t, pi.submitTx(bcommon.Submitter{}, accs[1].pk, sender, colliding, 108), ErrNonceTaken)
+	require.Len(t, fake.sentTxs(), 2)
+	pi.finishSend(accs[1].pk, sender, colliding, 108, false, nil)
+	_, ok = pi.findBroadcast(colliding.Hash())
+	require.False(t, ok)
+	fake.mine(tx.Hash(), 1)
+	pi.CheckBroadcastTxs(109)
+	status := <-ret
+	require.NoError(t, status.err)
+	require.Equal(t, tx.Hash().Hex(), status.hash)
+}
@@ -1,6 +1,9 @@
 package pubchain
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -10,9 +13,13 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/rs/zerolog"
+	"gitlab.com/joltify/joltifychain-bridge/config"
 	"gitlab.com/joltify/joltifychain-bridge/generated"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
 	"gitlab.com/joltify/joltifychain-bridge/tssclient"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -37,6 +44,46 @@ type InboundReq struct {
 	toPoolAddr  common.Address
 	coin        sdk.Coin
 	blockHeight int64
+	// sourceHeights are the public chain blocks this request is derived from
+	sourceHeights []int64
+}
+
+// inboundReqJSON is the persisted form of the InboundReq
+type inboundReqJSON struct {
+	Address       sdk.AccAddress `json:"address"`
+	TxID          []byte         `json:"tx_id"`
+	ToPoolAddr    common.Address `json:"to_pool_addr"`
+	Coin          sdk.Coin       `json:"coin"`
+	BlockHeight   int64          `json:"block_height"`
+	SourceHeights []int64        `json:"source_heights"`
+}
+
+// MarshalJSON encodes the inbound request for the state store
+func (i *InboundReq) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inboundReqJSON{
+		Address:       i.address,
+		TxID:          i.txID,
+		ToPoolAddr:    i.toPoolAddr,
+		Coin:          i.coin,
+		BlockHeight:   i.blockHeight,
+		SourceHeights: i.sourceHeights,
+	})
+}
+
+// UnmarshalJSON decodes the inbound request loaded from the state store
+func (i *InboundReq) UnmarshalJSON(data []byte) error {
+	var v inboundReqJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*i = NewAccountInboundReq(v.Address, v.ToPoolAddr, v.Coin, v.TxID, v.BlockHeight)
+	i.sourceHeights = v.SourceHeights
+	return nil
+}
+
+// SourceTx returns the hash of the deposit tx on the public chain
+func (i *InboundReq) SourceTx() string {
+	return hex.EncodeToString(i.txID)
 }
 
 func (i *InboundReq) Hash() common.Hash {
@@ -46,11 +93,11 @@ func (i *InboundReq) Hash() common.Hash {
 
 func NewAccountInboundReq(address sdk.AccAddress, toPoolAddr common.Address, coin sdk.Coin, txid []byte, blockHeight int64) InboundReq {
 	return InboundReq{
-		address,
-		txid,
-		toPoolAddr,
-		coin,
-		blockHeight,
+		address:     address,
+		txID:        txid,
+		toPoolAddr:  toPoolAddr,
+		coin:        coin,
+		blockHeight: blockHeight,
 	}
 }
 
@@ -64,10 +111,40 @@ func (acq *InboundReq) SetItemHeight(blockHeight int64) {
 	acq.blockHeight = blockHeight
 }
 
+// SourceHeight returns the public chain block that completes the request, it is the later one of the token tx and
+// the fee tx
+func (acq *InboundReq) SourceHeight() int64 {
+	height := acq.blockHeight
+	for _, el := range acq.sourceHeights {
+		if el > height {
+			height = el
+		}
+	}
+	return height
+}
+
 func (pi *PubChainInstance) AddItem(req *InboundReq) {
 	pi.RetryInboundReq.Store(req.Hash().Big(), req)
+	pi.persist(pi.bucket(storage.RetryInboundBucket), req.Hash().Hex(), req)
+	// the retry queue keeps the request from now on
+	pi.FinishInflight(req)
+	pi.Transfers.Update(req.SourceTx(), monitor.TransferQueued)
+}
+
+// SendInbound hands the request to the processing channel, the request is kept in the store until it is minted or
+// back in the retry queue, so a crash in between does not lose it
+func (pi *PubChainInstance) SendInbound(req *InboundReq) {
+	pi.persist(pi.bucket(storage.InflightInboundBucket), req.Hash().Hex(), req)
+	pi.InboundReqChan <- req
 }
 
+// FinishInflight removes the request handed to the processing from the store
+func (pi *PubChainInstance) FinishInflight(req *InboundReq) {
+	pi.unpersist(pi.bucket(storage.InflightInboundBucket), req.Hash().Hex())
+}
+
+// PopItem pops the retry request with the highest hash, the request is kept in the store as in-flight until it is
+// minted or back in the retry queue
 func (pi *PubChainInstance) PopItem() *InboundReq {
 	max := big.NewInt(0)
 	pi.RetryInboundReq.Range(func(key, value interface{}) bool {
@@ -79,11 +156,27 @@ func (pi *PubChainInstance) PopItem() *InboundReq {
 	})
 	if max.Cmp(big.NewInt(0)) == 1 {
 		item, _ := pi.RetryInboundReq.LoadAndDelete(max)
-		return item.(*InboundReq)
+		req := item.(*InboundReq)
+		pi.persist(pi.bucket(storage.InflightInboundBucket), req.Hash().Hex(), req)
+		pi.unpersist(pi.bucket(storage.RetryInboundBucket), req.Hash().Hex())
+		return req
 	}
 	return nil
 }
 
+// PopItems pops at most n items from the retry queue
+func (pi *PubChainInstance) PopItems(n int) []*InboundReq {
+	var items []*InboundReq
+	for len(items) < n {
+		item := pi.PopItem()
+		if item == nil {
+			break
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
 func (pi *PubChainInstance) Size() int {
 	i := 0
 	pi.RetryInboundReq.Range(func(key, value interface{}) bool {
@@ -109,18 +202,62 @@ type inboundTx struct {
 	fee            sdk.Coin
 }
 
+// inboundTxJSON is the persisted form of the inboundTx
+type inboundTxJSON struct {
+	Address        sdk.AccAddress `json:"address"`
+	PubBlockHeight uint64         `json:"pub_block_height"`
+	Token          sdk.Coin       `json:"token"`
+	Fee            sdk.Coin       `json:"fee"`
+}
+
+func (a *inboundTx) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inboundTxJSON{a.address, a.pubBlockHeight, a.token, a.fee})
+}
+
+func (a *inboundTx) UnmarshalJSON(data []byte) error {
+	var v inboundTxJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = inboundTx{v.Address, v.PubBlockHeight, v.Token, v.Fee}
+	return nil
+}
+
 type inboundTxBnb struct {
 	blockHeight uint64
 	txID        string
 	fee         sdk.Coin
 }
 
+// inboundTxBnbJSON is the persisted form of the inboundTxBnb
+type inboundTxBnbJSON struct {
+	BlockHeight uint64   `json:"block_height"`
+	TxID        string   `json:"tx_id"`
+	Fee         sdk.Coin `json:"fee"`
+}
+
+func (a *inboundTxBnb) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inboundTxBnbJSON{a.blockHeight, a.txID, a.fee})
+}
+
+func (a *inboundTxBnb) UnmarshalJSON(data []byte) error {
+	var v inboundTxBnbJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = inboundTxBnb{v.BlockHeight, v.TxID, v.Fee}
+	return nil
+}
+
 // PubChainInstance hold the joltify_bridge entity
 type PubChainInstance struct {
 	EthClient          *ethclient.Client
-	tokenAddr          string
-	tokenInstance      *generated.Token
+	clientLocker       sync.RWMutex
+	wsAddress          string
+	chainID            uint64
+	tokens             *bcommon.TokenRegistry
 	tokenAbi           *abi.ABI
+	transferParser     *generated.TokenFilterer
 	logger             zerolog.Logger
 	pendingInbounds    *sync.Map
 	pendingInboundsBnB *sync.Map
@@ -130,35 +267,105 @@ type PubChainInstance struct {
 	InboundReqChan     chan *InboundReq
 	RetryInboundReq    *sync.Map // if a tx fail to process, we need to put in this channel and wait for retry
 	moveFundReq        *sync.Map
-	CurrentHeight      int64
+	droppedInbounds    *sync.Map // the retry inbounds dropped by the operator
+	droppedPending     *sync.Map // the pending inbounds dropped by the operator
+	droppedPendingBnB  *sync.Map // the BnB halves of the pending inbounds dropped by the operator
+	refunds            *sync.Map // the deposits that cannot be credited, waiting for their payouts back
+	stateStore         *storage.StateStore
+	maxLookBack        int64
+	confirmDepth       int64
+	blockBuffer        map[common.Hash]*bufferedBlock
+	chainTip           *ethTypes.Header
+	blockLocker        *sync.Mutex
+	stallTimeout       time.Duration
+	legacyTx           bool
+	gasTipCap          uint64
+	maxGasFeeCap       uint64
+	nonces             *NonceManager
+	broadcastTxs       *sync.Map
+	stuckTxBlocks      int64
+	multisendAddr      common.Address
+	outboundBatchSize  int
+	multisendAbi       *abi.ABI
+	checkingTxs        int32
+	reconnectCount     int64
+	subscribed         int32
+	lastHeadTime       int64 // the unix nano time we received the last head
+	// ctx is cancelled once the instance is closed, it stops the goroutines waiting to take over the txs
+	ctx           context.Context
+	cancel        context.CancelFunc
+	CurrentHeight int64
+	// SignPolicy holds the requests we observed, we only sign the txs rebuilt from them
+	SignPolicy *tssclient.Policy
+	Metric     *monitor.Metric
+	// Transfers records the lifecycle of the inbounds
+	Transfers *monitor.TransferTracker
+	// OnReorg is called with the reorged blocks once the inbounds we keep are cancelled, so the inbounds taken from
+	// the chain for minting are cancelled too
+	OnReorg func(heights map[int64]bool)
 }
 
-// NewChainInstance initialize the joltify_bridge entity
-func NewChainInstance(ws, tokenAddr string, tssServer tssclient.TssSign) (*PubChainInstance, error) {
+// NewChainInstance initialize the joltify_bridge entity, the chain ID is checked against the one reported by the node
+func NewChainInstance(cfg config.PubChainConfig, tokenList []config.TokenConfig, tssServer tssclient.TssSign, stateStore *storage.StateStore) (*PubChainInstance, error) {
 	logger := log.With().Str("module", "pubchain").Logger()
 
-	wsClient, err := ethclient.Dial(ws)
+	wsClient, err := ethclient.Dial(cfg.WsAddress)
 	if err != nil {
 		logger.Error().Err(err).Msg("fail to dial the websocket")
 		return nil, errors.New("fail to dial the network")
 	}
 
-	tokenIns, err := generated.NewToken(common.HexToAddress(tokenAddr), wsClient)
+	ctx, cancel := context.WithTimeout(context.Background(), chainQueryTimeout)
+	defer cancel()
+	nodeChainID, err := wsClient.ChainID(ctx)
+	if err != nil {
+		wsClient.Close()
+		return nil, fmt.Errorf("fail to get the chain ID with err %v", err)
+	}
+	if cfg.ChainID != 0 && cfg.ChainID != nodeChainID.Uint64() {
+		wsClient.Close()
+		return nil, fmt.Errorf("the node %v is on chain %v while we expect chain %v", cfg.WsAddress, nodeChainID, cfg.ChainID)
+	}
+	chainID := nodeChainID.Uint64()
+	logger = logger.With().Uint64("chain_id", chainID).Logger()
+
+	tokens, err := bcommon.NewTokenRegistry(chainID, tokenList)
 	if err != nil {
-		return nil, errors.New("fail to get the new token")
+		wsClient.Close()
+		return nil, err
+	}
+	err = tokens.SetOutboundFee(cfg.OutboundFeeDenom, cfg.OutboundFeeMin)
+	if err != nil {
+		wsClient.Close()
+		return nil, err
+	}
+
+	// the parser only decodes the logs, so it is not bound to any token or client
+	parser, err := generated.NewTokenFilterer(common.Address{}, nil)
+	if err != nil {
+		wsClient.Close()
+		return nil, errors.New("fail to create the transfer parser")
 	}
 
 	tAbi, err := abi.JSON(strings.NewReader(generated.TokenMetaData.ABI))
 	if err != nil {
+		wsClient.Close()
 		return nil, fmt.Errorf("fail to get the tokenABI with err %v", err)
 	}
+	mAbi, err := abi.JSON(strings.NewReader(generated.MultisendMetaData.ABI))
+	if err != nil {
+		wsClient.Close()
+		return nil, fmt.Errorf("fail to get the multisend ABI with err %v", err)
+	}
 
-	return &PubChainInstance{
+	pi := &PubChainInstance{
 		logger:             logger,
 		EthClient:          wsClient,
-		tokenAddr:          tokenAddr,
-		tokenInstance:      tokenIns,
+		wsAddress:          cfg.WsAddress,
+		chainID:            chainID,
+		tokens:             tokens,
 		tokenAbi:           &tAbi,
+		transferParser:     parser,
 		pendingInbounds:    new(sync.Map),
 		pendingInboundsBnB: new(sync.Map),
 		poolLocker:         &sync.RWMutex{},
@@ -167,5 +374,30 @@ func NewChainInstance(ws, tokenAddr string, tssServer tssclient.TssSign) (*PubCh
 		InboundReqChan:     make(chan *InboundReq, reqCacheSize),
 		RetryInboundReq:    &sync.Map{},
 		moveFundReq:        &sync.Map{},
-	}, nil
+		droppedInbounds:    &sync.Map{},
+		droppedPending:     &sync.Map{},
+		droppedPendingBnB:  &sync.Map{},
+		refunds:            &sync.Map{},
+		stateStore:         stateStore,
+		maxLookBack:        cfg.MaxLookBack,
+		confirmDepth:       cfg.ConfirmationDepth,
+		blockBuffer:        make(map[common.Hash]*bufferedBlock),
+		blockLocker:        &sync.Mutex{},
+		stallTimeout:       cfg.StallTimeout,
+		legacyTx:           cfg.LegacyTx,
+		gasTipCap:          cfg.GasTipCap,
+		maxGasFeeCap:       cfg.MaxGasFeeCap,
+		broadcastTxs:       &sync.Map{},
+		stuckTxBlocks:      cfg.StuckTxBlocks,
+		outboundBatchSize:  cfg.OutboundBatchSize,
+		multisendAbi:       &mAbi,
+	}
+	if cfg.MultisendAddress != "" {
+		pi.multisendAddr = common.HexToAddress(cfg.MultisendAddress)
+	}
+	pi.nonces = newNonceManager(logger, func() nonceSource {
+		return pi.getEthClient()
+	})
+	pi.ctx, pi.cancel = context.WithCancel(context.Background())
+	return pi, nil
 }
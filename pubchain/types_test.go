@@ -44,14 +44,9 @@ func (tm *TssMock) KeySign(pk string, msgs []string, blockHeight int64, signers
 	sEncoded := base64.StdEncoding.EncodeToString(s)
 	vEncoded := base64.StdEncoding.EncodeToString(v)
 
-	sig := keysign.Signature{
-		msgs[0],
-		rEncoded,
-		sEncoded,
-		vEncoded,
-	}
+	sig := keysign.NewSignature(msgs[0], rEncoded, sEncoded, vEncoded)
 
-	return keysign.Response{[]keysign.Signature{sig}, common.Success, blame.Blame{}}, nil
+	return keysign.Response{Signatures: []keysign.Signature{sig}, Status: common.Success, Blame: blame.Blame{}}, nil
 }
 
 func (tm *TssMock) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	stateFolder = "bridge_state"
+	separator   = "/"
+)
+
+// the buckets that hold the bridge queues
+const (
+	PendingInboundBucket    = "pending_inbound"
+	PendingInboundBnBBucket = "pending_inbound_bnb"
+	RetryInboundBucket      = "retry_inbound"
+	PubMoveFundBucket       = "pub_move_fund"
+	RetryOutboundBucket     = "retry_outbound"
+	JoltMoveFundBucket      = "jolt_move_fund"
+	ChainHeightBucket       = "chain_height"
+	JoltOutboundTxBucket    = "jolt_outbound_tx"
+	JoltRetryTxBucket       = "jolt_retry_tx"
+	PubBroadcastTxBucket    = "pub_broadcast_tx"
+	TssBlameBucket          = "tss_blame"
+	TssPoolMemberBucket     = "tss_pool_member"
+	TssConfirmedBlameBucket = "tss_confirmed_blame"
+	DroppedInboundBucket    = "dropped_inbound"
+	DroppedPendingBucket    = "dropped_pending"
+	DroppedPendingBnBBucket = "dropped_pending_bnb"
+	DroppedOutboundBucket   = "dropped_outbound"
+	AdminAuditBucket        = "admin_audit"
+	TransferBucket          = "transfer"
+	InflightInboundBucket   = "inflight_inbound"
+	InflightOutboundBucket  = "inflight_outbound"
+	RefundBucket            = "refund"
+)
+
+// the keys of the last processed block height of each chain
+const (
+	PubChainHeightKey = "pubchain"
+	JoltifyHeightKey  = "joltify"
+)
+
+// StateStore is the crash-safe key-value store that keeps the bridge queues across restarts
+type StateStore struct {
+	db *leveldb.DB
+}
+
+// NewStateStore opens (or creates) the state store under the given home folder
+func NewStateStore(homeDir string) (*StateStore, error) {
+	if homeDir == "" {
+		return nil, errors.New("empty home folder")
+	}
+	err := os.MkdirAll(homeDir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	db, err := leveldb.OpenFile(path.Join(homeDir, stateFolder), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &StateStore{db: db}, nil
+}
+
+// ChainBucket returns the name of the bucket (or key) that belongs to the given public chain
+func ChainBucket(name string, chainID uint64) string {
+	return fmt.Sprintf("%v@%v", name, chainID)
+}
+
+func bucketKey(bucket, key string) []byte {
+	return []byte(bucket + separator + key)
+}
+
+// Put stores the json encoded value under the given bucket and key, the write is synced to the disk
+func (s *StateStore) Put(bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(bucketKey(bucket, key), data, &opt.WriteOptions{Sync: true})
+}
+
+// Get loads the value stored under the given bucket and key, it returns false if the key does not exist
+func (s *StateStore) Get(bucket, key string, value interface{}) (bool, error) {
+	data, err := s.db.Get(bucketKey(bucket, key), nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+// Delete removes the given key from the bucket
+func (s *StateStore) Delete(bucket, key string) error {
+	return s.db.Delete(bucketKey(bucket, key), &opt.WriteOptions{Sync: true})
+}
+
+// Iterate walks through all the items in the given bucket
+func (s *StateStore) Iterate(bucket string, fn func(key string, value []byte) error) error {
+	return s.IteratePrefix(bucket, "", fn)
+}
+
+// IteratePrefix walks through the items in the given bucket whose key starts with the key prefix
+func (s *StateStore) IteratePrefix(bucket, keyPrefix string, fn func(key string, value []byte) error) error {
+	prefix := bucket + separator
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix+keyPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := strings.TrimPrefix(string(iter.Key()), prefix)
+		// the iterator reuses the buffer, so we need to copy the value out
+		value := make([]byte, len(iter.Value()))
+		copy(value, iter.Value())
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// MoveBucket moves all the items of a bucket to another bucket in one batch
+func (s *StateStore) MoveBucket(from, to string) error {
+	batch := new(leveldb.Batch)
+	err := s.Iterate(from, func(key string, value []byte) error {
+		batch.Delete(bucketKey(from, key))
+		batch.Put(bucketKey(to, key), value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return s.db.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// Close closes the state store
+func (s *StateStore) Close() error {
+	return s.db.Close()
+}
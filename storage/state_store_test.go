@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testItem struct {
+	Name   string `json:"name"`
+	Height int64  `json:"height"`
+}
+
+func TestStateStore(t *testing.T) {
+	_, err := NewStateStore("")
+	require.EqualError(t, err, "empty home folder")
+
+	folder := t.TempDir()
+	store, err := NewStateStore(folder)
+	require.NoError(t, err)
+
+	err = store.Put(RetryInboundBucket, "key1", &testItem{"item1", 1})
+	require.NoError(t, err)
+	err = store.Put(RetryInboundBucket, "key2", &testItem{"item2", 2})
+	require.NoError(t, err)
+	err = store.Put(RetryOutboundBucket, "key1", &testItem{"item3", 3})
+	require.NoError(t, err)
+
+	var item testItem
+	found, err := store.Get(RetryOutboundBucket, "key1", &item)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "item3", item.Name)
+
+	found, err = store.Get(RetryOutboundBucket, "key2", &item)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	err = store.Delete(RetryInboundBucket, "key1")
+	require.NoError(t, err)
+
+	// now we kill the store and reopen it, the items should survive the restart
+	require.NoError(t, store.Close())
+	store, err = NewStateStore(folder)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	items := make(map[string]string)
+	err = store.Iterate(RetryInboundBucket, func(key string, value []byte) error {
+		items[key] = string(value)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, `{"name":"item2","height":2}`, items["key2"])
+
+	counter := 0
+	err = store.Iterate(RetryOutboundBucket, func(key string, value []byte) error {
+		counter++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, counter)
+}
+
+func TestMoveBucket(t *testing.T) {
+	store, err := NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	require.NoError(t, store.Put(RetryInboundBucket, "key1", &testItem{"item1", 1}))
+	require.NoError(t, store.Put(RetryInboundBucket, "key2", &testItem{"item2", 2}))
+	bucket := ChainBucket(RetryInboundBucket, 56)
+	require.NoError(t, store.MoveBucket(RetryInboundBucket, bucket))
+
+	var item testItem
+	found, err := store.Get(bucket, "key2", &item)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "item2", item.Name)
+	found, err = store.Get(RetryInboundBucket, "key1", &item)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// the chain buckets do not show up in the iteration of the original bucket
+	require.NoError(t, store.Put(RetryInboundBucket, "key3", &testItem{"item3", 3}))
+	counter := 0
+	err = store.Iterate(RetryInboundBucket, func(key string, value []byte) error {
+		counter++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, counter)
+
+	// moving an empty bucket is a no-op
+	require.NoError(t, store.MoveBucket(PendingInboundBucket, ChainBucket(PendingInboundBucket, 56)))
+}
@@ -0,0 +1,381 @@
+package tssclient
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joltify-finance/tss/blame"
+	"github.com/joltify-finance/tss/common"
+	"github.com/joltify-finance/tss/conversion"
+	"github.com/joltify-finance/tss/keygen"
+	"github.com/joltify-finance/tss/keysign"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+const (
+	// MaxBlames is how many confirmed blames within the blame window leave the peer out of the signers
+	MaxBlames = 3
+	// BlameWindow is how long a confirmed blame counts against the peer
+	BlameWindow = time.Hour
+	// maxBlameRecords is how many records we keep in the history of each peer
+	maxBlameRecords = 100
+	// oldJoinPartyVersion makes the tss run the keysign with the signers we pick instead of the online peers
+	oldJoinPartyVersion = "0.13.0"
+)
+
+// the tss operations a peer is blamed in
+const (
+	KeySignBlame = "keysign"
+	KeyGenBlame  = "keygen"
+)
+
+// BlameRecord is one failed tss operation the peer is blamed for
+type BlameRecord struct {
+	Kind   string    `json:"kind"`
+	Reason string    `json:"reason"`
+	Height int64     `json:"height"`
+	Time   time.Time `json:"time"`
+}
+
+// BlameReport is the blame of a failed keysign of the pool at the height as seen by one pool member
+type BlameReport struct {
+	Pool   string   `json:"pool"`
+	Height int64    `json:"height"`
+	Blamed []string `json:"blamed"`
+}
+
+func (r BlameReport) key() string {
+	return r.Pool + "/" + strconv.FormatInt(r.Height, 10)
+}
+
+// BlameSender shares our blame report with the other members of the pool
+type BlameSender interface {
+	Send(r BlameReport, members []string)
+}
+
+// keysignReports are the blame reports of one failed keysign keyed by the reporter
+type keysignReports struct {
+	received  time.Time
+	reporters map[string][]string
+	// confirmed are the peers whose blame has reached the quorum
+	confirmed map[string]bool
+}
+
+// BlameTracker keeps the blame history of each peer and the keygen members of each pool, both are persisted in
+// the state store. The history is only what this node has seen, so the peers are left out of the keysign for the
+// blames confirmed by a quorum of the pool members only, the members share their reports of each failed keysign.
+type BlameTracker struct {
+	lock    sync.RWMutex
+	history map[string][]BlameRecord
+	members map[string][]string
+	// reports are the blame reports of the failed keysigns keyed by the pool and the height
+	reports map[string]*keysignReports
+	// confirmed are the times the blames of each peer are confirmed
+	confirmed map[string][]time.Time
+	// self is our tss pubkey, the reports are only shared once it is set
+	self   string
+	sender BlameSender
+	store  *storage.StateStore
+	logger zerolog.Logger
+}
+
+// NewBlameTracker creates the blame tracker and loads the history from the store, a nil store keeps the history in
+// memory only
+func NewBlameTracker(store *storage.StateStore) (*BlameTracker, error) {
+	bt := &BlameTracker{
+		history:   make(map[string][]BlameRecord),
+		members:   make(map[string][]string),
+		reports:   make(map[string]*keysignReports),
+		confirmed: make(map[string][]time.Time),
+		store:     store,
+		logger:    log.With().Str("module", "tssBlame").Logger(),
+	}
+	if store == nil {
+		return bt, nil
+	}
+	err := store.Iterate(storage.TssBlameBucket, func(key string, value []byte) error {
+		var records []BlameRecord
+		if err := json.Unmarshal(value, &records); err != nil {
+			return err
+		}
+		bt.history[key] = records
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = store.Iterate(storage.TssPoolMemberBucket, func(key string, value []byte) error {
+		var members []string
+		if err := json.Unmarshal(value, &members); err != nil {
+			return err
+		}
+		bt.members[key] = members
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = store.Iterate(storage.TssConfirmedBlameBucket, func(key string, value []byte) error {
+		var confirmed []time.Time
+		if err := json.Unmarshal(value, &confirmed); err != nil {
+			return err
+		}
+		bt.confirmed[key] = confirmed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bt, nil
+}
+
+// SetSender sets our tss pubkey and the sender that shares our blame reports with the other pool members
+func (bt *BlameTracker) SetSender(self string, sender BlameSender) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	bt.self = self
+	bt.sender = sender
+}
+
+// Record adds the failed tss operation to the history of each blamed peer
+func (bt *BlameTracker) Record(kind string, height int64, b blame.Blame) {
+	if len(b.BlameNodes) == 0 {
+		return
+	}
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	now := time.Now().UTC()
+	for _, el := range b.BlameNodes {
+		if el.Pubkey == "" {
+			continue
+		}
+		records := append(bt.history[el.Pubkey], BlameRecord{Kind: kind, Reason: b.FailReason, Height: height, Time: now})
+		if len(records) > maxBlameRecords {
+			records = records[len(records)-maxBlameRecords:]
+		}
+		bt.history[el.Pubkey] = records
+		bt.logger.Warn().Msgf("the peer %v is blamed for %v at height %v: %v", el.Pubkey, kind, height, b.FailReason)
+		if bt.store != nil {
+			if err := bt.store.Put(storage.TssBlameBucket, el.Pubkey, records); err != nil {
+				bt.logger.Error().Err(err).Msgf("fail to persist the blame of %v", el.Pubkey)
+			}
+		}
+	}
+}
+
+// SetMembers records the members that generated the pool key
+func (bt *BlameTracker) SetMembers(poolPk string, members []string) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	bt.members[poolPk] = members
+	if bt.store != nil {
+		if err := bt.store.Put(storage.TssPoolMemberBucket, poolPk, members); err != nil {
+			bt.logger.Error().Err(err).Msgf("fail to persist the members of pool %v", poolPk)
+		}
+	}
+}
+
+// Report adds our blame of the failed keysign and shares it with the other members of the pool
+func (bt *BlameTracker) Report(poolPk string, height int64, b blame.Blame) {
+	var blamed []string
+	for _, el := range b.BlameNodes {
+		if el.Pubkey != "" {
+			blamed = append(blamed, el.Pubkey)
+		}
+	}
+	if len(blamed) == 0 {
+		return
+	}
+	r := BlameReport{Pool: poolPk, Height: height, Blamed: blamed}
+	bt.lock.RLock()
+	self, sender, members := bt.self, bt.sender, bt.members[poolPk]
+	bt.lock.RUnlock()
+	if self == "" || sender == nil {
+		return
+	}
+	bt.AddReport(self, r, time.Now())
+	sender.Send(r, members)
+}
+
+// AddReport adds the blame report of the pool member, the blame of a peer is confirmed once more than the keysign
+// threshold of the members have reported it for the same keysign
+func (bt *BlameTracker) AddReport(reporter string, r BlameReport, now time.Time) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	members := bt.members[r.Pool]
+	if !contains(members, reporter) {
+		bt.logger.Warn().Msgf("the blame report of %v is dropped as it is not a member of %v", reporter, r.Pool)
+		return
+	}
+	threshold, err := conversion.GetThreshold(len(members))
+	if err != nil {
+		return
+	}
+	for key, el := range bt.reports {
+		if now.Sub(el.received) >= BlameWindow {
+			delete(bt.reports, key)
+		}
+	}
+	reports, ok := bt.reports[r.key()]
+	if !ok {
+		reports = &keysignReports{received: now, reporters: make(map[string][]string), confirmed: make(map[string]bool)}
+		bt.reports[r.key()] = reports
+	}
+	reports.reporters[reporter] = r.Blamed
+	for _, pk := range r.Blamed {
+		if reports.confirmed[pk] || !contains(members, pk) {
+			continue
+		}
+		votes := 0
+		for _, blamed := range reports.reporters {
+			if contains(blamed, pk) {
+				votes++
+			}
+		}
+		if votes <= threshold {
+			continue
+		}
+		reports.confirmed[pk] = true
+		bt.confirm(pk, now)
+		bt.logger.Warn().Msgf("the blame of %v in the keysign of %v at height %v is confirmed", pk, r.Pool, r.Height)
+	}
+}
+
+func (bt *BlameTracker) confirm(pk string, now time.Time) {
+	var confirmed []time.Time
+	for _, el := range bt.confirmed[pk] {
+		if now.Sub(el) < BlameWindow {
+			confirmed = append(confirmed, el)
+		}
+	}
+	confirmed = append(confirmed, now)
+	bt.confirmed[pk] = confirmed
+	if bt.store != nil {
+		if err := bt.store.Put(storage.TssConfirmedBlameBucket, pk, confirmed); err != nil {
+			bt.logger.Error().Err(err).Msgf("fail to persist the confirmed blame of %v", pk)
+		}
+	}
+}
+
+// History returns a copy of the blame history of each peer
+func (bt *BlameTracker) History() map[string][]BlameRecord {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
+	ret := make(map[string][]BlameRecord, len(bt.history))
+	for k, v := range bt.history {
+		ret[k] = append([]BlameRecord{}, v...)
+	}
+	return ret
+}
+
+// Excluded returns the peers with at least MaxBlames confirmed blames within the blame window before now
+func (bt *BlameTracker) Excluded(now time.Time) map[string]bool {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
+	excluded := make(map[string]bool)
+	for pk, confirmed := range bt.confirmed {
+		recent := 0
+		for _, el := range confirmed {
+			if now.Sub(el) < BlameWindow {
+				recent++
+			}
+		}
+		if recent >= MaxBlames {
+			excluded[pk] = true
+		}
+	}
+	return excluded
+}
+
+// Signers returns the members of the pool without the repeatedly blamed peers, it returns false if no peer is left
+// out or too few members are left to sign
+func (bt *BlameTracker) Signers(poolPk string, now time.Time) ([]string, bool) {
+	bt.lock.RLock()
+	members := bt.members[poolPk]
+	bt.lock.RUnlock()
+	if len(members) == 0 {
+		return nil, false
+	}
+	excluded := bt.Excluded(now)
+	var signers []string
+	for _, el := range members {
+		if !excluded[el] {
+			signers = append(signers, el)
+		}
+	}
+	threshold, err := conversion.GetThreshold(len(members))
+	if err != nil || len(signers) == len(members) || len(signers) <= threshold {
+		return nil, false
+	}
+	// every node passes the same signers to the tss
+	sort.Strings(signers)
+	return signers, true
+}
+
+func contains(list []string, el string) bool {
+	for _, v := range list {
+		if v == el {
+			return true
+		}
+	}
+	return false
+}
+
+// BlameTssServer records the blame of the failed tss operations and leaves the peers with repeated blames confirmed
+// by the pool members out of the keysign
+type BlameTssServer struct {
+	ts      TssSign
+	tracker *BlameTracker
+}
+
+// NewBlameTssServer wraps the tss server with the blame tracker
+func NewBlameTssServer(ts TssSign, tracker *BlameTracker) *BlameTssServer {
+	return &BlameTssServer{
+		ts:      ts,
+		tracker: tracker,
+	}
+}
+
+// KeySign generates the signature, the repeatedly blamed peers are left out if the signers are not given, the blame of
+// a failed keysign is recorded and shared with the pool members
+func (bs *BlameTssServer) KeySign(pk string, msgs []string, blockHeight int64, signers []string, version string) (keysign.Response, error) {
+	if len(signers) == 0 {
+		if picked, ok := bs.tracker.Signers(pk, time.Now()); ok {
+			bs.tracker.logger.Info().Msgf("we sign with %v without the blamed peers", picked)
+			signers = picked
+			version = oldJoinPartyVersion
+		}
+	}
+	resp, err := bs.ts.KeySign(pk, msgs, blockHeight, signers, version)
+	if resp.Status != common.Success {
+		bs.tracker.Record(KeySignBlame, blockHeight, resp.Blame)
+		bs.tracker.Report(pk, blockHeight, resp.Blame)
+	}
+	return resp, err
+}
+
+// KeyGen generate the tss key and records the members of the new pool
+func (bs *BlameTssServer) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
+	resp, err := bs.ts.KeyGen(keys, blockHeight, version)
+	if err != nil || resp.Status != common.Success {
+		bs.tracker.Record(KeyGenBlame, blockHeight, resp.Blame)
+		return resp, err
+	}
+	bs.tracker.SetMembers(resp.PubKey, keys)
+	return resp, nil
+}
+
+// GetTssNodeID get the tss node ID
+func (bs *BlameTssServer) GetTssNodeID() string {
+	return bs.ts.GetTssNodeID()
+}
+
+// Stop stop the tss server
+func (bs *BlameTssServer) Stop() {
+	bs.ts.Stop()
+}
@@ -0,0 +1,111 @@
+package tssclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/joltify-finance/tss/conversion"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// blameProtocol is the p2p protocol the pool members share their blame reports over
+	blameProtocol = protocol.ID("/joltify/bridge/blame/1.0.0")
+	// blameSendTimeout is how long we try to deliver the blame report to one member
+	blameSendTimeout = time.Second * 10
+	// maxBlameReportSize is the largest blame report we read from a member
+	maxBlameReportSize = 64 * 1024
+)
+
+// p2pBlameSender sends our blame reports to the pool members over the p2p host of the tss
+type p2pBlameSender struct {
+	host   host.Host
+	logger zerolog.Logger
+}
+
+// Send delivers the report to each member of the pool except ourselves in the background
+func (s *p2pBlameSender) Send(r BlameReport, members []string) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("fail to encode the blame report")
+		return
+	}
+	for _, el := range members {
+		peerID, err := conversion.GetPeerIDFromPubKey(el)
+		if err != nil {
+			s.logger.Warn().Err(err).Msgf("fail to get the peer of the member %v", el)
+			continue
+		}
+		if peerID == s.host.ID() {
+			continue
+		}
+		go s.send(peerID, data)
+	}
+}
+
+func (s *p2pBlameSender) send(peerID peer.ID, data []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), blameSendTimeout)
+	defer cancel()
+	stream, err := s.host.NewStream(ctx, peerID, blameProtocol)
+	if err != nil {
+		s.logger.Warn().Err(err).Msgf("fail to open the stream to %v", peerID)
+		return
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+	_ = stream.SetWriteDeadline(time.Now().Add(blameSendTimeout))
+	if _, err := stream.Write(data); err != nil {
+		s.logger.Warn().Err(err).Msgf("fail to send the blame report to %v", peerID)
+	}
+}
+
+// serveBlames handles the blame reports of the pool members on the host and shares our own reports over it, the
+// reporter is the authenticated peer of the stream, so a member cannot report for another one
+func serveBlames(h host.Host, tracker *BlameTracker) error {
+	self, err := conversion.GetPubKeyFromPeerID(h.ID().String())
+	if err != nil {
+		return err
+	}
+	logger := log.With().Str("module", "tssBlame").Logger()
+	h.SetStreamHandler(blameProtocol, func(stream network.Stream) {
+		defer func() {
+			_ = stream.Close()
+		}()
+		reporter, err := conversion.GetPubKeyFromPeerID(stream.Conn().RemotePeer().String())
+		if err != nil {
+			logger.Warn().Err(err).Msg("fail to get the pubkey of the blame reporter")
+			return
+		}
+		_ = stream.SetReadDeadline(time.Now().Add(blameSendTimeout))
+		data, err := io.ReadAll(io.LimitReader(stream, maxBlameReportSize))
+		if err != nil {
+			logger.Warn().Err(err).Msgf("fail to read the blame report of %v", reporter)
+			return
+		}
+		var r BlameReport
+		if err := json.Unmarshal(data, &r); err != nil {
+			logger.Warn().Err(err).Msgf("fail to decode the blame report of %v", reporter)
+			return
+		}
+		tracker.AddReport(reporter, r, time.Now())
+	})
+	tracker.SetSender(self, &p2pBlameSender{host: h, logger: logger})
+	return nil
+}
+
+// ServeBlames shares the blame reports with the other pool members over the p2p host of the tss server
+func (tc *BridgeTssServer) ServeBlames(tracker *BlameTracker) error {
+	h, err := tc.p2pHost()
+	if err != nil {
+		return err
+	}
+	return serveBlames(h, tracker)
+}
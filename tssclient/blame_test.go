@@ -0,0 +1,160 @@
+package tssclient
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/types/bech32/legacybech32" // nolint
+	"github.com/joltify-finance/tss/blame"
+	"github.com/joltify-finance/tss/common"
+	"github.com/joltify-finance/tss/keygen"
+	"github.com/joltify-finance/tss/keysign"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	maddr "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/joltify/joltifychain-bridge/storage"
+)
+
+type blameTss struct {
+	fakeTss
+	blamed  []string
+	signers []string
+	version string
+}
+
+func (b *blameTss) KeySign(pk string, msgs []string, blockHeight int64, signers []string, version string) (keysign.Response, error) {
+	b.signers = signers
+	b.version = version
+	var nodes []blame.Node
+	for _, el := range b.blamed {
+		nodes = append(nodes, blame.NewNode(el, nil, nil))
+	}
+	return keysign.NewResponse(nil, common.Fail, blame.NewBlame(blame.TssSyncFail, nodes)), nil
+}
+
+func (b *blameTss) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
+	return keygen.NewResponse("poolpk", "pooladdr", common.Success, blame.Blame{}), nil
+}
+
+// newTestMembers creates the hosts of the pool members on the mock network and their tss pubkeys
+func newTestMembers(t *testing.T, mn mocknet.Mocknet, n int) ([]host.Host, []string) {
+	var hosts []host.Host
+	var pubKeys []string
+	for i := 0; i < n; i++ {
+		sk, pk, err := crypto.GenerateEd25519Key(nil)
+		require.NoError(t, err)
+		h, err := mn.AddPeer(sk, maddr.StringCast("/ip4/127.0.0.1/tcp/4001"))
+		require.NoError(t, err)
+		raw, err := pk.Raw()
+		require.NoError(t, err)
+		pubKey, err := legacybech32.MarshalPubKey(legacybech32.AccPK, &ed25519.PubKey{Key: raw}) // nolint
+		require.NoError(t, err)
+		hosts = append(hosts, h)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return hosts, pubKeys
+}
+
+func TestBlameTracker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, members := newTestMembers(t, mocknet.New(ctx), 4)
+
+	store, err := storage.NewStateStore(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+	tracker, err := NewBlameTracker(store)
+	require.NoError(t, err)
+
+	ts := &blameTss{}
+	bs := NewBlameTssServer(ts, tracker)
+	_, err = bs.KeyGen(members, 10, TssVersion)
+	require.NoError(t, err)
+
+	// the blames we have seen alone are recorded while the signers are left to the tss
+	ts.blamed = []string{members[0], members[1]}
+	for i := 0; i < MaxBlames; i++ {
+		_, err = bs.KeySign("poolpk", []string{"msg"}, int64(i), nil, TssVersion)
+		require.NoError(t, err)
+		require.Nil(t, ts.signers)
+		require.Equal(t, TssVersion, ts.version)
+	}
+	require.Len(t, tracker.History()[members[0]], MaxBlames)
+	require.Len(t, tracker.History()[members[1]], MaxBlames)
+	require.Equal(t, KeySignBlame, tracker.History()[members[0]][0].Kind)
+	require.Equal(t, blame.TssSyncFail, tracker.History()[members[0]][0].Reason)
+	require.Equal(t, int64(2), tracker.History()[members[0]][2].Height)
+	require.Empty(t, tracker.Excluded(time.Now()))
+
+	// the blame counts once more than the threshold of the members report it for the same keysign
+	now := time.Now()
+	for i := 0; i < MaxBlames; i++ {
+		r := BlameReport{Pool: "poolpk", Height: int64(i), Blamed: []string{members[0]}}
+		tracker.AddReport(members[1], r, now)
+		tracker.AddReport(members[2], r, now)
+		// the same member reporting twice and the one not in the pool do not count
+		tracker.AddReport(members[2], r, now)
+		tracker.AddReport("stranger", r, now)
+		require.Empty(t, tracker.Excluded(now))
+		tracker.AddReport(members[3], r, now)
+	}
+	require.Equal(t, map[string]bool{members[0]: true}, tracker.Excluded(now))
+
+	_, err = bs.KeySign("poolpk", []string{"msg"}, 5, nil, TssVersion)
+	require.NoError(t, err)
+	expected := append([]string{}, members[1:]...)
+	sort.Strings(expected)
+	require.Equal(t, expected, ts.signers)
+	require.Equal(t, oldJoinPartyVersion, ts.version)
+
+	// the blames expire after the window
+	_, ok := tracker.Signers("poolpk", now.Add(BlameWindow))
+	require.False(t, ok)
+	// the unknown pool has no members to pick from
+	_, ok = tracker.Signers("other", now)
+	require.False(t, ok)
+
+	// the history, the members and the confirmed blames survive the restart
+	restored, err := NewBlameTracker(store)
+	require.NoError(t, err)
+	require.Equal(t, tracker.History(), restored.History())
+	require.Equal(t, members, restored.members["poolpk"])
+	require.Equal(t, tracker.Excluded(now), restored.Excluded(now))
+}
+
+func TestServeBlames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn := mocknet.New(ctx)
+	hosts, members := newTestMembers(t, mn, 4)
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	var trackers []*BlameTracker
+	for _, h := range hosts {
+		tracker, err := NewBlameTracker(nil)
+		require.NoError(t, err)
+		tracker.SetMembers("poolpk", members)
+		require.NoError(t, serveBlames(h, tracker))
+		trackers = append(trackers, tracker)
+	}
+
+	// three members blame the last one in the same keysign, so every member confirms the blame
+	failed := blame.NewBlame(blame.TssSyncFail, []blame.Node{blame.NewNode(members[3], nil, nil)})
+	for i := 0; i < MaxBlames; i++ {
+		for _, el := range trackers[:3] {
+			el.Report("poolpk", int64(i), failed)
+		}
+	}
+	for _, el := range trackers {
+		tracker := el
+		require.Eventually(t, func() bool {
+			return tracker.Excluded(time.Now())[members[3]]
+		}, time.Second*5, time.Millisecond*50)
+	}
+}
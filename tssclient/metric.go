@@ -0,0 +1,50 @@
+package tssclient
+
+import (
+	"time"
+
+	"github.com/joltify-finance/tss/common"
+	"github.com/joltify-finance/tss/keygen"
+	"github.com/joltify-finance/tss/keysign"
+	"gitlab.com/joltify/joltifychain-bridge/monitor"
+)
+
+// MetricTssServer records the latency and the result of the tss operations
+type MetricTssServer struct {
+	ts     TssSign
+	metric *monitor.Metric
+}
+
+// NewMetricTssServer wraps the tss server with the metric
+func NewMetricTssServer(ts TssSign, metric *monitor.Metric) *MetricTssServer {
+	return &MetricTssServer{
+		ts:     ts,
+		metric: metric,
+	}
+}
+
+// KeySign generates the signature
+func (ms *MetricTssServer) KeySign(pk string, msgs []string, blockHeight int64, signers []string, version string) (keysign.Response, error) {
+	start := time.Now()
+	resp, err := ms.ts.KeySign(pk, msgs, blockHeight, signers, version)
+	ms.metric.UpdateKeySign(time.Since(start), err == nil && resp.Status == common.Success)
+	return resp, err
+}
+
+// KeyGen generate the tss key
+func (ms *MetricTssServer) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
+	start := time.Now()
+	resp, err := ms.ts.KeyGen(keys, blockHeight, version)
+	ms.metric.UpdateKeyGen(time.Since(start), err == nil && resp.Status == common.Success)
+	return resp, err
+}
+
+// GetTssNodeID get the tss node ID
+func (ms *MetricTssServer) GetTssNodeID() string {
+	return ms.ts.GetTssNodeID()
+}
+
+// Stop stop the tss server
+func (ms *MetricTssServer) Stop() {
+	ms.ts.Stop()
+}
@@ -0,0 +1,57 @@
+package tssclient
+
+import (
+	"errors"
+	"reflect"
+	"unsafe"
+
+	"github.com/joltify-finance/tss/conversion"
+	"github.com/joltify-finance/tss/p2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+)
+
+// connectedPeers returns how many of the tss peers with the given pubkeys are connected to the host, we do not count
+// ourselves
+func connectedPeers(h host.Host, pubKeys []string) (int, error) {
+	count := 0
+	for _, el := range pubKeys {
+		peerID, err := conversion.GetPeerIDFromPubKey(el)
+		if err != nil {
+			return 0, err
+		}
+		if peerID == h.ID() {
+			continue
+		}
+		if h.Network().Connectedness(peerID) == network.Connected {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// p2pHost returns the p2p host of the tss server, the tss server does not export it, so we read it from its
+// communication layer
+func (tc *BridgeTssServer) p2pHost() (host.Host, error) {
+	if tc.ts == nil {
+		return nil, errors.New("the tss server is not started")
+	}
+	field := reflect.ValueOf(tc.ts).Elem().FieldByName("p2pCommunication")
+	if !field.IsValid() || field.IsNil() {
+		return nil, errors.New("the tss server has no p2p communication")
+	}
+	comm, ok := reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Interface().(*p2p.Communication)
+	if !ok {
+		return nil, errors.New("unknown p2p communication of the tss server")
+	}
+	return comm.GetHost(), nil
+}
+
+// ConnectedPeers returns how many of the pool members with the given tss pubkeys are connected to our tss host
+func (tc *BridgeTssServer) ConnectedPeers(pubKeys []string) (int, error) {
+	h, err := tc.p2pHost()
+	if err != nil {
+		return 0, err
+	}
+	return connectedPeers(h, pubKeys)
+}
@@ -0,0 +1,52 @@
+package tssclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/types/bech32/legacybech32" // nolint
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	maddr "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectedPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn := mocknet.New(ctx)
+
+	var hosts []host.Host
+	var pubKeys []string
+	for i := 0; i < 4; i++ {
+		sk, pk, err := crypto.GenerateEd25519Key(nil)
+		require.NoError(t, err)
+		h, err := mn.AddPeer(sk, maddr.StringCast("/ip4/127.0.0.1/tcp/4001"))
+		require.NoError(t, err)
+		raw, err := pk.Raw()
+		require.NoError(t, err)
+		pubKey, err := legacybech32.MarshalPubKey(legacybech32.AccPK, &ed25519.PubKey{Key: raw}) // nolint
+		require.NoError(t, err)
+		hosts = append(hosts, h)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	require.NoError(t, mn.LinkAll())
+
+	count, err := connectedPeers(hosts[0], pubKeys)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	_, err = mn.ConnectPeers(hosts[0].ID(), hosts[1].ID())
+	require.NoError(t, err)
+	_, err = mn.ConnectPeers(hosts[0].ID(), hosts[2].ID())
+	require.NoError(t, err)
+	// we do not count ourselves
+	count, err = connectedPeers(hosts[0], pubKeys)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	_, err = connectedPeers(hosts[0], []string{"invalid"})
+	require.Error(t, err)
+}
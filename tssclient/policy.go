@@ -0,0 +1,211 @@
+package tssclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/joltify-finance/tss/keygen"
+	"github.com/joltify-finance/tss/keysign"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrUnknownMsg is returned when we are asked to sign a message we have not rebuilt from our own observations
+var ErrUnknownMsg = errors.New("the keysign message is not expected")
+
+// MintKey is the observation of an inbound that mints the coin to the receiver on joltify
+func MintKey(index string, receiver sdk.AccAddress, coin sdk.Coin) string {
+	return fmt.Sprintf("mint/%v/%v/%v", strings.ToLower(index), receiver.String(), coin.String())
+}
+
+// PayoutKey is the observation of an outbound that pays the token from the pool on the public chain
+func PayoutKey(chainID uint64, pool, receiver, token common.Address, amount *big.Int) string {
+	return fmt.Sprintf("payout/%v/%v/%v/%v/%v", chainID, pool.Hex(), receiver.Hex(), token.Hex(), amount.String())
+}
+
+// IsPayoutKey returns true if the observation is an outbound payout
+func IsPayoutKey(key string) bool {
+	return strings.HasPrefix(key, "payout/")
+}
+
+// ApprovalKey is the observation of the batch total the pool approves the multisend contract to spend
+func ApprovalKey(chainID uint64, pool, token common.Address, amount *big.Int) string {
+	return fmt.Sprintf("approval/%v/%v/%v/%v", chainID, pool.Hex(), token.Hex(), amount.String())
+}
+
+// RetiredPoolKey is the observation of a pool whose fund moves to the current pool
+func RetiredPoolKey(pool string) string {
+	return fmt.Sprintf("retired/%v", strings.ToLower(pool))
+}
+
+type expectedMsg struct {
+	pk   string
+	keys []string
+}
+
+// Policy keeps the requests this node has observed on its own, the chain clients rebuild the request of each
+// message from the tx they are about to sign and register the message, we only join the keysign of the registered
+// messages
+type Policy struct {
+	lock sync.Mutex
+	// observed maps the observation key to the IDs of the requests that have it, the same payout may be made by
+	// several requests
+	observed map[string]map[string]bool
+	expected map[string]expectedMsg
+	logger   zerolog.Logger
+}
+
+// NewPolicy creates an empty signing policy
+func NewPolicy() *Policy {
+	return &Policy{
+		observed: make(map[string]map[string]bool),
+		expected: make(map[string]expectedMsg),
+		logger:   log.With().Str("module", "signPolicy").Logger(),
+	}
+}
+
+// Observe records the request we have seen on the chain ourselves, a nil policy checks nothing
+func (p *Policy) Observe(key, requestID string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ids, ok := p.observed[key]
+	if !ok {
+		ids = make(map[string]bool)
+		p.observed[key] = ids
+	}
+	ids[requestID] = true
+}
+
+// ObserveDerived records the request derived from the requests we have observed, e.g. the approval of the total of
+// the batched payouts, it fails if any of them is not observed
+func (p *Policy) ObserveDerived(key, requestID string, from ...string) error {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	used := make(map[string]int)
+	for _, el := range from {
+		used[el]++
+		if used[el] > len(p.observed[el]) {
+			return fmt.Errorf("the request %v is not observed", el)
+		}
+	}
+	ids, ok := p.observed[key]
+	if !ok {
+		ids = make(map[string]bool)
+		p.observed[key] = ids
+	}
+	ids[requestID] = true
+	return nil
+}
+
+// Forget drops the request once it is done, so it cannot be signed again
+func (p *Policy) Forget(key, requestID string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.observed[key], requestID)
+	if len(p.observed[key]) == 0 {
+		delete(p.observed, key)
+	}
+}
+
+// Expect registers the message we are about to sign with the pool key, keys are the observations rebuilt from the
+// tx of the message, the same key appears as many times as the tx spends it
+func (p *Policy) Expect(pk string, msg []byte, keys ...string) error {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	used := make(map[string]int)
+	for _, el := range keys {
+		used[el]++
+		if used[el] > len(p.observed[el]) {
+			p.logger.Error().Msgf("the request %v of the keysign message is not observed by us", el)
+			return fmt.Errorf("the request %v is not observed", el)
+		}
+	}
+	p.expected[base64.StdEncoding.EncodeToString(msg)] = expectedMsg{pk: pk, keys: keys}
+	return nil
+}
+
+// Discard drops the expectation of the message once the keysign is over, the message allowed by the keysign has been
+// consumed already
+func (p *Policy) Discard(msg []byte) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.expected, base64.StdEncoding.EncodeToString(msg))
+}
+
+// allow checks that all the messages are expected with the pool key, the expectations are consumed
+func (p *Policy) allow(pk string, msgs []string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(msgs) == 0 {
+		return ErrUnknownMsg
+	}
+	for _, el := range msgs {
+		expected, ok := p.expected[el]
+		if !ok || expected.pk != pk {
+			p.logger.Error().Msgf("we refuse to sign the unknown message %v with %v", el, pk)
+			return ErrUnknownMsg
+		}
+	}
+	for _, el := range msgs {
+		delete(p.expected, el)
+	}
+	return nil
+}
+
+// PolicyTssServer only joins the keysign of the messages allowed by the policy
+type PolicyTssServer struct {
+	ts     TssSign
+	policy *Policy
+}
+
+// NewPolicyTssServer wraps the tss server with the signing policy
+func NewPolicyTssServer(ts TssSign, policy *Policy) *PolicyTssServer {
+	return &PolicyTssServer{
+		ts:     ts,
+		policy: policy,
+	}
+}
+
+// KeySign generates the signature if all the messages are allowed
+func (ps *PolicyTssServer) KeySign(pk string, msgs []string, blockHeight int64, signers []string, version string) (keysign.Response, error) {
+	if err := ps.policy.allow(pk, msgs); err != nil {
+		return keysign.Response{}, err
+	}
+	return ps.ts.KeySign(pk, msgs, blockHeight, signers, version)
+}
+
+// KeyGen generate the tss key
+func (ps *PolicyTssServer) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
+	return ps.ts.KeyGen(keys, blockHeight, version)
+}
+
+// GetTssNodeID get the tss node ID
+func (ps *PolicyTssServer) GetTssNodeID() string {
+	return ps.ts.GetTssNodeID()
+}
+
+// Stop stop the tss server
+func (ps *PolicyTssServer) Stop() {
+	ps.ts.Stop()
+}
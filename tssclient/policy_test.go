@@ -0,0 +1,95 @@
+package tssclient
+
+import (
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/joltify-finance/tss/keygen"
+	"github.com/joltify-finance/tss/keysign"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTss struct {
+	signed int
+}
+
+func (f *fakeTss) KeySign(pk string, msgs []string, blockHeight int64, signers []string, version string) (keysign.Response, error) {
+	f.signed++
+	return keysign.Response{}, nil
+}
+
+func (f *fakeTss) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
+	return keygen.Response{}, nil
+}
+
+func (f *fakeTss) GetTssNodeID() string {
+	return ""
+}
+
+func (f *fakeTss) Stop() {}
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy()
+	ts := &fakeTss{}
+	ps := NewPolicyTssServer(ts, policy)
+	encode := func(msg []byte) []string {
+		return []string{base64.StdEncoding.EncodeToString(msg)}
+	}
+
+	payout := PayoutKey(56, common.Address{1}, common.Address{2}, common.Address{3}, big.NewInt(10))
+	mint := MintKey("0xAB", sdk.AccAddress{1}, sdk.NewCoin("JUSD", sdk.NewInt(1)))
+	require.Equal(t, MintKey("0xab", sdk.AccAddress{1}, sdk.NewCoin("JUSD", sdk.NewInt(1))), mint)
+
+	// the requests we have not observed are refused
+	require.Error(t, policy.Expect("pk", []byte("msg"), payout))
+	policy.Observe(payout, "tx1")
+	policy.Observe(payout, "tx1")
+	policy.Observe(mint, "tx2")
+	// the same payout is only observed once for the same request
+	require.Error(t, policy.Expect("pk", []byte("msg"), payout, payout))
+	require.NoError(t, policy.Expect("pk", []byte("msg"), payout, mint))
+
+	// the message is only signed with the expected key and only once
+	_, err := ps.KeySign("other", encode([]byte("msg")), 10, nil, TssVersion)
+	require.ErrorIs(t, err, ErrUnknownMsg)
+	_, err = ps.KeySign("pk", encode([]byte("msg")), 10, nil, TssVersion)
+	require.NoError(t, err)
+	_, err = ps.KeySign("pk", encode([]byte("msg")), 10, nil, TssVersion)
+	require.ErrorIs(t, err, ErrUnknownMsg)
+	_, err = ps.KeySign("pk", encode([]byte("unknown")), 10, nil, TssVersion)
+	require.ErrorIs(t, err, ErrUnknownMsg)
+	require.Equal(t, 1, ts.signed)
+
+	// two outbounds paying the same amount to the same receiver are both signed
+	policy.Observe(payout, "tx3")
+	require.NoError(t, policy.Expect("pk", []byte("batch"), payout, payout))
+	policy.Forget(payout, "tx1")
+	policy.Forget(payout, "tx3")
+	require.Error(t, policy.Expect("pk", []byte("msg"), payout))
+
+	// the approval of the batch total is only observed if all its payouts are observed
+	policy.Observe(payout, "tx4")
+	approval := ApprovalKey(56, common.Address{1}, common.Address{3}, big.NewInt(20))
+	require.Error(t, policy.ObserveDerived(approval, "batch", payout, payout))
+	require.NoError(t, policy.ObserveDerived(approval, "batch", payout))
+	require.NoError(t, policy.Expect("pk", []byte("approve"), approval))
+	policy.Forget(approval, "batch")
+	require.Error(t, policy.Expect("pk", []byte("approve2"), approval))
+	// the expectation of a keysign that never reaches us is dropped
+	policy.Discard([]byte("approve"))
+	_, err = ps.KeySign("pk", encode([]byte("approve")), 10, nil, TssVersion)
+	require.ErrorIs(t, err, ErrUnknownMsg)
+	policy.Forget(payout, "tx4")
+	policy.Forget(mint, "tx2")
+	require.Len(t, policy.observed, 0)
+	policy.Discard([]byte("batch"))
+	require.Len(t, policy.expected, 0)
+
+	// a nil policy checks nothing
+	var noPolicy *Policy
+	noPolicy.Observe(payout, "tx1")
+	require.NoError(t, noPolicy.Expect("pk", []byte("msg"), payout))
+}
@@ -85,21 +85,15 @@ func StartTssServer(baseFolder string, tssConfig config.TssConfig) (*BridgeTssSe
 // KeyGen generate the tss key
 func (tc *BridgeTssServer) KeyGen(keys []string, blockHeight int64, version string) (keygen.Response, error) {
 	req := keygen.NewRequest(keys, blockHeight, version)
-	resp, err := tc.ts.Keygen(req)
-	if err != nil {
-		return keygen.Response{}, err
-	}
-	return resp, nil
+	// the response carries the blame even if the keygen fails
+	return tc.ts.Keygen(req)
 }
 
 // KeySign generates the signature
 func (tc *BridgeTssServer) KeySign(pk string, msgs []string, blockHeight int64, signers []string, version string) (keysign.Response, error) {
 	req := keysign.NewRequest(pk, msgs, blockHeight, signers, version)
-	resp, err := tc.ts.KeySign(req)
-	if err != nil {
-		return keysign.Response{}, err
-	}
-	return resp, nil
+	// the response carries the blame even if the keysign fails
+	return tc.ts.KeySign(req)
 }
 
 // GetTssNodeID get the tss node ID